@@ -9,6 +9,7 @@ package iql
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/markkurossi/iql/lang"
 	"github.com/markkurossi/iql/types"
@@ -17,8 +18,10 @@ import (
 
 // Client implements the IQL client.
 type Client struct {
-	global *lang.Scope
-	out    io.Writer
+	global      *lang.Scope
+	out         io.Writer
+	diagnostics lang.DiagnosticHandler
+	warnings    lang.WarningHandler
 }
 
 // NewClient creates a new IQL client.
@@ -27,11 +30,26 @@ func NewClient(out io.Writer) *Client {
 	lang.InitSystemVariables(global)
 
 	return &Client{
-		global: global,
-		out:    out,
+		global:      global,
+		out:         out,
+		diagnostics: lang.LogDiagnostic,
 	}
 }
 
+// SetDiagnosticHandler sets the handler for the parser error
+// diagnostics. The default handler prints the diagnostics with the
+// standard logger and the nil handler discards the diagnostics.
+func (c *Client) SetDiagnosticHandler(h lang.DiagnosticHandler) {
+	c.diagnostics = h
+}
+
+// SetWarningHandler sets the handler for the query warnings. The
+// handler is called for each warning of a query after the query is
+// evaluated. The default nil handler discards the warnings.
+func (c *Client) SetWarningHandler(h lang.WarningHandler) {
+	c.warnings = h
+}
+
 // SetString assigns the string value to the global variable. The
 // global variable must have been declared and its type must be
 // VARCHAR.
@@ -39,6 +57,28 @@ func (c *Client) SetString(name, value string) error {
 	return c.global.Set(name, types.StringValue(value))
 }
 
+// SetBool assigns the boolean value to the global variable. The
+// global variable must have been declared and its type must be
+// BOOLEAN.
+func (c *Client) SetBool(name string, value bool) error {
+	return c.global.Set(name, types.BoolValue(value))
+}
+
+// SetTable assigns the data source to the global table variable. The
+// variable is declared if it does not exist yet. The table can be
+// used as a data source in queries.
+func (c *Client) SetTable(name string, source types.Source) error {
+	if c.global.Get(name) == nil {
+		err := c.global.Declare(name, types.Table, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return c.global.Set(name, types.TableValue{
+		Source: source,
+	})
+}
+
 // SetStringArray assings the string array value to the global
 // variable. The global variable must have been declared and its type
 // must be []VARCHAR.
@@ -61,6 +101,7 @@ func (c *Client) Write(p []byte) (n int, err error) {
 // Parse parses the IQL file.
 func (c *Client) Parse(input io.Reader, source string) error {
 	parser := lang.NewParser(c.global, input, source, c)
+	parser.SetDiagnosticHandler(c.diagnostics)
 	for {
 		q, err := parser.Parse()
 		if err != nil {
@@ -69,12 +110,45 @@ func (c *Client) Parse(input io.Reader, source string) error {
 			}
 			return err
 		}
-		tab, err := types.Tabulate(q, c.SysTableFmt())
+		start := time.Now()
+		rows, err := q.Get()
+		if err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+
+		style := c.SysTableFmt()
+		tab, err := types.Tabulate(q, style)
 		if err != nil {
 			return err
 		}
 		tab.Print(c)
+
+		if lang.Timing(c.global) && style != tabulate.CSV &&
+			style != tabulate.JSON {
+			fmt.Fprintln(c, timingFooter(len(rows), elapsed))
+		}
+
+		if c.warnings != nil {
+			for _, w := range q.Warnings() {
+				c.warnings(w)
+			}
+		}
+	}
+}
+
+// timingFooter formats the query statistics footer.
+func timingFooter(rows int, elapsed time.Duration) string {
+	unit := "rows"
+	if rows == 1 {
+		unit = "row"
+	}
+	if elapsed >= time.Millisecond {
+		elapsed = elapsed.Round(time.Millisecond)
+	} else {
+		elapsed = elapsed.Round(time.Microsecond)
 	}
+	return fmt.Sprintf("%d %s in %s", rows, unit, elapsed)
 }
 
 // SysTableFmt returns the table formatting style.
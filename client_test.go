@@ -7,10 +7,14 @@
 package iql
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/markkurossi/iql/data"
 	"github.com/markkurossi/iql/lang"
+	"github.com/markkurossi/iql/types"
 )
 
 func TestClient(t *testing.T) {
@@ -20,3 +24,335 @@ func TestClient(t *testing.T) {
 		t.Errorf("client.SetString(SysTableFmt): %s", err)
 	}
 }
+
+func TestClientTable(t *testing.T) {
+	source := data.NewRows([]types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Name",
+			},
+		},
+		{
+			Name: types.Reference{
+				Column: "Age",
+			},
+		},
+	}, []types.Row{
+		{
+			types.StringColumn("Alice"),
+			types.NewValueColumn(types.IntValue(42)),
+		},
+		{
+			types.StringColumn("Bob"),
+			types.NewValueColumn(types.IntValue(27)),
+		},
+		{
+			types.StringColumn("Carol"),
+			types.StringColumn("35"),
+		},
+	})
+
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "csv")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	err = client.SetTable("people", source)
+	if err != nil {
+		t.Fatalf("client.SetTable: %s", err)
+	}
+	err = client.Parse(strings.NewReader(`
+SELECT Name, Age + 1 AS Next
+FROM people
+WHERE Age > 30
+ORDER BY Age;`), "table")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	expected := "Name,Next\r\nCarol,36\r\nAlice,43\r\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestClientQuotedAlias(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "csv")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	// Name,Count
+	// a,1
+	// b,2
+	// c,3
+	// d,4
+	err = client.Parse(strings.NewReader(`
+SELECT Name AS "Item Name",
+       Count * 2 AS [Double Count],
+       Count AS 'Total Revenue'
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count > 3;`), "alias")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	expected := "Item Name,Double Count,Total Revenue\r\nd,8,4\r\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestClientColumnComment(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "ascii")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	// Name,Count
+	// a,1
+	// b,2
+	// c,3
+	// d,4
+	err = client.Parse(strings.NewReader(`
+SELECT Name,
+       Count * 1000 AS Weight COMMENT 'grams'
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count > 3;`), "comment")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "Weight") ||
+		!strings.Contains(lines[2], "grams") {
+		t.Errorf("comment not rendered under the header:\n%s", buf.String())
+	}
+}
+
+func TestClientThousands(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "ascii")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	err = client.Parse(strings.NewReader(`
+SET THOUSANDS = true;
+SELECT 1234567 AS Value, -1234567 AS Neg, 1234567 + 1 = 1234568 AS Eq;`),
+		"thousands")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	for _, expected := range []string{"1,234,567", "-1,234,567", "true"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("output does not contain %s:\n%s", expected, buf.String())
+		}
+	}
+
+	// CSV output is not grouped.
+	buf.Reset()
+	err = client.SetString(lang.SysTableFmt, "csv")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	err = client.Parse(strings.NewReader(`SELECT 1234567 AS Value;`),
+		"thousands")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	expected := "Value\r\n1234567\r\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestClientDecimalAlign(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "ascii")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	err = client.Parse(strings.NewReader(`
+SELECT Floats
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Floats > 0;`), "align")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	var rows int
+	pos := -1
+	for _, line := range strings.Split(buf.String(), "\n") {
+		idx := strings.IndexByte(line, '.')
+		if idx < 0 {
+			continue
+		}
+		rows++
+		if pos < 0 {
+			pos = idx
+		} else if idx != pos {
+			t.Errorf("decimal points not aligned:\n%s", buf.String())
+			break
+		}
+	}
+	if rows != 5 {
+		t.Errorf("got %d rows, expected 5:\n%s", rows, buf.String())
+	}
+}
+
+func TestClientTiming(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	err := client.SetString(lang.SysTableFmt, "ascii")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	query := `
+SELECT Name
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count > 1;`
+
+	err = client.Parse(strings.NewReader(query), "timing")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	if strings.Contains(buf.String(), "rows in") {
+		t.Errorf("unexpected statistics footer:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	err = client.SetBool(lang.SysTiming, true)
+	if err != nil {
+		t.Fatalf("client.SetBool(SysTiming): %s", err)
+	}
+	err = client.Parse(strings.NewReader(query), "timing")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	if !strings.Contains(buf.String(), "\n3 rows in ") {
+		t.Errorf("statistics footer missing:\n%s", buf.String())
+	}
+
+	// No footer in machine-readable formats.
+	buf.Reset()
+	err = client.SetString(lang.SysTableFmt, "csv")
+	if err != nil {
+		t.Fatalf("client.SetString(SysTableFmt): %s", err)
+	}
+	err = client.Parse(strings.NewReader(query), "timing")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	expected := "Name\r\nb\r\nc\r\nd\r\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestClientSelectIntoScalar(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient(&buf)
+	// Name,Count
+	// a,1
+	// b,2
+	// c,3
+	// d,4
+	err := client.Parse(strings.NewReader(`
+DECLARE total INTEGER;
+DECLARE name VARCHAR;
+DECLARE data VARCHAR;
+SET data = 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK';
+
+SELECT SUM(Count) INTO total FROM data;
+PRINT total;
+SET total = SELECT MAX(Count) FROM data WHERE Count < 4;
+PRINT total;
+SELECT Name INTO name FROM data WHERE Count = 2;
+PRINT name;
+SELECT Name INTO name FROM data WHERE Count > 4;
+PRINT name;`), "into")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	expected := "10\n3\nb\nnull\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+
+	for _, q := range []string{
+		`SELECT Name INTO name FROM data;`,
+		`SELECT Name, Count INTO name FROM data WHERE Count = 1;`,
+		`SELECT Name INTO total FROM data WHERE Count = 1;`,
+	} {
+		err = client.Parse(strings.NewReader(q), "into")
+		if err == nil {
+			t.Errorf("expected error: %s", q)
+		}
+	}
+}
+
+func TestClientDiagnostics(t *testing.T) {
+	var diagnostics []*lang.Diagnostic
+
+	client := NewClient(&bytes.Buffer{})
+	client.SetDiagnosticHandler(func(d *lang.Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	})
+	err := client.Parse(strings.NewReader("SELECT 1 +;\n"), "diag")
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, expected 1", len(diagnostics))
+	}
+	d := diagnostics[0]
+	if d.Line != "SELECT 1 +;" || d.Indicator != "          ^" {
+		t.Errorf("unexpected diagnostic:\n%s", d)
+	}
+	if d.Loc.Line != 1 || d.Loc.Col != 10 {
+		t.Errorf("unexpected diagnostic location: %s", d.Loc)
+	}
+
+	// Discard diagnostics.
+	diagnostics = nil
+	client.SetDiagnosticHandler(nil)
+	err = client.Parse(strings.NewReader("SELECT 1 +;\n"), "diag")
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestClientWarnings(t *testing.T) {
+	client := NewClient(new(bytes.Buffer))
+	var warnings []lang.Warning
+	client.SetWarningHandler(func(w lang.Warning) {
+		warnings = append(warnings, w)
+	})
+	// Name,Count
+	// a,1
+	// b,2
+	// c,x
+	// d,4
+	query := `SELECT Count FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMseApkLDQK';`
+	err := client.Parse(strings.NewReader(query), "warnings")
+	if err != nil {
+		t.Fatalf("client.Parse: %s", err)
+	}
+	if len(warnings) != 1 || warnings[0].Column != "Count" {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
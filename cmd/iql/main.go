@@ -28,6 +28,11 @@ func main() {
 	tableFmt := flag.String("t", "uc", "table formatting style")
 	expr := flag.String("e", "", "code to execute")
 	output := flag.String("o", "", "output file name (default is stdout)")
+	strict := flag.Bool("strict", false, "fail on ambiguous column types")
+	warnings := flag.Bool("warnings", false,
+		"print query warnings to stderr")
+	usePager := flag.Bool("pager", false,
+		"show output longer than the terminal with $PAGER")
 	flag.Parse()
 	log.SetFlags(0)
 
@@ -59,13 +64,32 @@ func main() {
 		defer out.Close()
 	}
 
+	// The pager is used only for terminal output.
+	var w io.Writer = out
+	var pager *Pager
+	if *usePager && len(*output) == 0 {
+		pager = NewPager(out)
+		if pager != nil {
+			w = pager
+		}
+	}
+	flush := func(client *iql.Client) {
+		if pager == nil {
+			return
+		}
+		if err := pager.Flush(client.SysTableFmt()); err != nil {
+			log.Printf("%s: %s\n", program, err)
+		}
+	}
+
 	if len(*expr) > 0 {
-		client := newClient(out, program, *tableFmt)
+		client := newClient(w, program, *tableFmt, *strict, *warnings)
 		err := client.SetStringArray(lang.SysARGS, flag.Args())
 		if err != nil {
 			log.Fatalf("%s: %s\n", program, err)
 		}
 		err = client.Parse(strings.NewReader(*expr), "expr")
+		flush(client)
 		if err != nil {
 			log.Fatalf("%s: %s\n", program, err)
 		}
@@ -95,8 +119,9 @@ func main() {
 				fmt.Printf("%s:%s: nth=%d:\n%v\n", arg, *htmlFilter, idx, r)
 			}
 		} else {
-			client := newClient(out, program, *tableFmt)
+			client := newClient(w, program, *tableFmt, *strict, *warnings)
 			err = client.Parse(f, arg)
+			flush(client)
 			if err != nil {
 				log.Fatalf("%s: %s\n", arg, err)
 			}
@@ -104,7 +129,9 @@ func main() {
 	}
 }
 
-func newClient(out io.Writer, program, tableFmt string) *iql.Client {
+func newClient(out io.Writer, program, tableFmt string,
+	strict, warnings bool) *iql.Client {
+
 	client := iql.NewClient(out)
 	err := client.SetString(lang.SysTableFmt, tableFmt)
 	if err != nil {
@@ -112,5 +139,14 @@ func newClient(out io.Writer, program, tableFmt string) *iql.Client {
 		log.Fatalf("Possible styles are: %s\n",
 			strings.Join(tabulate.StyleNames(), ", "))
 	}
+	err = client.SetBool(lang.SysStrict, strict)
+	if err != nil {
+		log.Fatalf("%s: %s\n", program, err)
+	}
+	if warnings {
+		client.SetWarningHandler(func(w lang.Warning) {
+			log.Printf("%s: warning: %s\n", program, w)
+		})
+	}
 	return client
 }
@@ -0,0 +1,93 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/markkurossi/tabulate"
+)
+
+// defaultPager specifies the pager command that is used if the PAGER
+// environment variable is not set.
+const defaultPager = "less"
+
+// Pager buffers the program output and shows it with an external
+// pager if the output does not fit into the terminal window.
+type Pager struct {
+	out    *os.File
+	buf    bytes.Buffer
+	height int
+}
+
+// NewPager creates a new pager for the output file. The function
+// returns nil if the output is not a terminal.
+func NewPager(out *os.File) *Pager {
+	if !isTerminal(out) {
+		return nil
+	}
+	return &Pager{
+		out:    out,
+		height: terminalHeight(out),
+	}
+}
+
+// Write implements io.Writer.Write().
+func (p *Pager) Write(data []byte) (int, error) {
+	return p.buf.Write(data)
+}
+
+// Flush writes the buffered output with the table style. The output
+// is shown with the pager if it is longer than the terminal height.
+func (p *Pager) Flush(style tabulate.Style) error {
+	defer p.buf.Reset()
+
+	lines := bytes.Count(p.buf.Bytes(), []byte{'\n'})
+	if !usePager(true, style, lines, p.height) {
+		_, err := p.out.Write(p.buf.Bytes())
+		return err
+	}
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = []string{defaultPager}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(p.buf.Bytes())
+	cmd.Stdout = p.out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// Show the output without the pager.
+		_, err = io.Copy(p.out, bytes.NewReader(p.buf.Bytes()))
+		return err
+	}
+	return nil
+}
+
+// usePager tests if the output of lines lines is shown with the
+// pager. The pager is used only for terminals and for the
+// human-readable table styles.
+func usePager(tty bool, style tabulate.Style, lines, height int) bool {
+	if !tty || height <= 0 {
+		return false
+	}
+	if style == tabulate.CSV || style == tabulate.JSON {
+		return false
+	}
+	return lines >= height
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,40 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/markkurossi/tabulate"
+)
+
+var usePagerTests = []struct {
+	tty    bool
+	style  tabulate.Style
+	lines  int
+	height int
+	v      bool
+}{
+	{true, tabulate.Unicode, 100, 24, true},
+	{true, tabulate.Unicode, 24, 24, true},
+	{true, tabulate.Unicode, 23, 24, false},
+	{true, tabulate.Plain, 100, 24, true},
+	{false, tabulate.Unicode, 100, 24, false},
+	{true, tabulate.CSV, 100, 24, false},
+	{true, tabulate.JSON, 100, 24, false},
+	{true, tabulate.Unicode, 100, 0, false},
+}
+
+func TestUsePager(t *testing.T) {
+	for idx, test := range usePagerTests {
+		v := usePager(test.tty, test.style, test.lines, test.height)
+		if v != test.v {
+			t.Errorf("test %d: usePager(%v, %v, %d, %d)=%v, expected %v",
+				idx, test.tty, test.style, test.lines, test.height, v, test.v)
+		}
+	}
+}
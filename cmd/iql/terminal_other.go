@@ -0,0 +1,25 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// terminalHeight returns the height of the terminal in lines or 0 if
+// the height is unknown.
+func terminalHeight(f *os.File) int {
+	lines, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil {
+		return 0
+	}
+	return lines
+}
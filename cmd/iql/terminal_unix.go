@@ -0,0 +1,40 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build linux || darwin
+// +build linux darwin
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// terminalHeight returns the height of the terminal in lines or 0 if
+// the height is unknown.
+func terminalHeight(f *os.File) int {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(),
+		uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno == 0 && ws.Row > 0 {
+		return int(ws.Row)
+	}
+	lines, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil {
+		return 0
+	}
+	return lines
+}
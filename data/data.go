@@ -7,29 +7,43 @@
 package data
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/markkurossi/iql/types"
+	"golang.org/x/text/encoding"
 )
 
 var (
 	_ types.Source = &CSV{}
 	_ types.Source = &HTML{}
+	_ types.Source = &Rows{}
 )
 
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
 // NewSource defines a constructor for data sources.
 type NewSource func(in []io.ReadCloser, filter string,
 	columns []types.ColumnSelector) (types.Source, error)
 
+// WriteSource defines a writer for data sources.
+type WriteSource func(w io.Writer, source types.Source, filter string) error
+
 // New creates a new data source for the URL.
 func New(urls []string, filter string, columns []types.ColumnSelector) (
 	types.Source, error) {
@@ -38,11 +52,16 @@ func New(urls []string, filter string, columns []types.ColumnSelector) (
 		return nil, fmt.Errorf("empty URL list")
 	}
 
+	opts, filter, err := parseInputOptions(filter)
+	if err != nil {
+		return nil, err
+	}
+
 	var inputs []io.ReadCloser
 	var format Format
 
 	for idx, url := range urls {
-		input, f, err := openInput(url)
+		input, f, err := openInput(url, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -60,7 +79,130 @@ func New(urls []string, filter string, columns []types.ColumnSelector) (
 	return n(inputs, filter, columns)
 }
 
-func openInput(input string) ([]io.ReadCloser, Format, error) {
+// Write writes the source into the output file. The output format is
+// resolved from the file name suffix unless the format argument
+// specifies it. The HTTP and HTTPS URL outputs are written with the
+// HTTP POST method.
+func Write(output string, format Format, source types.Source,
+	filter string) error {
+
+	var resolver Resolver
+	u, err := url.Parse(output)
+	isHTTP := err == nil && (u.Scheme == "http" || u.Scheme == "https")
+	if isHTTP {
+		resolver.ResolvePath(u.Path)
+	} else {
+		resolver.ResolvePath(output)
+	}
+	if format == FormatUnknown {
+		format, err = resolver.Format()
+		if err != nil {
+			return err
+		}
+	}
+	w, ok := writers[format]
+	if !ok {
+		return fmt.Errorf("unsupported output format '%s'", format)
+	}
+	if isHTTP {
+		return post(output, format, w, source, filter)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = w(f, source, filter)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// post writes the source into the HTTP URL with the POST method. The
+// filter can specify the input option timeout for the request.
+func post(output string, format Format, w WriteSource, source types.Source,
+	filter string) error {
+
+	opts, filter, err := parseInputOptions(filter)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = w(&buf, source, filter)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: opts.timeout,
+	}
+	resp, err := client.Post(output, format.MediaType(), &buf)
+	if err != nil {
+		if isTimeout(err) {
+			return fmt.Errorf("HTTP URL '%s': timeout after %s",
+				output, opts.timeout)
+		}
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP URL '%s': %s", output, resp.Status)
+	}
+	return nil
+}
+
+// inputOptions define the input options for the HTTP sources.
+type inputOptions struct {
+	maxBytes int64
+	timeout  time.Duration
+}
+
+// parseInputOptions parses the input options from the source filter.
+// The function returns the options and the filter without the input
+// options. The filter is returned unmodified if it does not contain
+// input options.
+func parseInputOptions(filter string) (*inputOptions, string, error) {
+	opts := new(inputOptions)
+
+	var rest []string
+	var found bool
+	for _, option := range strings.Split(filter, " ") {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 {
+			rest = append(rest, option)
+			continue
+		}
+		switch parts[0] {
+		case "max-bytes":
+			n, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil || n <= 0 {
+				return nil, "", fmt.Errorf("invalid max-bytes: %s", parts[1])
+			}
+			opts.maxBytes = n
+			found = true
+
+		case "timeout":
+			d, err := time.ParseDuration(parts[1])
+			if err != nil || d <= 0 {
+				return nil, "", fmt.Errorf("invalid timeout: %s", parts[1])
+			}
+			opts.timeout = d
+			found = true
+
+		default:
+			rest = append(rest, option)
+		}
+	}
+	if !found {
+		return opts, filter, nil
+	}
+	return opts, strings.Join(rest, " "), nil
+}
+
+func openInput(input string, opts *inputOptions) (
+	[]io.ReadCloser, Format, error) {
+
 	var resolver Resolver
 
 	u, err := url.Parse(input)
@@ -70,8 +212,15 @@ func openInput(input string) ([]io.ReadCloser, Format, error) {
 		resolver.ResolvePath(u.Path)
 	}
 	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
-		resp, err := http.Get(input)
+		client := &http.Client{
+			Timeout: opts.timeout,
+		}
+		resp, err := client.Get(input)
 		if err != nil {
+			if isTimeout(err) {
+				return nil, 0, fmt.Errorf("HTTP URL '%s': timeout after %s",
+					input, opts.timeout)
+			}
 			return nil, 0, err
 		}
 		if resp.StatusCode != http.StatusOK {
@@ -83,7 +232,13 @@ func openInput(input string) ([]io.ReadCloser, Format, error) {
 		resolver.ResolveMediaType(resp.Header.Get("Content-Type"))
 
 		format, err := resolver.Format()
-		return []io.ReadCloser{resp.Body}, format, err
+		return []io.ReadCloser{
+			&httpBody{
+				url:  input,
+				body: resp.Body,
+				opts: opts,
+			},
+		}, format, err
 	}
 	if err == nil && u.Scheme == "data" {
 		idx := strings.IndexByte(input, ',')
@@ -127,6 +282,11 @@ func openInput(input string) ([]io.ReadCloser, Format, error) {
 		}, format, err
 	}
 
+	if err == nil && len(u.Fragment) > 0 &&
+		strings.ToLower(filepath.Ext(u.Path)) == ".zip" {
+		return openZip(u.Path, u.Fragment)
+	}
+
 	matches, err := filepath.Glob(input)
 	if err != nil {
 		return nil, 0, err
@@ -148,6 +308,139 @@ func openInput(input string) ([]io.ReadCloser, Format, error) {
 	return result, format, err
 }
 
+// openZip opens the ZIP archive members matching the glob pattern.
+// The input format is resolved from the member names.
+func openZip(archive, pattern string) ([]io.ReadCloser, Format, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, 0, err
+	}
+	z := &zipArchive{
+		r: r,
+	}
+
+	var result []io.ReadCloser
+	var format Format
+	// closeAll closes the opened members and the archive and returns
+	// the error err or the first close error. Closing the last member
+	// closes the archive so the archive is closed here only if no
+	// members were opened.
+	closeAll := func(err error) error {
+		for _, rc := range result {
+			if cerr := rc.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if len(result) == 0 {
+			if cerr := r.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+
+	for _, f := range r.File {
+		match, err := path.Match(pattern, f.Name)
+		if err != nil {
+			return nil, 0, closeAll(err)
+		}
+		if !match || f.FileInfo().IsDir() {
+			continue
+		}
+		var resolver Resolver
+		resolver.ResolvePath(f.Name)
+		ff, err := resolver.Format()
+		if err != nil {
+			return nil, 0, closeAll(fmt.Errorf("%s#%s: %s", archive, f.Name,
+				err))
+		}
+		if len(result) > 0 && ff != format {
+			return nil, 0, closeAll(fmt.Errorf(
+				"mixed source formats: %s, %s", format, ff))
+		}
+		format = ff
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, 0, closeAll(err)
+		}
+		z.refs++
+		result = append(result, &zipMember{
+			ReadCloser: rc,
+			archive:    z,
+		})
+	}
+	if len(result) == 0 {
+		return nil, 0, closeAll(fmt.Errorf("file not found: %s#%s",
+			archive, pattern))
+	}
+	return result, format, nil
+}
+
+// zipArchive implements a reference counted ZIP archive. The archive
+// is closed when all its opened members are closed.
+type zipArchive struct {
+	r    *zip.ReadCloser
+	refs int
+}
+
+// zipMember implements a ZIP archive member reader.
+type zipMember struct {
+	io.ReadCloser
+	archive *zipArchive
+	closed  bool
+}
+
+func (m *zipMember) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	err := m.ReadCloser.Close()
+	m.archive.refs--
+	if m.archive.refs == 0 {
+		if cerr := m.archive.r.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// httpBody implements HTTP response body reader which enforces the
+// input options.
+type httpBody struct {
+	url   string
+	body  io.ReadCloser
+	opts  *inputOptions
+	count int64
+}
+
+func (b *httpBody) Read(p []byte) (n int, err error) {
+	if b.opts.maxBytes > 0 && int64(len(p)) > b.opts.maxBytes-b.count+1 {
+		p = p[:b.opts.maxBytes-b.count+1]
+	}
+	n, err = b.body.Read(p)
+	b.count += int64(n)
+	if b.opts.maxBytes > 0 && b.count > b.opts.maxBytes {
+		return 0, fmt.Errorf("HTTP URL '%s': input exceeds max-bytes %d",
+			b.url, b.opts.maxBytes)
+	}
+	if err != nil && err != io.EOF && isTimeout(err) {
+		return n, fmt.Errorf("HTTP URL '%s': timeout after %s",
+			b.url, b.opts.timeout)
+	}
+	return n, err
+}
+
+func (b *httpBody) Close() error {
+	return b.body.Close()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 type memory struct {
 	in io.Reader
 }
@@ -159,3 +452,19 @@ func (m *memory) Read(p []byte) (n int, err error) {
 func (m *memory) Close() error {
 	return nil
 }
+
+// textReader returns a reader that decodes the input from the
+// encoding enc into UTF-8 and strips the leading byte order mark from
+// the decoded data. If the encoding is nil, the input is read as
+// UTF-8.
+func textReader(in io.Reader, enc encoding.Encoding) io.Reader {
+	if enc != nil {
+		in = enc.NewDecoder().Reader(in)
+	}
+	r := bufio.NewReader(in)
+	bom, err := r.Peek(len(utf8BOM))
+	if err == nil && bytes.Equal(bom, utf8BOM) {
+		r.Discard(len(utf8BOM))
+	}
+	return r
+}
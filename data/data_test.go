@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+func writeZip(t *testing.T, members map[string]string) string {
+	name := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for member := range members {
+		names = append(names, member)
+	}
+	sort.Strings(names)
+
+	w := zip.NewWriter(f)
+	for _, member := range names {
+		mw, err := w.Create(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mw.Write([]byte(members[member])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestZip(t *testing.T) {
+	archive := writeZip(t, map[string]string{
+		"data/a.csv": "Name,Count\na,1\nb,2\n",
+		"data/b.csv": "Name,Count\nc,3\n",
+		"readme.txt": "Test archive\n",
+	})
+
+	tests := []struct {
+		member string
+		v      [][]string
+	}{
+		{
+			member: "data/a.csv",
+			v: [][]string{
+				{"a", "1"},
+				{"b", "2"},
+			},
+		},
+		{
+			member: "data/*.csv",
+			v: [][]string{
+				{"a", "1"},
+				{"b", "2"},
+				{"c", "3"},
+			},
+		},
+	}
+	for _, test := range tests {
+		input := archive + "#" + test.member
+		source, err := New([]string{input}, "", nil)
+		if err != nil {
+			t.Fatalf("%s: New failed: %s", input, err)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", input, err)
+		}
+		if len(rows) != len(test.v) {
+			t.Fatalf("%s: got %d rows, expected %d", input, len(rows),
+				len(test.v))
+		}
+		for i, row := range rows {
+			for j, col := range row {
+				if col.String() != test.v[i][j] {
+					t.Errorf("%s: row %d column %d: got %s, expected %s",
+						input, i, j, col, test.v[i][j])
+				}
+			}
+		}
+		if source.Columns()[1].Type != types.Int {
+			t.Errorf("%s: unexpected column type: %s", input,
+				source.Columns()[1].Type)
+		}
+	}
+
+	for _, member := range []string{"missing.csv", "readme.txt", "*"} {
+		_, err := New([]string{archive + "#" + member}, "", nil)
+		if err == nil {
+			t.Errorf("%s: expected error", member)
+		}
+	}
+}
+
+func TestHTTPOptions(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			fmt.Fprintln(w, "Name,Count")
+			for i := 0; i < 100; i++ {
+				fmt.Fprintf(w, "row%d,%d\n", i, i)
+			}
+			if r.URL.Path == "/slow.csv" {
+				w.(http.Flusher).Flush()
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+				}
+			}
+		}))
+	defer server.Close()
+	defer close(done)
+
+	tests := []struct {
+		path   string
+		filter string
+		err    string
+	}{
+		{
+			path: "/fast.csv",
+		},
+		{
+			path:   "/fast.csv",
+			filter: "timeout=5s max-bytes=10000 skip=0",
+		},
+		{
+			path:   "/fast.csv",
+			filter: "max-bytes=100",
+			err:    "exceeds max-bytes 100",
+		},
+		{
+			path:   "/slow.csv",
+			filter: "timeout=100ms",
+			err:    "timeout after 100ms",
+		},
+	}
+	for _, test := range tests {
+		url := server.URL + test.path
+		start := time.Now()
+		source, err := New([]string{url}, test.filter, nil)
+		if err == nil {
+			_, err = source.Get()
+		}
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s %q: unexpected error: %s", test.path, test.filter,
+					err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s %q: got error %v, expected %s", test.path,
+				test.filter, err, test.err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("%s %q: abort took %s", test.path, test.filter,
+				time.Since(start))
+		}
+	}
+
+	for _, filter := range []string{"timeout=x", "max-bytes=-1"} {
+		_, err := New([]string{server.URL + "/fast.csv"}, filter, nil)
+		if err == nil {
+			t.Errorf("%q: expected error", filter)
+		}
+	}
+}
@@ -42,6 +42,11 @@ var formats = map[Format]NewSource{
 	FormatJSON: NewJSON,
 }
 
+var writers = map[Format]WriteSource{
+	FormatCSV:  WriteCSV,
+	FormatJSON: WriteJSON,
+}
+
 var formatNames = map[Format]string{
 	FormatUnknown: "unknown",
 	FormatCSV:     "csv",
@@ -57,6 +62,27 @@ func (f Format) String() string {
 	return fmt.Sprintf("{Format %d}", f)
 }
 
+// MediaType returns the media type of the format. The function
+// returns an empty string if the format does not have a media type.
+func (f Format) MediaType() string {
+	for mediatype, format := range mediatypes {
+		if format == f {
+			return mediatype
+		}
+	}
+	return ""
+}
+
+// ParseFormat parses the data format name.
+func ParseFormat(name string) (Format, error) {
+	for f, n := range formatNames {
+		if f != FormatUnknown && n == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return FormatUnknown, fmt.Errorf("unknown data format '%s'", name)
+}
+
 // Resolver resolves data format from input meta data.
 type Resolver struct {
 	format Format
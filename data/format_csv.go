@@ -13,8 +13,11 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/markkurossi/iql/types"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 // CSV implements a data source from comma-separated values (CSV).
@@ -23,24 +26,34 @@ type CSV struct {
 	rows    []types.Row
 }
 
-// NewCSV creates a new CSV data source from the input.
-func NewCSV(input []io.ReadCloser, filter string,
-	columns []types.ColumnSelector) (types.Source, error) {
-
-	for _, in := range input {
-		defer in.Close()
-	}
-
-	// Parse filter options
+// csvOptions define the CSV processing options.
+type csvOptions struct {
+	skip             int
+	headerRow        int
+	comment          rune
+	headers          bool
+	prependHeaders   []string
+	unitsRow         bool
+	trimLeadingSpace bool
+	trimCells        bool
+	skipEmptyRows    bool
+	ragged           bool
+	comma            rune
+	decimal          rune
+	grouping         rune
+	charset          encoding.Encoding
+	null             *string
+}
 
+// parseCSVOptions parses the CSV filter options.
+func parseCSVOptions(filter string) (*csvOptions, error) {
 	var err error
-	var skip int
-	var comment rune
 
-	headers := true
-	var prependHeaders []string
-	trimLeadingSpace := false
-	comma := ','
+	opts := &csvOptions{
+		headerRow: -1,
+		headers:   true,
+		comma:     ',',
+	}
 
 	for _, option := range strings.Split(filter, " ") {
 		if len(option) == 0 {
@@ -51,10 +64,22 @@ func NewCSV(input []io.ReadCloser, filter string,
 		case 1:
 			switch parts[0] {
 			case "trim-leading-space":
-				trimLeadingSpace = true
+				opts.trimLeadingSpace = true
 
 			case "noheaders":
-				headers = false
+				opts.headers = false
+
+			case "ragged", "lazy-fields":
+				opts.ragged = true
+
+			case "units-row":
+				opts.unitsRow = true
+
+			case "trim-cells":
+				opts.trimCells = true
+
+			case "skip-empty-rows":
+				opts.skipEmptyRows = true
 
 			default:
 				return nil, fmt.Errorf("csv: invalid filter flag: %s", parts[0])
@@ -63,23 +88,42 @@ func NewCSV(input []io.ReadCloser, filter string,
 		case 2:
 			switch parts[0] {
 			case "skip":
-				skip, err = strconv.Atoi(parts[1])
+				opts.skip, err = strconv.Atoi(parts[1])
 				if err != nil {
 					return nil, fmt.Errorf("csv: invalid skip count: %s",
 						parts[1])
 				}
 
+			case "header-row":
+				opts.headerRow, err = strconv.Atoi(parts[1])
+				if err != nil || opts.headerRow < 0 {
+					return nil, fmt.Errorf("csv: invalid header row: %s",
+						parts[1])
+				}
+
 			case "comma":
 				switch parts[1] {
 				case "TAB":
-					comma = '\t'
+					opts.comma = '\t'
 				default:
 					runes := []rune(parts[1])
 					if len(runes) != 1 {
 						return nil, fmt.Errorf("csv: comma must be rune: %s",
 							parts[1])
 					}
-					comma = runes[0]
+					opts.comma = runes[0]
+				}
+
+			case "decimal", "grouping":
+				runes := []rune(parts[1])
+				if len(runes) != 1 {
+					return nil, fmt.Errorf("csv: %s must be rune: %s",
+						parts[0], parts[1])
+				}
+				if parts[0] == "decimal" {
+					opts.decimal = runes[0]
+				} else {
+					opts.grouping = runes[0]
 				}
 
 			case "comment":
@@ -88,10 +132,21 @@ func NewCSV(input []io.ReadCloser, filter string,
 					return nil, fmt.Errorf("csv: comment must be rune: %s",
 						parts[1])
 				}
-				comment = runes[0]
+				opts.comment = runes[0]
+
+			case "charset":
+				opts.charset, err = htmlindex.Get(parts[1])
+				if err != nil {
+					return nil, fmt.Errorf("csv: unknown charset: %s",
+						parts[1])
+				}
+
+			case "null":
+				null := parts[1]
+				opts.null = &null
 
 			case "prepend-headers":
-				prependHeaders = strings.Split(parts[1], ",")
+				opts.prependHeaders = strings.Split(parts[1], ",")
 
 			default:
 				return nil, fmt.Errorf("csv: unknown option: %s", parts[0])
@@ -101,17 +156,78 @@ func NewCSV(input []io.ReadCloser, filter string,
 			return nil, fmt.Errorf("csv: invalid filter option: %s", option)
 		}
 	}
+	if opts.unitsRow && !opts.headers {
+		return nil, errors.New("csv: units-row requires headers")
+	}
+	if opts.headerRow >= 0 {
+		if !opts.headers {
+			return nil, errors.New("csv: header-row requires headers")
+		}
+		if opts.skip != 0 {
+			return nil, errors.New("csv: header-row and skip are exclusive")
+		}
+	}
+	if opts.grouping != 0 {
+		decimal := opts.decimal
+		if decimal == 0 {
+			decimal = '.'
+		}
+		if opts.grouping == decimal {
+			return nil, fmt.Errorf("csv: grouping equals decimal separator: %c",
+				decimal)
+		}
+	}
+	return opts, nil
+}
+
+// NewCSV creates a new CSV data source from the input.
+func NewCSV(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	for _, in := range input {
+		defer in.Close()
+	}
+
+	opts, err := parseCSVOptions(filter)
+	if err != nil {
+		return nil, err
+	}
+	skip := opts.skip
 
 	var rows []types.Row
 	var indices []int
+	var fromEnd bool
+
+	// Without headers, columns are selected by their indices. The
+	// negative indices select columns from the end of each record so
+	// the records can have different number of fields.
+	if !opts.headers {
+		if len(columns) == 0 {
+			return nil, errors.New(
+				"csv: 'SELECT *' not supported without headers")
+		}
+		for _, col := range columns {
+			i, err := strconv.Atoi(col.Name.Column)
+			if err != nil {
+				return nil, err
+			}
+			if i < 0 {
+				fromEnd = true
+			}
+			indices = append(indices, i)
+		}
+	}
 
 	for idx, in := range input {
-		reader := csv.NewReader(in)
-		reader.Comment = comment
-		reader.TrimLeadingSpace = trimLeadingSpace
-		reader.Comma = comma
-
-		if len(prependHeaders) > 0 {
+		reader := csv.NewReader(textReader(in, opts.charset))
+		reader.Comment = opts.comment
+		reader.TrimLeadingSpace = opts.trimLeadingSpace
+		reader.Comma = opts.comma
+
+		// The banner records above the header row can have any
+		// number of fields.
+		if len(opts.prependHeaders) > 0 || fromEnd || opts.ragged ||
+			opts.headerRow >= 0 {
 			reader.FieldsPerRecord = -1
 		}
 
@@ -119,67 +235,103 @@ func NewCSV(input []io.ReadCloser, filter string,
 		if err != nil {
 			return nil, err
 		}
+		if opts.headerRow >= 0 {
+			skip = opts.headerRow
+		}
 		if skip > len(records) {
 			skip = len(records)
 		}
 		records = records[skip:]
+		if opts.headerRow >= 0 && !opts.ragged &&
+			len(opts.prependHeaders) == 0 {
+			err = checkFieldCounts(records, opts.headerRow)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-		if idx == 0 {
-			if headers {
-				// Mapping from column names to column indices.
-				if len(records) == 0 {
-					return nil, errors.New("csv: no records")
-				}
-
-				r0 := append(prependHeaders, records[0]...)
+		if idx == 0 && opts.headers {
+			// Mapping from column names to column indices.
+			if len(records) == 0 {
+				return nil, errors.New("csv: no records")
+			}
 
-				// Collect all column names; unselected columns are
-				// appended to the source's columns array.
-				seen := make(map[string]bool)
-				for _, col := range columns {
-					seen[col.Name.Column] = true
+			header := records[0]
+			if opts.trimCells {
+				header = trimRecord(header)
+			}
+			r0 := uniqueHeaders(append(opts.prependHeaders, header...))
+
+			// Order the columns in their declaration order in the
+			// header row. The unselected columns are included in
+			// their header positions so "SELECT *" and the selected
+			// columns have the same stable order.
+			selected := make(map[string][]types.ColumnSelector)
+			for _, col := range columns {
+				selected[col.Name.Column] = append(selected[col.Name.Column],
+					col)
+			}
+			var ordered []types.ColumnSelector
+			seen := make(map[string]bool)
+			names := make(map[string]int)
+			for idx, col := range r0 {
+				names[col] = idx
+
+				if seen[col] {
+					continue
 				}
-				names := make(map[string]int)
-				for idx, col := range r0 {
-					names[col] = idx
-
-					if !seen[col] {
-						seen[col] = true
-						columns = append(columns, types.ColumnSelector{
-							Name: types.Reference{
-								Column: col,
-							},
-						})
-					}
+				seen[col] = true
+				sel, ok := selected[col]
+				if ok {
+					ordered = append(ordered, sel...)
+				} else {
+					ordered = append(ordered, types.ColumnSelector{
+						Name: types.Reference{
+							Column: col,
+						},
+					})
+				}
+			}
+			// The columns matching a header only by case are not
+			// selected. The header columns are included with their
+			// header names and the query resolves the names with its
+			// identifier case rules.
+			for _, col := range columns {
+				if !seen[col.Name.Column] &&
+					!headerFold(r0, col.Name.Column) {
+					return nil, fmt.Errorf("csv: unknown column: %s",
+						col.Name.Column)
 				}
+			}
+			columns = ordered
 
-				for _, col := range columns {
-					i, ok := names[col.Name.Column]
-					if !ok {
-						return nil, fmt.Errorf("csv: unknown column: %s",
-							col.Name.Column)
-					}
-					indices = append(indices, i)
+			for _, col := range columns {
+				i, ok := names[col.Name.Column]
+				if !ok {
+					return nil, fmt.Errorf("csv: unknown column: %s",
+						col.Name.Column)
 				}
-			} else {
-				if len(columns) == 0 {
-					return nil, errors.New(
-						"csv: 'SELECT *' not supported without headers")
-				}
-				for _, col := range columns {
-					i, err := strconv.Atoi(col.Name.Column)
-					if err != nil {
-						return nil, err
+				indices = append(indices, i)
+			}
+
+			// The units row annotates the columns with comments.
+			if opts.unitsRow && len(records) > 1 {
+				units := records[1]
+				for i, idx := range indices {
+					if idx < len(units) && len(columns[i].Comment) == 0 {
+						columns[i].Comment = units[idx]
 					}
-					indices = append(indices, i)
 				}
 			}
 		}
-		if headers {
+		if opts.headers {
+			records = records[1:]
+		}
+		if opts.unitsRow && len(records) > 0 {
 			records = records[1:]
 		}
 
-		rows, err = processCSV(rows, records, indices, columns)
+		rows, err = processCSV(rows, records, indices, columns, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -191,24 +343,97 @@ func NewCSV(input []io.ReadCloser, filter string,
 	}, nil
 }
 
+// headerFold tests if the name matches any of the headers under
+// Unicode case-folding.
+func headerFold(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueHeaders renames the duplicate header names so that all
+// columns remain selectable. The first occurrence of a name keeps its
+// name and the following occurrences are suffixed with _2, _3, and so
+// on.
+func uniqueHeaders(headers []string) []string {
+	result := make([]string, len(headers))
+	used := make(map[string]bool)
+	for _, h := range headers {
+		used[h] = true
+	}
+	counts := make(map[string]int)
+	for idx, h := range headers {
+		counts[h]++
+		if counts[h] == 1 {
+			result[idx] = h
+			continue
+		}
+		for {
+			name := fmt.Sprintf("%s_%d", h, counts[h])
+			if !used[name] {
+				used[name] = true
+				result[idx] = name
+				break
+			}
+			counts[h]++
+		}
+	}
+	return result
+}
+
+// checkFieldCounts verifies that the records have the same number of
+// fields as the first record. The first is the 0-based index of the
+// first record in the input.
+func checkFieldCounts(records [][]string, first int) error {
+	for idx, record := range records {
+		if len(record) != len(records[0]) {
+			return fmt.Errorf("csv: record %d: %s",
+				first+idx, csv.ErrFieldCount)
+		}
+	}
+	return nil
+}
+
 func processCSV(rows []types.Row, records [][]string, indices []int,
-	columns []types.ColumnSelector) ([]types.Row, error) {
+	columns []types.ColumnSelector, opts *csvOptions) ([]types.Row, error) {
 
 	for _, record := range records {
+		if opts.trimCells {
+			record = trimRecord(record)
+		}
+		if opts.skipEmptyRows && emptyRecord(record) {
+			continue
+		}
 		var row types.Row
 		for i := range columns {
 			idx := indices[i]
 			var val string
+			var ok bool
 
 			if idx < 0 {
 				if -idx <= len(record) {
 					val = record[len(record)+idx]
+					ok = true
 				}
 			} else {
 				if idx < len(record) {
 					val = record[idx]
+					ok = true
 				}
 			}
+			if !ok && opts.ragged {
+				// Missing fields of ragged records are NULL.
+				row = append(row, types.NullColumn{})
+				continue
+			}
+			if opts.null != nil && val == *opts.null {
+				row = append(row, types.NullColumn{})
+				continue
+			}
+			val = opts.normalizeNumber(val)
 			columns[i].ResolveString(val)
 			row = append(row, types.StringColumn(val))
 		}
@@ -217,6 +442,90 @@ func processCSV(rows []types.Row, records [][]string, indices []int,
 	return rows, nil
 }
 
+// normalizeNumber converts the number val from the decimal and
+// grouping separators of the options into the standard number
+// syntax. The grouping separators are accepted only between the
+// integer digits so that all groups after the first have exactly 3
+// digits. The function returns val unmodified if the options do not
+// specify the separators or if val is not a number.
+func (opts *csvOptions) normalizeNumber(val string) string {
+	if opts.decimal == 0 && opts.grouping == 0 {
+		return val
+	}
+	decimal := opts.decimal
+	if decimal == 0 {
+		decimal = '.'
+	}
+	if decimal != '.' && opts.grouping != '.' &&
+		strings.ContainsRune(val, '.') {
+		// The standard decimal point is not a number character in
+		// this locale.
+		return val
+	}
+
+	integer := val
+	var frac string
+	idx := strings.IndexRune(val, decimal)
+	if idx >= 0 {
+		integer = val[:idx]
+		frac = val[idx+utf8.RuneLen(decimal):]
+		if opts.grouping != 0 && strings.ContainsRune(frac, opts.grouping) {
+			return val
+		}
+	}
+	var sign string
+	if strings.HasPrefix(integer, "-") || strings.HasPrefix(integer, "+") {
+		sign = integer[:1]
+		integer = integer[1:]
+	}
+	if opts.grouping != 0 && strings.ContainsRune(integer, opts.grouping) {
+		groups := strings.Split(integer, string(opts.grouping))
+		for i, group := range groups {
+			if i == 0 && (len(group) == 0 || len(group) > 3) {
+				return val
+			}
+			if i > 0 && len(group) != 3 {
+				return val
+			}
+			for _, r := range group {
+				if r < '0' || r > '9' {
+					return val
+				}
+			}
+		}
+		integer = strings.Join(groups, "")
+	}
+
+	result := sign + integer
+	if idx >= 0 {
+		result += "." + frac
+	}
+	if _, err := strconv.ParseFloat(result, 64); err != nil {
+		return val
+	}
+	return result
+}
+
+// trimRecord returns a copy of the record with leading and trailing
+// white space removed from all fields.
+func trimRecord(record []string) []string {
+	result := make([]string, len(record))
+	for i, field := range record {
+		result[i] = strings.TrimSpace(field)
+	}
+	return result
+}
+
+// emptyRecord tests if all fields of the record are empty.
+func emptyRecord(record []string) bool {
+	for _, field := range record {
+		if len(field) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Columns implements the Source.Columns().
 func (c *CSV) Columns() []types.ColumnSelector {
 	return c.columns
@@ -226,3 +535,54 @@ func (c *CSV) Columns() []types.ColumnSelector {
 func (c *CSV) Get() ([]types.Row, error) {
 	return c.rows, nil
 }
+
+// WriteCSV writes the source as CSV data into the writer. The filter
+// specifies the CSV output options. The options comma, noheaders, and
+// null have the same meaning as in NewCSV so that the output can be
+// read back with the same filter.
+func WriteCSV(w io.Writer, source types.Source, filter string) error {
+	opts, err := parseCSVOptions(filter)
+	if err != nil {
+		return err
+	}
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma
+
+	if opts.headers {
+		var record []string
+		for _, col := range source.Columns() {
+			if len(col.As) > 0 {
+				record = append(record, col.As)
+			} else {
+				record = append(record, col.Name.Column)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		var record []string
+		for _, col := range row {
+			if _, ok := col.(types.NullColumn); ok {
+				if opts.null != nil {
+					record = append(record, *opts.null)
+				} else {
+					record = append(record, "")
+				}
+			} else {
+				record = append(record, col.String())
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -7,6 +7,8 @@
 package data
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -82,3 +84,442 @@ func TestCSVOptions(t *testing.T) {
 	}
 	tab.Print(os.Stdout)
 }
+
+func TestCSVNegativeIndex(t *testing.T) {
+	name := "test_ragged.csv"
+	source, err := New([]string{name}, "noheaders", []types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "0",
+			},
+			As: "Year",
+		},
+		{
+			Name: types.Reference{
+				Column: "-1",
+			},
+			As: "Last",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	expected := [][]string{
+		{"2019", "a"},
+		{"2020", "c"},
+		{"2021", "d"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("%s: row %d column %d: got %s, expected %s",
+					name, i, j, col, expected[i][j])
+			}
+		}
+	}
+}
+
+func TestCSVRagged(t *testing.T) {
+	name := "test_ragged_headers.csv"
+	source, err := New([]string{name}, "ragged", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	expected := [][]string{
+		{"a", "1", "kg"},
+		{"b", "2", "NULL"},
+		{"c", "3", "g"},
+		{"d", "NULL", "NULL"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		if len(row) != len(expected[i]) {
+			t.Fatalf("%s: row %d: got %d columns, expected %d",
+				name, i, len(row), len(expected[i]))
+		}
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("%s: row %d column %d: got %s, expected %s",
+					name, i, j, col, expected[i][j])
+			}
+		}
+	}
+	if source.Columns()[1].Type != types.Int {
+		t.Errorf("%s: unexpected column type: %s", name,
+			source.Columns()[1].Type)
+	}
+}
+
+func TestCSVColumnOrder(t *testing.T) {
+	name := "test_ragged_headers.csv"
+	source, err := New([]string{name}, "ragged", []types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Unit",
+			},
+		},
+		{
+			Name: types.Reference{
+				Column: "Name",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	expected := []string{"Name", "Count", "Unit"}
+	columns := source.Columns()
+	if len(columns) != len(expected) {
+		t.Fatalf("%s: got %d columns, expected %d", name, len(columns),
+			len(expected))
+	}
+	for i, col := range columns {
+		if col.Name.Column != expected[i] {
+			t.Errorf("%s: column %d: got %s, expected %s", name, i,
+				col.Name.Column, expected[i])
+		}
+	}
+}
+
+func TestCSVDuplicateHeaders(t *testing.T) {
+	name := "test_duplicate_headers.csv"
+	source, err := New([]string{name}, "", []types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Value",
+			},
+		},
+		{
+			Name: types.Reference{
+				Column: "Value_3",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	headers := []string{"Name", "Value", "Value_3", "Value_2"}
+	columns := source.Columns()
+	if len(columns) != len(headers) {
+		t.Fatalf("%s: got %d columns, expected %d", name, len(columns),
+			len(headers))
+	}
+	for i, col := range columns {
+		if col.Name.Column != headers[i] {
+			t.Errorf("%s: column %d: got %s, expected %s", name, i,
+				col.Name.Column, headers[i])
+		}
+	}
+	expected := [][]string{
+		{"a", "1", "2", "3"},
+		{"b", "4", "5", "6"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("%s: row %d column %d: got %s, expected %s",
+					name, i, j, col, expected[i][j])
+			}
+		}
+	}
+}
+
+func TestCSVUnitsRow(t *testing.T) {
+	name := "test_units.csv"
+	source, err := New([]string{name}, "units-row", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%s: got %d rows, expected 2", name, len(rows))
+	}
+	expected := []struct {
+		name    string
+		t       types.Type
+		comment string
+	}{
+		{"Name", types.String, ""},
+		{"Weight", types.Float, "kg"},
+		{"Height", types.Int, "cm"},
+	}
+	columns := source.Columns()
+	if len(columns) != len(expected) {
+		t.Fatalf("%s: got %d columns, expected %d", name, len(columns),
+			len(expected))
+	}
+	for i, col := range columns {
+		e := expected[i]
+		if col.Name.Column != e.name || col.Type != e.t ||
+			col.Comment != e.comment {
+			t.Errorf("%s: column %d: got %s %s %q, expected %s %s %q",
+				name, i, col.Name.Column, col.Type, col.Comment,
+				e.name, e.t, e.comment)
+		}
+	}
+
+	_, err = New([]string{name}, "noheaders units-row", nil)
+	if err == nil {
+		t.Errorf("units-row without headers succeeded")
+	}
+}
+
+func TestCSVHeaderRow(t *testing.T) {
+	name := "test_banner.csv"
+	source, err := New([]string{name}, "header-row=2", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	expected := [][]string{
+		{"North", "1200", "10"},
+		{"South", "800", "7"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("%s: row %d column %d: got %q, expected %q",
+					name, i, j, col.String(), expected[i][j])
+			}
+		}
+	}
+	columns := source.Columns()
+	if len(columns) != 3 || columns[0].Name.Column != "Region" {
+		t.Errorf("%s: unexpected columns: %v", name, columns)
+	}
+
+	for _, filter := range []string{
+		"header-row=1", "header-row=-1", "header-row=2 skip=1",
+		"header-row=2 noheaders",
+	} {
+		_, err = New([]string{name}, filter, nil)
+		if err == nil {
+			t.Errorf("%s: filter '%s' succeeded", name, filter)
+		}
+	}
+}
+
+func TestCSVSkipEmptyTrim(t *testing.T) {
+	name := "test_padded.csv"
+	source, err := New([]string{name}, "trim-cells skip-empty-rows", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	expected := [][]string{
+		{"a", "1"},
+		{"b", "2"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("%s: row %d column %d: got %q, expected %q",
+					name, i, j, col.String(), expected[i][j])
+			}
+		}
+	}
+	columns := source.Columns()
+	if columns[1].Name.Column != "Count" || columns[1].Type != types.Int {
+		t.Errorf("%s: got column %s %s, expected Count %s", name,
+			columns[1].Name.Column, columns[1].Type, types.Int)
+	}
+
+	// Without the options, the padded cells and empty rows remain.
+	source, err = New([]string{name}, "", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err = source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	if len(rows) != 4 {
+		t.Errorf("%s: got %d rows, expected 4", name, len(rows))
+	}
+}
+
+func TestCSVDecimal(t *testing.T) {
+	name := "test_decimal.csv"
+	source, err := New([]string{name}, "comma=; decimal=, grouping=.", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	columns := source.Columns()
+	if columns[1].Type != types.Float {
+		t.Errorf("%s: Price: got %s, expected %s", name, columns[1].Type,
+			types.Float)
+	}
+	if columns[2].Type != types.Int {
+		t.Errorf("%s: Count: got %s, expected %s", name, columns[2].Type,
+			types.Int)
+	}
+	expected := []struct {
+		price   float64
+		count   int64
+		date    string
+		version string
+	}{
+		{3.14, 1000, "15.03.2021", "1.2.3"},
+		{1234.5, 2, "01.12.2020", "10.0.1"},
+		{0.5, 12, "31.01.2021", "2.10.0"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+			len(expected))
+	}
+	for i, row := range rows {
+		price, err := row[1].Float()
+		if err != nil || price != types.FloatValue(expected[i].price) {
+			t.Errorf("%s: row %d: Price %v (%v), expected %v", name, i,
+				price, err, expected[i].price)
+		}
+		count, err := row[2].Int()
+		if err != nil || count != types.IntValue(expected[i].count) {
+			t.Errorf("%s: row %d: Count %v (%v), expected %v", name, i,
+				count, err, expected[i].count)
+		}
+		if row[3].String() != expected[i].date {
+			t.Errorf("%s: row %d: Date %v, expected %v", name, i,
+				row[3], expected[i].date)
+		}
+		if row[4].String() != expected[i].version {
+			t.Errorf("%s: row %d: Version %v, expected %v", name, i,
+				row[4], expected[i].version)
+		}
+	}
+	if columns[3].Type != types.String {
+		t.Errorf("%s: Date: got %s, expected %s", name, columns[3].Type,
+			types.String)
+	}
+
+	_, err = New([]string{name}, "comma=; grouping=.", nil)
+	if err == nil {
+		t.Errorf("grouping equal to decimal separator succeeded")
+	}
+}
+
+var csvCharsetTests = []struct {
+	name   string
+	filter string
+	header string
+	v      [][]string
+}{
+	{
+		name:   "test_bom.csv",
+		header: "Year",
+		v: [][]string{
+			{"2020", "1"},
+			{"2021", "2"},
+		},
+	},
+	{
+		name:   "test_latin1.csv",
+		filter: "charset=latin1",
+		header: "Name",
+		v: [][]string{
+			{"Källe", "Åbo"},
+		},
+	},
+	{
+		name:   "test_utf16.csv",
+		filter: "charset=utf-16",
+		header: "Name",
+		v: [][]string{
+			{"Källe", "Åbo"},
+		},
+	},
+}
+
+func TestCSVCharset(t *testing.T) {
+	for _, test := range csvCharsetTests {
+		source, err := New([]string{test.name}, test.filter, nil)
+		if err != nil {
+			t.Fatalf("%s: NewCSV failed: %s", test.name, err)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: csv.Get() failed: %s", test.name, err)
+		}
+		header := source.Columns()[0].Name.Column
+		if header != test.header {
+			t.Errorf("%s: got header %q, expected %q", test.name, header,
+				test.header)
+		}
+		if len(rows) != len(test.v) {
+			t.Fatalf("%s: got %d rows, expected %d", test.name, len(rows),
+				len(test.v))
+		}
+		for i, row := range rows {
+			for j, col := range row {
+				if col.String() != test.v[i][j] {
+					t.Errorf("%s: row %d column %d: got %s, expected %s",
+						test.name, i, j, col, test.v[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestCSVWrite(t *testing.T) {
+	name := "test_write.csv"
+	source, err := New([]string{name}, "null=-", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	var buf bytes.Buffer
+	err = WriteCSV(&buf, source, "null=-")
+	if err != nil {
+		t.Fatalf("WriteCSV failed: %s", err)
+	}
+	expected, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(expected) {
+		t.Errorf("WriteCSV: got\n%s\nexpected\n%s\n", buf.String(), expected)
+	}
+}
@@ -8,7 +8,9 @@ package data
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -75,24 +77,56 @@ func processHTML(in io.ReadCloser, rows []types.Row, filter string,
 		return nil, err
 	}
 
-	doc.Find(filter).Each(func(i int, s *goquery.Selection) {
+	opts, filter := parseHTMLOptions(filter)
+	elements, nth, filter, err := parseHTMLFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Selection
+	if nth > 0 {
+		matches := doc.Find(elements)
+		if nth > matches.Length() {
+			return nil, fmt.Errorf("html: %s:nth=%d: only %d elements found",
+				elements, nth, matches.Length())
+		}
+		root = matches.Eq(nth - 1)
+	}
+
+	root.Find(filter).Each(func(i int, s *goquery.Selection) {
 		var row types.Row
-		for i, col := range columns {
+		empty := true
+		for _, col := range columns {
 			sel := s.Find(col.Name.Column)
 			switch sel.Length() {
 			case 0:
 				row = append(row, types.StringColumn(""))
 
 			case 1:
-				row = append(row,
-					types.StringColumn(strings.TrimSpace(sel.Text())))
+				text := strings.TrimSpace(sel.Text())
+				if len(text) > 0 {
+					empty = false
+				}
+				row = append(row, types.StringColumn(text))
 
 			default:
-				strings := sel.Map(func(i int, s *goquery.Selection) string {
+				texts := sel.Map(func(i int, s *goquery.Selection) string {
+					if opts.trimCells {
+						return strings.TrimSpace(s.Text())
+					}
 					return s.Text()
 				})
-				row = append(row, types.StringsColumn(strings))
+				for _, text := range texts {
+					if len(text) > 0 {
+						empty = false
+					}
+				}
+				row = append(row, types.StringsColumn(texts))
 			}
+		}
+		if opts.skipEmptyRows && empty {
+			return
+		}
+		for i := range columns {
 			columns[i].ResolveString(row[i].String())
 		}
 		rows = append(rows, row)
@@ -101,6 +135,69 @@ func processHTML(in io.ReadCloser, rows []types.Row, filter string,
 	return rows, nil
 }
 
+// htmlOptions define the HTML processing options.
+type htmlOptions struct {
+	trimCells     bool
+	skipEmptyRows bool
+}
+
+// parseHTMLOptions parses the option flags from the filter
+// string. The flags are whitespace separated words of the filter and
+// they are removed from the returned filter.
+func parseHTMLOptions(filter string) (*htmlOptions, string) {
+	opts := new(htmlOptions)
+	var rest []string
+	var found bool
+	for _, field := range strings.Fields(filter) {
+		switch field {
+		case "trim-cells":
+			opts.trimCells = true
+			found = true
+
+		case "skip-empty-rows":
+			opts.skipEmptyRows = true
+			found = true
+
+		default:
+			rest = append(rest, field)
+		}
+	}
+	if !found {
+		return opts, filter
+	}
+	return opts, strings.Join(rest, " ")
+}
+
+// parseHTMLFilter parses the nth element selection from the filter
+// string. The filter '<elements>:nth=<n> [<rows>]' selects the row
+// elements <rows> from the nth (1-based) element matching the
+// <elements> selector. The default <rows> selector "tr:has(td)"
+// selects the table rows with data cells. Filters without the nth
+// selection return nth 0 and the filter unmodified.
+func parseHTMLFilter(filter string) (string, int, string, error) {
+	idx := strings.Index(filter, ":nth=")
+	if idx < 0 {
+		return "", 0, filter, nil
+	}
+	elements := strings.TrimSpace(filter[:idx])
+	rest := filter[idx+5:]
+
+	var rows string
+	end := strings.IndexAny(rest, " \t\n")
+	if end >= 0 {
+		rows = strings.TrimSpace(rest[end:])
+		rest = rest[:end]
+	}
+	if len(rows) == 0 {
+		rows = "tr:has(td)"
+	}
+	nth, err := strconv.Atoi(rest)
+	if err != nil || nth < 1 || len(elements) == 0 {
+		return "", 0, "", fmt.Errorf("html: invalid nth selection: %s", filter)
+	}
+	return elements, nth, rows, nil
+}
+
 // Columns implements the Source.Columns().
 func (html *HTML) Columns() []types.ColumnSelector {
 	return html.columns
@@ -45,3 +45,125 @@ func TestHTMLCorrect(t *testing.T) {
 	}
 	tab.Print(os.Stdout)
 }
+
+func TestHTMLNthTable(t *testing.T) {
+	source, err := New([]string{"test_tables.html"}, "table:nth=2",
+		[]types.ColumnSelector{
+			{
+				Name: types.Reference{
+					Column: ".name",
+				},
+				As: "Name",
+			},
+			{
+				Name: types.Reference{
+					Column: ".value",
+				},
+				As: "Value",
+			},
+		})
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("html.Get() failed: %s", err)
+	}
+	expected := [][]string{
+		{"C3", "1.25"},
+		{"D4", "2.50"},
+		{"E5", "3.75"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("row %d column %d: got %q, expected %q",
+					i, j, col.String(), expected[i][j])
+			}
+		}
+	}
+
+	_, err = New([]string{"test_tables.html"}, "table:nth=3",
+		[]types.ColumnSelector{
+			{
+				Name: types.Reference{
+					Column: ".name",
+				},
+			},
+		})
+	if err == nil {
+		t.Errorf("table:nth=3 succeeded")
+	}
+}
+
+func TestHTMLCellOptions(t *testing.T) {
+	columns := func() []types.ColumnSelector {
+		return []types.ColumnSelector{
+			{
+				Name: types.Reference{
+					Column: ".name",
+				},
+			},
+			{
+				Name: types.Reference{
+					Column: "i",
+				},
+			},
+		}
+	}
+	tests := []struct {
+		filter   string
+		expected [][]string
+	}{
+		{
+			filter: "tr:has(td)",
+			expected: [][]string{
+				{"A1", "[ x   y ]"},
+				{"", ""},
+				{"", "[  ]"},
+				{"B2", "z"},
+			},
+		},
+		{
+			filter: "tr:has(td) skip-empty-rows",
+			expected: [][]string{
+				{"A1", "[ x   y ]"},
+				{"", "[  ]"},
+				{"B2", "z"},
+			},
+		},
+		{
+			filter: "trim-cells tr:has(td) skip-empty-rows",
+			expected: [][]string{
+				{"A1", "[x y]"},
+				{"B2", "z"},
+			},
+		},
+	}
+	for _, test := range tests {
+		source, err := New([]string{"test_cells.html"}, test.filter,
+			columns())
+		if err != nil {
+			t.Fatalf("%s: New failed: %s", test.filter, err)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: html.Get() failed: %s", test.filter, err)
+		}
+		if len(rows) != len(test.expected) {
+			t.Fatalf("%s: got %d rows, expected %d", test.filter,
+				len(rows), len(test.expected))
+		}
+		for i, row := range rows {
+			for j, col := range row {
+				if col.String() != test.expected[i][j] {
+					t.Errorf("%s: row %d column %d: got %q, expected %q",
+						test.filter, i, j, col.String(), test.expected[i][j])
+				}
+			}
+		}
+	}
+}
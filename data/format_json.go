@@ -7,11 +7,13 @@
 package data
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"sort"
 	"strings"
 
@@ -131,3 +133,97 @@ func (src *JSON) Columns() []types.ColumnSelector {
 func (src *JSON) Get() ([]types.Row, error) {
 	return src.rows, nil
 }
+
+// WriteJSON writes the source as JSON data into the writer. The rows
+// are written as an array of objects which map the column names to
+// the column values. The column values are encoded by the column
+// types and NULL values are encoded as null.
+func WriteJSON(w io.Writer, source types.Source, filter string) error {
+	if len(strings.TrimSpace(filter)) > 0 {
+		return fmt.Errorf("json: unknown output options: %s", filter)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	columns := source.Columns()
+	var names [][]byte
+	for _, col := range columns {
+		name := col.As
+		if len(name) == 0 {
+			name = col.Name.Column
+		}
+		data, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		names = append(names, data)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, col := range row {
+			if j >= len(names) {
+				break
+			}
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(names[j])
+			buf.WriteByte(':')
+			data, err := json.Marshal(jsonValue(columns[j].Type, col))
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString("]\n")
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// jsonValue returns the JSON value of the column of type t.
+func jsonValue(t types.Type, col types.Column) interface{} {
+	if _, ok := col.(types.NullColumn); ok {
+		return nil
+	}
+	var v types.Value
+	var err error
+	switch t {
+	case types.Bool:
+		v, err = col.Bool()
+	case types.Int:
+		v, err = col.Int()
+	case types.Float:
+		v, err = col.Float()
+	default:
+		return col.String()
+	}
+	if err != nil {
+		return col.String()
+	}
+	switch val := v.(type) {
+	case types.NullValue:
+		return nil
+	case types.BoolValue:
+		return bool(val)
+	case types.IntValue:
+		return int64(val)
+	case types.FloatValue:
+		f := float64(val)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return col.String()
+		}
+		return f
+	default:
+		return col.String()
+	}
+}
@@ -0,0 +1,50 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"github.com/markkurossi/iql/types"
+)
+
+// Rows implements a data source from in-memory rows.
+type Rows struct {
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// NewRows creates a new data source from the argument columns and
+// rows. The column types are resolved from the row values.
+func NewRows(columns []types.ColumnSelector, rows []types.Row) types.Source {
+	resolved := make([]types.ColumnSelector, len(columns))
+	copy(resolved, columns)
+
+	for _, row := range rows {
+		for i, col := range row {
+			if i >= len(resolved) {
+				break
+			}
+			if _, ok := col.(types.NullColumn); ok {
+				continue
+			}
+			resolved[i].ResolveString(col.String())
+		}
+	}
+	return &Rows{
+		columns: resolved,
+		rows:    rows,
+	}
+}
+
+// Columns implements the Source.Columns().
+func (src *Rows) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *Rows) Get() ([]types.Row, error) {
+	return src.rows, nil
+}
@@ -0,0 +1,150 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// MaxSequenceLength specifies the maximum number of rows a sequence
+// can generate.
+var MaxSequenceLength = 10 * 1024 * 1024
+
+// Sequence implements a data source that generates a series of
+// values from start to stop (inclusive), incremented by step. The
+// sequence values are integers or dates. The date sequences are
+// incremented by interval steps.
+type Sequence struct {
+	start     types.Value
+	stop      types.Value
+	step      types.Value
+	columns   []types.ColumnSelector
+	rows      []types.Row
+	evaluated bool
+}
+
+// NewSequence creates a new sequence data source. The sequence has
+// one column named Value.
+func NewSequence(start, stop, step types.Value) (types.Source, error) {
+	var t types.Type
+
+	switch step.(type) {
+	case types.IntValue:
+		t = types.Int
+
+	case types.IntervalValue:
+		t = types.Date
+
+	default:
+		return nil, fmt.Errorf("SEQUENCE: invalid step: %s", step)
+	}
+	return &Sequence{
+		start: start,
+		stop:  stop,
+		step:  step,
+		columns: []types.ColumnSelector{
+			{
+				Name: types.Reference{
+					Column: "Value",
+				},
+				Type: t,
+			},
+		},
+	}, nil
+}
+
+// Columns implements the Source.Columns().
+func (s *Sequence) Columns() []types.ColumnSelector {
+	return s.columns
+}
+
+// Get implements the Source.Get().
+func (s *Sequence) Get() ([]types.Row, error) {
+	if s.evaluated {
+		return s.rows, nil
+	}
+	var err error
+	switch step := s.step.(type) {
+	case types.IntValue:
+		err = s.intSequence(int64(step))
+
+	case types.IntervalValue:
+		err = s.dateSequence(step)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.evaluated = true
+
+	return s.rows, nil
+}
+
+func (s *Sequence) intSequence(step int64) error {
+	start, err := s.start.Int()
+	if err != nil {
+		return err
+	}
+	stop, err := s.stop.Int()
+	if err != nil {
+		return err
+	}
+	if step == 0 {
+		return fmt.Errorf("SEQUENCE: zero step")
+	}
+	for i := start; (step > 0 && i <= stop) || (step < 0 && i >= stop); {
+		if err := s.add(types.IntValue(i)); err != nil {
+			return err
+		}
+		next := i + step
+		if (step > 0 && next < i) || (step < 0 && next > i) {
+			// Overflow.
+			break
+		}
+		i = next
+	}
+	return nil
+}
+
+func (s *Sequence) dateSequence(step types.IntervalValue) error {
+	start, err := s.start.Date()
+	if err != nil {
+		return err
+	}
+	stop, err := s.stop.Date()
+	if err != nil {
+		return err
+	}
+	next := step.AddTo(start)
+	if next.Equal(start) {
+		return fmt.Errorf("SEQUENCE: zero step: %s", step)
+	}
+	ascending := next.After(start)
+
+	// Add the steps to the start date so that the end of month
+	// clamping does not accumulate: the month steps from January 31
+	// are February 29 (or 28) and March 31.
+	var sum types.IntervalValue
+	for d := start; (ascending && !d.After(stop)) ||
+		(!ascending && !d.Before(stop)); d = sum.AddTo(start) {
+
+		if err := s.add(types.DateValue(d)); err != nil {
+			return err
+		}
+		sum = sum.Add(step)
+	}
+	return nil
+}
+
+func (s *Sequence) add(v types.Value) error {
+	if len(s.rows) >= MaxSequenceLength {
+		return fmt.Errorf("SEQUENCE: too many rows: %d", len(s.rows))
+	}
+	s.rows = append(s.rows, types.Row{types.NewValueColumn(v)})
+	return nil
+}
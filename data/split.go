@@ -0,0 +1,54 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"github.com/markkurossi/iql/types"
+)
+
+// Split implements a data source that returns one row for each
+// segment of a split string. The source has one column named Value
+// or the column name given to NewSplitColumn.
+type Split struct {
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// NewSplit creates a new data source from the string segments.
+func NewSplit(segments []string) types.Source {
+	return NewSplitColumn("Value", segments)
+}
+
+// NewSplitColumn creates a new data source from the string segments
+// with the column name.
+func NewSplitColumn(column string, segments []string) types.Source {
+	var rows []types.Row
+	for _, segment := range segments {
+		rows = append(rows, types.Row{types.StringColumn(segment)})
+	}
+	return &Split{
+		columns: []types.ColumnSelector{
+			{
+				Name: types.Reference{
+					Column: column,
+				},
+				Type: types.String,
+			},
+		},
+		rows: rows,
+	}
+}
+
+// Columns implements the Source.Columns().
+func (s *Split) Columns() []types.ColumnSelector {
+	return s.columns
+}
+
+// Get implements the Source.Get().
+func (s *Split) Get() ([]types.Row, error) {
+	return s.rows, nil
+}
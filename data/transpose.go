@@ -0,0 +1,88 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// Transpose implements a data source that transposes its input
+// source. The input columns become rows where the first column holds
+// the input column name and the following columns hold the column
+// values of the input rows.
+type Transpose struct {
+	source    types.Source
+	columns   []types.ColumnSelector
+	rows      []types.Row
+	evaluated bool
+}
+
+// NewTranspose creates a new transposing data source for the
+// argument source.
+func NewTranspose(source types.Source) types.Source {
+	return &Transpose{
+		source: source,
+	}
+}
+
+// Columns implements the Source.Columns().
+func (t *Transpose) Columns() []types.ColumnSelector {
+	if !t.evaluated {
+		t.Get()
+	}
+	return t.columns
+}
+
+// Get implements the Source.Get().
+func (t *Transpose) Get() ([]types.Row, error) {
+	if t.evaluated {
+		return t.rows, nil
+	}
+	rows, err := t.source.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Column",
+			},
+		},
+	}
+	for i := range rows {
+		name := "Value"
+		if len(rows) > 1 {
+			name = fmt.Sprintf("Value%d", i+1)
+		}
+		columns = append(columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: name,
+			},
+		})
+	}
+
+	var result []types.Row
+	for idx, col := range t.source.Columns() {
+		row := types.Row{
+			types.StringColumn(col.String()),
+		}
+		for _, r := range rows {
+			row = append(row, r[idx])
+		}
+		result = append(result, row)
+	}
+
+	transposed := NewRows(columns, result)
+	t.columns = transposed.Columns()
+	t.rows = result
+	t.evaluated = true
+
+	return t.rows, nil
+}
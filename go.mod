@@ -7,10 +7,10 @@ require (
 	github.com/markkurossi/jsonq v0.0.0-20210109084605-ee95c910c453
 	github.com/markkurossi/tabulate v0.0.0-20230223130100-d4965869b123
 	github.com/markkurossi/vt100 v0.0.0-20210316192307-a09f3f88c5ec
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
 	golang.org/x/net v0.24.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
@@ -10,14 +10,23 @@ import (
 	"encoding/base64"
 	"fmt"
 	"math"
+	"math/bits"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/markkurossi/iql/types"
 	"github.com/markkurossi/vt100"
+	"golang.org/x/text/width"
 )
 
+// MaxStringLength specifies the maximum length of the strings that
+// the string constructor functions, like REPLICATE and SPACE, can
+// create.
+var MaxStringLength = 1024 * 1024
+
 var builtIns = []Function{
 	// Aggregate functions.
 	{
@@ -33,6 +42,31 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Nulls:        count,
+		Distinct:     countDistinct,
+	},
+	{
+		Name:         "COUNT_NULL",
+		Impl:         builtInCountNull,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentTrue,
+	},
+	{
+		Name:         "FIRST",
+		Impl:         builtInFirst,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentTrue,
+		Nulls:        first,
+	},
+	{
+		Name:         "LAST",
+		Impl:         builtInLast,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentTrue,
+		Nulls:        last,
 	},
 	{
 		Name:         "MAX",
@@ -48,6 +82,13 @@ var builtIns = []Function{
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
 	},
+	{
+		Name:         "NULL_RATE",
+		Impl:         builtInNullRate,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentTrue,
+	},
 	{
 		Name:         "SUM",
 		Impl:         builtInSum,
@@ -61,22 +102,70 @@ var builtIns = []Function{
 		MinArgs:      2,
 		MaxArgs:      2,
 		IsIdempotent: idempotentArgs,
+		Type:         typeFirstArg,
+	},
+	{
+		Name:         "CHOOSE",
+		Impl:         builtInChoose,
+		MinArgs:      2,
+		MaxArgs:      math.MaxInt32,
+		IsIdempotent: idempotentArgs,
 	},
 
 	// Mathematical function.
+	{
+		Name:         "ABS",
+		Impl:         builtInAbs,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+		Type:         typeNumericArg,
+	},
+	{
+		Name:         "BITNOT",
+		Impl:         builtInBitNot,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
+	{
+		Name:         "CEILING",
+		Impl:         builtInCeiling,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+		Type:         typeNumericArg,
+	},
+	{
+		Name:         "EXP",
+		Impl:         builtInExp,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
+	},
 	{
 		Name:         "FLOOR",
 		Impl:         builtInFloor,
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
+		Type:         typeNumericArg,
+	},
+	{
+		Name:         "FROM_BASE",
+		Impl:         builtInFromBase,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
 	},
 	{
 		Name:         "LOG",
 		Impl:         builtInLog,
 		MinArgs:      1,
-		MaxArgs:      1,
+		MaxArgs:      2,
 		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
 	},
 	{
 		Name:         "LOG10",
@@ -84,9 +173,69 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
+	},
+	{
+		Name:         "POPCOUNT",
+		Impl:         builtInPopCount,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
+	{
+		Name:         "POWER",
+		Impl:         builtInPower,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
+	},
+	{
+		Name:         "ROUND",
+		Impl:         builtInRound,
+		MinArgs:      1,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
+	},
+	{
+		Name:         "SAFE_DIVIDE",
+		Impl:         builtInSafeDivide,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
+	{
+		Name:         "SQRT",
+		Impl:         builtInSqrt,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+		Type:         typeFloat,
+	},
+	{
+		Name:         "TO_BASE",
+		Impl:         builtInToBase,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
 	},
 
 	// String functions.
+	{
+		Name:         "ASCII",
+		Impl:         builtInASCII,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
+	{
+		Name:         "CENTER",
+		Impl:         builtInCenter,
+		MinArgs:      2,
+		MaxArgs:      4,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "CHAR",
 		Impl:         builtInChar,
@@ -101,6 +250,13 @@ var builtIns = []Function{
 		MaxArgs:      3,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "CODEPOINT_AT",
+		Impl:         builtInCodepointAt,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "CONCAT",
 		Impl:         builtInConcat,
@@ -150,6 +306,13 @@ var builtIns = []Function{
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "BYTELEN",
+		Impl:         builtInByteLen,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "LOWER",
 		Impl:         builtInLower,
@@ -161,7 +324,7 @@ var builtIns = []Function{
 		Name:         "LPAD",
 		Impl:         builtInLPad,
 		MinArgs:      2,
-		MaxArgs:      3,
+		MaxArgs:      4,
 		IsIdempotent: idempotentArgs,
 	},
 	{
@@ -185,6 +348,13 @@ var builtIns = []Function{
 		MaxArgs:      2,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "REPEAT",
+		Impl:         builtInReplicate,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "REVERSE",
 		Impl:         builtInReverse,
@@ -199,6 +369,13 @@ var builtIns = []Function{
 		MaxArgs:      2,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "RPAD",
+		Impl:         builtInRPad,
+		MinArgs:      2,
+		MaxArgs:      4,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "RTRIM",
 		Impl:         builtInRTrim,
@@ -248,6 +425,13 @@ var builtIns = []Function{
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "WRAP",
+		Impl:         builtInWrap,
+		MinArgs:      2,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
 
 	// Datetime functions.
 	{
@@ -270,6 +454,24 @@ specifies the units in which the difference is computed:
  - millisecond, ms:  difference in milliseconds
  - microsecond, mcs: difference in microseconds
  - nanosecond, ns:   difference in nanoseconds
+`,
+	},
+	{
+		Name:         "DATE_TRUNC",
+		Impl:         builtInDateTrunc,
+		MinArgs:      2,
+		MaxArgs:      2,
+		FirstBound:   1,
+		IsIdempotent: idempotentArgs,
+		Usage: `
+DATE_TRUNC(part, date)
+DATE_TRUNC truncates the date to the precision specified by part:
+ - year, yy, yyyy:   first day of the year
+ - quarter, qq, q:   first day of the quarter
+ - month, mm, m:     first day of the month
+ - week, wk, ww:     first day of the ISO week (Monday)
+ - day, dd, d:       start of the day
+ - hour, hh:         start of the hour
 `,
 	},
 	{
@@ -279,6 +481,20 @@ specifies the units in which the difference is computed:
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "DAYOFWEEK",
+		Impl:         builtInDayOfWeek,
+		MinArgs:      1,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
+	{
+		Name:         "EOMONTH",
+		Impl:         builtInEOMonth,
+		MinArgs:      1,
+		MaxArgs:      2,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "GETDATE",
 		Impl:         builtInGetDate,
@@ -286,6 +502,13 @@ specifies the units in which the difference is computed:
 		MaxArgs:      0,
 		IsIdempotent: idempotentFalse,
 	},
+	{
+		Name:         "ISOWEEK",
+		Impl:         builtInISOWeek,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "MONTH",
 		Impl:         builtInMonth,
@@ -293,6 +516,20 @@ specifies the units in which the difference is computed:
 		MaxArgs:      1,
 		IsIdempotent: idempotentArgs,
 	},
+	{
+		Name:         "NOW",
+		Impl:         builtInGetDate,
+		MinArgs:      0,
+		MaxArgs:      0,
+		IsIdempotent: idempotentFalse,
+	},
+	{
+		Name:         "WEEKDAY",
+		Impl:         builtInWeekday,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
 	{
 		Name:         "YEAR",
 		Impl:         builtInYear,
@@ -301,6 +538,74 @@ specifies the units in which the difference is computed:
 		IsIdempotent: idempotentArgs,
 	},
 
+	// Type functions.
+	{
+		Name:         "TYPEOF",
+		Impl:         builtInTypeOf,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentArgs,
+	},
+
+	// Row functions.
+	{
+		Name:         "ROWID",
+		Impl:         builtInRowID,
+		MinArgs:      0,
+		MaxArgs:      0,
+		IsIdempotent: idempotentFalse,
+	},
+
+	// Window functions.
+	{
+		Name:         "CUME_DIST",
+		Impl:         builtInCumeDist,
+		MinArgs:      0,
+		MaxArgs:      0,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+	{
+		Name:         "FILL_FORWARD",
+		Impl:         builtInFillForward,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+	{
+		Name:         "NTILE",
+		Impl:         builtInNTile,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+	{
+		Name:         "PERCENT_RANK",
+		Impl:         builtInPercentRank,
+		MinArgs:      0,
+		MaxArgs:      0,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+	{
+		Name:         "ROW_NUMBER",
+		Impl:         builtInRowNumber,
+		MinArgs:      0,
+		MaxArgs:      0,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+	{
+		Name:         "RUNLENGTH",
+		Impl:         builtInRunLength,
+		MinArgs:      1,
+		MaxArgs:      1,
+		IsIdempotent: idempotentFalse,
+		Window:       true,
+	},
+
 	// Visualization functions.
 	{
 		Name:         "HBAR",
@@ -368,6 +673,12 @@ func builtInAvg(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 }
 
 func builtInCount(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return count(args, row, rows, false)
+}
+
+func count(args []Expr, row *Row, rows []*Row, respect bool) (
+	types.Value, error) {
+
 	var count int
 	for _, countRow := range rows {
 		val, err := args[0].Eval(countRow, nil)
@@ -375,15 +686,115 @@ func builtInCount(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 			return nil, err
 		}
 		_, ok := val.(types.NullValue)
-		if !ok {
+		if !ok || respect {
 			count++
 		}
 	}
 	return types.IntValue(count), nil
 }
 
+// countDistinct counts the distinct non-NULL values. The values are
+// distinct with the same rules as the GROUP BY keys.
+func countDistinct(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	seen := make(map[string]bool)
+	var key []byte
+	for _, countRow := range rows {
+		val, err := args[0].Eval(countRow, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := val.(types.NullValue); ok {
+			continue
+		}
+		key = appendGroupKey(key[:0], val)
+		seen[string(key)] = true
+	}
+	return types.IntValue(len(seen)), nil
+}
+
+func builtInCountNull(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	nulls, _, err := countNulls(args, rows)
+	if err != nil {
+		return nil, err
+	}
+	return types.IntValue(nulls), nil
+}
+
+func builtInNullRate(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	nulls, total, err := countNulls(args, rows)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return types.Null, nil
+	}
+	return types.FloatValue(float64(nulls) / float64(total)), nil
+}
+
+// countNulls returns the number of NULL values and the total number
+// of values of the argument expression over the rows.
+func countNulls(args []Expr, rows []*Row) (int, int, error) {
+	var nulls int
+	for _, countRow := range rows {
+		val, err := args[0].Eval(countRow, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := val.(types.NullValue); ok {
+			nulls++
+		}
+	}
+	return nulls, len(rows), nil
+}
+
+func builtInFirst(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return first(args, row, rows, false)
+}
+
+func first(args []Expr, row *Row, rows []*Row, respect bool) (
+	types.Value, error) {
+
+	for _, firstRow := range rows {
+		val, err := args[0].Eval(firstRow, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := val.(types.NullValue)
+		if !ok || respect {
+			return val, nil
+		}
+	}
+	return types.Null, nil
+}
+
+func builtInLast(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return last(args, row, rows, false)
+}
+
+func last(args []Expr, row *Row, rows []*Row, respect bool) (
+	types.Value, error) {
+
+	for i := len(rows) - 1; i >= 0; i-- {
+		val, err := args[0].Eval(rows[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := val.(types.NullValue)
+		if !ok || respect {
+			return val, nil
+		}
+	}
+	return types.Null, nil
+}
+
 func builtInMax(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	seen := make(map[types.Type]bool)
+	var max types.Value
+	argType := staticType(args[0])
 
 	var intMax int64
 	var floatMax float64
@@ -417,8 +828,22 @@ func builtInMax(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 			seen[types.Float] = true
 
 		default:
-			return nil, fmt.Errorf("MAX over %T", val)
+			val, err = dateValue(val, argType)
+			if err != nil {
+				return nil, err
+			}
+			max, err = extremeValue("MAX", max, val, 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if max != nil {
+		if seen[types.Int] || seen[types.Float] {
+			return nil, fmt.Errorf("MAX over %s and numeric values",
+				max.Type())
 		}
+		return max, nil
 	}
 	if seen[types.Float] && seen[types.Int] {
 		var r float64
@@ -437,6 +862,8 @@ func builtInMax(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 
 func builtInMin(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	seen := make(map[types.Type]bool)
+	var min types.Value
+	argType := staticType(args[0])
 
 	var intMin int64
 	var floatMin float64
@@ -470,9 +897,23 @@ func builtInMin(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 			seen[types.Float] = true
 
 		default:
-			return nil, fmt.Errorf("MIN over %T", val)
+			val, err = dateValue(val, argType)
+			if err != nil {
+				return nil, err
+			}
+			min, err = extremeValue("MIN", min, val, -1)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
+	if min != nil {
+		if seen[types.Int] || seen[types.Float] {
+			return nil, fmt.Errorf("MIN over %s and numeric values",
+				min.Type())
+		}
+		return min, nil
+	}
 	if seen[types.Float] && seen[types.Int] {
 		var r float64
 		if float64(intMin) < floatMin {
@@ -488,6 +929,50 @@ func builtInMin(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(intMin), nil
 }
 
+// dateValue converts the string value val of a datetime typed
+// expression into a datetime value. The column references return the
+// datetime column values as strings.
+func dateValue(val types.Value, t types.Type) (types.Value, error) {
+	str, ok := val.(types.StringValue)
+	if !ok || t != types.Date {
+		return val, nil
+	}
+	d, err := str.Date()
+	if err != nil {
+		return nil, err
+	}
+	return types.DateValue(d), nil
+}
+
+// extremeValue returns the extreme of the non-numeric values cur and
+// val. The sign 1 selects the maximum and -1 the minimum value. The
+// values must have the same type.
+func extremeValue(name string, cur, val types.Value, sign int) (
+	types.Value, error) {
+
+	switch val.(type) {
+	case types.BoolValue, types.DateValue, types.StringValue,
+		types.IntervalValue:
+	default:
+		return nil, fmt.Errorf("%s over %T", name, val)
+	}
+	if cur == nil {
+		return val, nil
+	}
+	if cur.Type() != val.Type() {
+		return nil, fmt.Errorf("%s over %s and %s values", name, cur.Type(),
+			val.Type())
+	}
+	cmp, err := types.Compare(val, cur)
+	if err != nil {
+		return nil, err
+	}
+	if cmp*sign > 0 {
+		return val, nil
+	}
+	return cur, nil
+}
+
 func builtInSum(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	seen := make(map[types.Type]bool)
 
@@ -549,6 +1034,90 @@ func builtInNullIf(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return val, nil
 }
 
+func builtInChoose(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	if val == types.Null {
+		return types.Null, nil
+	}
+	iv, ok := val.(types.IntValue)
+	if !ok {
+		return nil, fmt.Errorf("CHOOSE: invalid index: %v", val)
+	}
+	// Index is 1-based. Only the selected value is evaluated.
+	if iv < 1 || int64(iv) >= int64(len(args)) {
+		return types.Null, nil
+	}
+	return args[iv].Eval(row, rows)
+}
+
+func builtInAbs(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case types.IntValue:
+		if v == math.MinInt64 {
+			return nil, fmt.Errorf("ABS: integer overflow: %d", v)
+		}
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+
+	case types.FloatValue:
+		return types.FloatValue(math.Abs(float64(v))), nil
+
+	default:
+		return types.Null, nil
+	}
+}
+
+func builtInBitNot(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := val.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	i64, err := val.Int()
+	if err != nil {
+		return nil, err
+	}
+	return types.IntValue(^i64), nil
+}
+
+func builtInCeiling(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case types.IntValue:
+		return val, nil
+
+	case types.FloatValue:
+		return types.FloatValue(math.Ceil(float64(v))), nil
+
+	default:
+		return types.Null, nil
+	}
+}
+
+func builtInExp(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	f64, ok, err := evalFloat(args[0], row, rows)
+	if err != nil || !ok {
+		return types.Null, err
+	}
+	return types.FloatValue(math.Exp(f64)), nil
+}
+
 func builtInFloor(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	val, err := args[0].Eval(row, rows)
 	if err != nil {
@@ -566,42 +1135,303 @@ func builtInFloor(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	}
 }
 
+func builtInFromBase(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	base, err := evalBase("FROM_BASE", args[1], row, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := val.(types.NullValue)
+	if ok || base == 0 {
+		return types.Null, nil
+	}
+	i64, err := strconv.ParseInt(strings.TrimSpace(val.String()), base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("FROM_BASE: invalid base %d number: %s",
+			base, val)
+	}
+	return types.IntValue(i64), nil
+}
+
 func builtInLog(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	f64, ok, err := evalFloat(args[0], row, rows)
+	if err != nil || !ok || f64 <= 0 {
+		return types.Null, err
+	}
+	if len(args) == 1 {
+		return types.FloatValue(math.Log(f64)), nil
+	}
+	base, ok, err := evalFloat(args[1], row, rows)
+	if err != nil || !ok || base <= 0 || base == 1 {
+		return types.Null, err
+	}
+	switch base {
+	case 2:
+		return types.FloatValue(math.Log2(f64)), nil
+	case 10:
+		return types.FloatValue(math.Log10(f64)), nil
+	default:
+		return types.FloatValue(math.Log(f64) / math.Log(base)), nil
+	}
+}
+
+func builtInLog10(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	f64, ok, err := evalFloat(args[0], row, rows)
+	if err != nil || !ok || f64 <= 0 {
+		return types.Null, err
+	}
+	return types.FloatValue(math.Log10(f64)), nil
+}
+
+func builtInPopCount(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
 	val, err := args[0].Eval(row, rows)
 	if err != nil {
 		return nil, err
 	}
-	var f64 float64
+	if _, ok := val.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	i64, err := val.Int()
+	if err != nil {
+		return nil, err
+	}
+	return types.IntValue(bits.OnesCount64(uint64(i64))), nil
+}
+
+func builtInPower(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	base, ok, err := evalFloat(args[0], row, rows)
+	if err != nil || !ok {
+		return types.Null, err
+	}
+	exp, ok, err := evalFloat(args[1], row, rows)
+	if err != nil || !ok {
+		return types.Null, err
+	}
+	return types.FloatValue(math.Pow(base, exp)), nil
+}
+
+func builtInRound(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	var length int64
+	if len(args) > 1 {
+		l, err := args[1].Eval(row, rows)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := l.(types.NullValue); ok {
+			return types.Null, nil
+		}
+		if _, ok := l.(types.IntValue); !ok {
+			return nil, fmt.Errorf("ROUND: invalid length: %s", l)
+		}
+		length, err = l.Int()
+		if err != nil {
+			return nil, err
+		}
+	}
 	switch v := val.(type) {
 	case types.IntValue:
-		f64 = float64(v)
+		return types.FloatValue(roundHalfAway(float64(v), length)), nil
 
 	case types.FloatValue:
-		f64 = float64(v)
+		return types.FloatValue(roundHalfAway(float64(v), length)), nil
 
 	default:
 		return types.Null, nil
 	}
-	return types.FloatValue(math.Log(f64)), nil
 }
 
-func builtInLog10(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+// roundHalfAway rounds f to length decimal places, rounding halfway
+// cases away from zero. The negative length rounds to the left of the
+// decimal point. The rounding is done on the shortest decimal
+// representation of f so that, for example, 2.345 rounds to 2.35
+// even though its binary value is slightly below 2.345.
+func roundHalfAway(f float64, length int64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	// The value is 0.digits * 10^(exp+1).
+	str := strconv.FormatFloat(math.Abs(f), 'e', -1, 64)
+	epos := strings.IndexByte(str, 'e')
+	exp, err := strconv.Atoi(str[epos+1:])
+	if err != nil {
+		return f
+	}
+	digits := strings.Replace(str[:epos], ".", "", 1)
+
+	keep := int64(exp) + 1 + length
+	if keep >= int64(len(digits)) {
+		return f
+	}
+	var m uint64
+	if keep > 0 {
+		m, err = strconv.ParseUint(digits[:keep], 10, 64)
+		if err != nil {
+			return f
+		}
+	} else {
+		keep = 0
+	}
+	if int64(exp)+1+length >= 0 && digits[keep] >= '5' {
+		m++
+	}
+	result, err := strconv.ParseFloat(
+		fmt.Sprintf("%de%d", m, int64(exp)+1-keep), 64)
+	if err != nil {
+		return f
+	}
+	if f < 0 && result != 0 {
+		return -result
+	}
+	return result
+}
+
+func builtInSafeDivide(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	left, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	right, err := args[1].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, lNull := left.(types.NullValue)
+	_, rNull := right.(types.NullValue)
+	if lNull || rNull {
+		return types.Null, nil
+	}
+	opType, err := superType(left.Type(), right.Type(), "SAFE_DIVIDE")
+	if err != nil {
+		return nil, err
+	}
+	switch opType {
+	case types.Int:
+		l, err := left.Int()
+		if err != nil {
+			return nil, err
+		}
+		r, err := right.Int()
+		if err != nil {
+			return nil, err
+		}
+		if r == 0 {
+			return types.Null, nil
+		}
+		return types.IntValue(l / r), nil
+
+	case types.Float:
+		l, err := left.Float()
+		if err != nil {
+			return nil, err
+		}
+		r, err := right.Float()
+		if err != nil {
+			return nil, err
+		}
+		if r == 0 {
+			return types.Null, nil
+		}
+		return types.FloatValue(l / r), nil
+
+	default:
+		return nil, fmt.Errorf("SAFE_DIVIDE: invalid types: %s, %s",
+			left.Type(), right.Type())
+	}
+}
+
+func builtInSqrt(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	f64, ok, err := evalFloat(args[0], row, rows)
+	if err != nil || !ok || f64 < 0 {
+		return types.Null, err
+	}
+	return types.FloatValue(math.Sqrt(f64)), nil
+}
+
+func builtInToBase(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	base, err := evalBase("TO_BASE", args[1], row, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := val.(types.NullValue)
+	if ok || base == 0 {
+		return types.Null, nil
+	}
+	i64, err := val.Int()
+	if err != nil {
+		return nil, err
+	}
+	return types.StringValue(strconv.FormatInt(i64, base)), nil
+}
+
+// evalFloat evaluates the argument as a floating point number. The
+// function returns false if the argument value is NULL.
+func evalFloat(arg Expr, row *Row, rows []*Row) (float64, bool, error) {
+	val, err := arg.Eval(row, rows)
+	if err != nil {
+		return 0, false, err
+	}
+	if _, ok := val.(types.NullValue); ok {
+		return 0, false, nil
+	}
+	f64, err := val.Float()
+	if err != nil {
+		return 0, false, err
+	}
+	return f64, true, nil
+}
+
+// evalBase evaluates the numeric base argument of the TO_BASE and
+// FROM_BASE functions. The function returns 0 if the base is NULL
+// and an error if the base is not in the range 2-36.
+func evalBase(name string, arg Expr, row *Row, rows []*Row) (int, error) {
+	val, err := arg.Eval(row, rows)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := val.(types.NullValue); ok {
+		return 0, nil
+	}
+	base, err := val.Int()
+	if err != nil {
+		return 0, err
+	}
+	if base < 2 || base > 36 {
+		return 0, fmt.Errorf("%s: invalid base: %d", name, base)
+	}
+	return int(base), nil
+}
+
+func builtInASCII(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	val, err := args[0].Eval(row, rows)
 	if err != nil {
 		return nil, err
 	}
-	var f64 float64
-	switch v := val.(type) {
-	case types.IntValue:
-		f64 = float64(v)
-
-	case types.FloatValue:
-		f64 = float64(v)
-
-	default:
+	if _, ok := val.(types.NullValue); ok {
 		return types.Null, nil
 	}
-	return types.FloatValue(math.Log10(f64)), nil
+	str := val.String()
+	if len(str) == 0 || str[0] >= utf8.RuneSelf {
+		return types.Null, nil
+	}
+	return types.IntValue(str[0]), nil
 }
 
 func builtInChar(args []Expr, row *Row, rows []*Row) (types.Value, error) {
@@ -656,6 +1486,36 @@ func builtInCharIndex(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(idx + strings.Index(str[idx:], search) + 1), nil
 }
 
+func builtInCodepointAt(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	strVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	idxVal, err := args[1].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := strVal.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	if _, ok := idxVal.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	idx64, err := idxVal.Int()
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(strVal.String())
+
+	// Index is 1-based.
+	if idx64 < 1 || idx64 > int64(len(runes)) {
+		return types.Null, nil
+	}
+	return types.IntValue(runes[idx64-1]), nil
+}
+
 func builtInConcat(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	var sb strings.Builder
 	for i := 0; i < len(args); i++ {
@@ -780,6 +1640,17 @@ func builtInLen(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(len([]rune(val.String()))), nil
 }
 
+func builtInByteLen(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := val.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	return types.IntValue(len(val.String())), nil
+}
+
 func builtInLower(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	val, err := args[0].Eval(row, rows)
 	if err != nil {
@@ -789,6 +1660,35 @@ func builtInLower(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 }
 
 func builtInLPad(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return pad("LPAD", padStart, args, row, rows)
+}
+
+func builtInRPad(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return pad("RPAD", padEnd, args, row, rows)
+}
+
+func builtInCenter(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	return pad("CENTER", padBoth, args, row, rows)
+}
+
+// padding specifies where the pad function adds the padding.
+type padding int
+
+const (
+	padStart padding = iota
+	padEnd
+	padBoth
+)
+
+// pad implements the LPAD, RPAD, and CENTER functions. The function
+// pads the string argument from the start, end, or both sides into
+// length characters. When padding both sides, the extra padding
+// character goes to the end. If the optional display argument is
+// true, the length is counted in the display width of the characters
+// where the wide East Asian characters take two columns.
+func pad(name string, where padding, args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
 	strVal, err := args[0].Eval(row, rows)
 	if err != nil {
 		return nil, err
@@ -802,36 +1702,91 @@ func builtInLPad(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 		return nil, err
 	}
 
+	if length64 > int64(MaxStringLength) {
+		return nil, fmt.Errorf("result string too long: %d > %d",
+			length64, MaxStringLength)
+	}
 	length := Int64ToInt(length64)
 	if length < 0 {
 		length = 0
 	}
 
-	runes := []rune(strVal.String())
-	if length <= len(runes) {
-		return types.StringValue(string(runes[:length])), nil
-	}
-
 	pad := ' '
-	if len(args) == 3 {
+	if len(args) >= 3 {
 		padStr, err := args[2].Eval(row, rows)
 		if err != nil {
 			return nil, err
 		}
 		padRunes := []rune(padStr.String())
 		if len(padRunes) != 1 {
-			return nil, fmt.Errorf("LPAD: invalid padding: '%s'", padStr)
+			return nil, fmt.Errorf("%s: invalid padding: '%s'", name, padStr)
 		}
 		pad = padRunes[0]
 	}
+	measure := func(r rune) int {
+		return 1
+	}
+	if len(args) == 4 {
+		display, err := args[3].Eval(row, rows)
+		if err != nil {
+			return nil, err
+		}
+		d, err := display.Bool()
+		if err != nil {
+			return nil, err
+		}
+		if d {
+			measure = runeWidth
+		}
+	}
 
-	var result []rune
-	for i := 0; i < length-len(runes); i++ {
-		result = append(result, pad)
+	// Truncate the string into length leftmost characters.
+	var runes []rune
+	var w int
+	for _, r := range strVal.String() {
+		rw := measure(r)
+		if w+rw > length {
+			return types.StringValue(string(runes)), nil
+		}
+		runes = append(runes, r)
+		w += rw
+	}
+
+	var padRunes []rune
+	pw := measure(pad)
+	if pw == 0 {
+		return nil, fmt.Errorf("%s: invalid padding: '%c'", name, pad)
+	}
+	for ; w+pw <= length; w += pw {
+		padRunes = append(padRunes, pad)
 	}
-	result = append(result, runes...)
+	switch where {
+	case padStart:
+		return types.StringValue(string(padRunes) + string(runes)), nil
+	case padEnd:
+		return types.StringValue(string(runes) + string(padRunes)), nil
+	default:
+		half := len(padRunes) / 2
+		return types.StringValue(string(padRunes[:half]) + string(runes) +
+			string(padRunes[half:])), nil
+	}
+}
 
-	return types.StringValue(string(result)), nil
+// runeWidth returns the display width of the rune. The East Asian
+// wide and fullwidth runes take two columns. The vt100.DisplayWidth
+// is not used since it removes the terminal control codes but counts
+// each rune as one column.
+func runeWidth(r rune) int {
+	if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) ||
+		unicode.IsControl(r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	default:
+		return 1
+	}
 }
 
 func builtInSubstring(args []Expr, row *Row, rows []*Row) (types.Value, error) {
@@ -923,6 +1878,13 @@ func builtInReplicate(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	if count < 0 {
 		return types.Null, nil
 	}
+	if len(str) == 0 || count == 0 {
+		return types.StringValue(""), nil
+	}
+	if count > int64(MaxStringLength/len(str)) {
+		return nil, fmt.Errorf("result string too long: %d*%d > %d",
+			count, len(str), MaxStringLength)
+	}
 
 	var sb strings.Builder
 	var i int64
@@ -996,6 +1958,10 @@ func builtInSpace(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	if count < 0 {
 		return types.Null, nil
 	}
+	if count > int64(MaxStringLength) {
+		return nil, fmt.Errorf("result string too long: %d > %d",
+			count, MaxStringLength)
+	}
 
 	var sb strings.Builder
 	var i int64
@@ -1096,6 +2062,67 @@ func builtInUpper(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.StringValue(strings.ToUpper(val.String())), nil
 }
 
+func builtInWrap(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := val.(types.NullValue); ok {
+		return types.Null, nil
+	}
+	widthVal, err := args[1].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	width, err := widthVal.Int()
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 {
+		return nil, fmt.Errorf("invalid width: %d", width)
+	}
+	return types.StringValue(wrap(val.String(), Int64ToInt(width))), nil
+}
+
+// wrap wraps the text into lines of at most width display columns.
+// The lines are wrapped at word boundaries and words longer than
+// width are split. The existing newlines are preserved.
+func wrap(text string, width int) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []rune
+		var w int
+
+		for _, word := range strings.Fields(paragraph) {
+			var ww int
+			for _, r := range word {
+				ww += runeWidth(r)
+			}
+			if w > 0 && w+1+ww > width {
+				lines = append(lines, string(line))
+				line = nil
+				w = 0
+			}
+			if w > 0 {
+				line = append(line, ' ')
+				w++
+			}
+			for _, r := range word {
+				rw := runeWidth(r)
+				if w > 0 && w+rw > width {
+					lines = append(lines, string(line))
+					line = nil
+					w = 0
+				}
+				line = append(line, r)
+				w += rw
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func builtInDateDiff(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	fromVal, err := args[1].Eval(row, rows)
 	if err != nil {
@@ -1172,10 +2199,132 @@ func builtInDay(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(date.Day()), nil
 }
 
+func builtInDateTrunc(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	dateVal, err := args[1].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	date, err := dateVal.Date()
+	if err != nil {
+		return nil, err
+	}
+	year, month, day := date.Date()
+	loc := date.Location()
+
+	switch strings.ToLower(args[0].String()) {
+	case "year", "yy", "yyyy":
+		date = time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+
+	case "quarter", "qq", "q":
+		month = (month-1)/3*3 + 1
+		date = time.Date(year, month, 1, 0, 0, 0, 0, loc)
+
+	case "month", "mm", "m":
+		date = time.Date(year, month, 1, 0, 0, 0, 0, loc)
+
+	case "week", "wk", "ww":
+		day -= (int(date.Weekday()) + 6) % 7
+		date = time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	case "day", "dd", "d":
+		date = time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	case "hour", "hh":
+		date = time.Date(year, month, day, date.Hour(), 0, 0, 0, loc)
+
+	default:
+		return nil, fmt.Errorf("DATE_TRUNC: invalid date part: %s", args[0])
+	}
+	return types.DateValue(date), nil
+}
+
+func builtInDayOfWeek(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	dateVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	date, err := dateVal.Date()
+	if err != nil {
+		return nil, err
+	}
+
+	// The first day of the week: 1=Monday, ..., 7=Sunday.
+	var first int64 = 7
+	if len(args) > 1 {
+		firstVal, err := args[1].Eval(row, rows)
+		if err != nil {
+			return nil, err
+		}
+		first, err = firstVal.Int()
+		if err != nil {
+			return nil, err
+		}
+		if first < 1 || first > 7 {
+			return nil, fmt.Errorf("DAYOFWEEK: invalid first day: %d", first)
+		}
+	}
+	iso := (int64(date.Weekday())+6)%7 + 1
+
+	return types.IntValue((iso-first+7)%7 + 1), nil
+}
+
+func builtInEOMonth(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	dateVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	date, err := dateVal.Date()
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if len(args) > 1 {
+		offsetVal, err := args[1].Eval(row, rows)
+		if err != nil {
+			return nil, err
+		}
+		offset, err = offsetVal.Int()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Day 0 of the following month is the last day of the month.
+	year, month, _ := date.Date()
+	month += time.Month(offset + 1)
+
+	return types.DateValue(time.Date(year, month, 0, 0, 0, 0, 0,
+		date.Location())), nil
+}
+
 func builtInGetDate(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	if row != nil && !row.Now.IsZero() {
+		return types.DateValue(row.Now), nil
+	}
 	return types.DateValue(time.Now()), nil
 }
 
+func builtInISOWeek(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	dateVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	date, err := dateVal.Date()
+	if err != nil {
+		return nil, err
+	}
+	_, week := date.ISOWeek()
+	return types.IntValue(week), nil
+}
+
 func builtInMonth(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	dateVal, err := args[0].Eval(row, rows)
 	if err != nil {
@@ -1188,6 +2337,20 @@ func builtInMonth(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(date.Month()), nil
 }
 
+func builtInWeekday(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	dateVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	date, err := dateVal.Date()
+	if err != nil {
+		return nil, err
+	}
+	return types.IntValue((date.Weekday() + 6) % 7), nil
+}
+
 func builtInYear(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	dateVal, err := args[0].Eval(row, rows)
 	if err != nil {
@@ -1200,6 +2363,113 @@ func builtInYear(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	return types.IntValue(date.Year()), nil
 }
 
+func builtInTypeOf(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	val, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := val.(types.NullValue)
+	if ok {
+		return types.StringValue("null"), nil
+	}
+	return types.StringValue(val.Type().String()), nil
+}
+
+func builtInRowID(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	if row.Ordinal == 0 {
+		return types.Null, nil
+	}
+	return types.IntValue(row.Ordinal), nil
+}
+
+func builtInCumeDist(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	if row.Window == nil || len(row.Window.OrderBy) == 0 {
+		return nil, fmt.Errorf("CUME_DIST: ORDER BY required")
+	}
+	_, last, err := row.Window.Peers(row.Index)
+	if err != nil {
+		return nil, err
+	}
+	return types.FloatValue(float64(last+1) /
+		float64(len(row.Window.Rows))), nil
+}
+
+func builtInFillForward(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	if row.Window == nil || len(row.Window.OrderBy) == 0 {
+		return nil, fmt.Errorf("FILL_FORWARD: ORDER BY required")
+	}
+	return row.Window.FillForward(args[0], row.Index)
+}
+
+func builtInNTile(args []Expr, row *Row, rows []*Row) (types.Value, error) {
+	nVal, err := args[0].Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	n, err := nVal.Int()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("NTILE: invalid number of buckets: %d", n)
+	}
+	if row.Window == nil || len(row.Window.OrderBy) == 0 {
+		return nil, fmt.Errorf("NTILE: ORDER BY required")
+	}
+
+	// The first count%n buckets get one extra row.
+	count := int64(len(row.Window.Rows))
+	size := count / n
+	extra := count % n
+	idx := int64(row.Index)
+
+	if idx < extra*(size+1) {
+		return types.IntValue(idx/(size+1) + 1), nil
+	}
+	return types.IntValue(extra + (idx-extra*(size+1))/size + 1), nil
+}
+
+func builtInRowNumber(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	if row.Window == nil {
+		return nil, fmt.Errorf("ROW_NUMBER: no result window")
+	}
+	return types.IntValue(row.Index + 1), nil
+}
+
+func builtInRunLength(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	if row.Window == nil || len(row.Window.OrderBy) == 0 {
+		return nil, fmt.Errorf("RUNLENGTH: ORDER BY required")
+	}
+	count, err := row.Window.RunLength(args[0], row.Index)
+	if err != nil {
+		return nil, err
+	}
+	return types.IntValue(count), nil
+}
+
+func builtInPercentRank(args []Expr, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	if row.Window == nil || len(row.Window.OrderBy) == 0 {
+		return nil, fmt.Errorf("PERCENT_RANK: ORDER BY required")
+	}
+	first, _, err := row.Window.Peers(row.Index)
+	if err != nil {
+		return nil, err
+	}
+	count := len(row.Window.Rows)
+	if count <= 1 {
+		return types.FloatValue(0), nil
+	}
+	return types.FloatValue(float64(first) / float64(count-1)), nil
+}
+
 func builtInHBar(args []Expr, row *Row, rows []*Row) (types.Value, error) {
 	valVal, err := args[0].Eval(row, rows)
 	if err != nil {
@@ -12,7 +12,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 var builtInData = `Year,IVal,FVal
@@ -72,6 +74,82 @@ from (
 	},
 	{
 		q: `
+SELECT MAX(CAST(Day AS DATETIME)) AS Last,
+       MIN(CAST(Day AS DATETIME)) AS First,
+       MIN(Name) AS MinName, MAX(Name) AS MaxName
+FROM ` + "```csv" + `
+Name,Day
+pear,2021-03-15
+apple,2022-01-02
+fig,2020-12-31
+` + "```;",
+		v: [][]string{{
+			"2022-01-02 00:00:00",
+			"2020-12-31 00:00:00",
+			"apple",
+			"pear",
+		}},
+	},
+	{
+		q: `
+SELECT MAX(Day) AS Last, MIN(Day) AS First
+FROM (
+      SELECT CAST(Day AS DATETIME) AS Day FORMAT '01/02/2006'
+      FROM ` + "```csv" + `
+Day
+12/31/2019
+01/02/2020
+06/15/2019
+` + "```" + `
+     );`,
+		v: [][]string{{
+			"2020-01-02 00:00:00",
+			"2019-06-15 00:00:00",
+		}},
+	},
+	{
+		q: `
+SELECT Kind, MIN(Name), MAX(Name)
+FROM ` + "```csv" + `
+Kind,Name
+fruit,pear
+root,carrot
+fruit,apple
+root,beet
+` + "```" + `
+GROUP BY Kind;`,
+		v: [][]string{
+			{"fruit", "apple", "pear"},
+			{"root", "beet", "carrot"},
+		},
+	},
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	{
+		q: `
+SELECT COUNT_NULL(Ints), NULL_RATE(Ints), COUNT_NULL(Floats),
+       COUNT(Ints), COUNT_NULL(Ints) + COUNT(Ints) = COUNT(*)
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo=';`,
+		v: [][]string{{"1", "0.16666666666666666", "1", "5", "true"}},
+	},
+	{
+		q: `
+SELECT Ints, COUNT_NULL(Floats), NULL_RATE(Floats)
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Ints > 7
+GROUP BY Ints;`,
+		v: [][]string{
+			{"12", "0", "0"},
+			{"8", "1", "1"},
+		},
+	},
+	{
+		q: `
 select SUM(Year)
 from (
       select Year, IVal, FVal from data
@@ -110,6 +188,33 @@ SELECT 5 / NULLIF(0.0, 0.0);`,
 SELECT 5 / NULLIF(5.0, 0.0);`,
 		v: [][]string{{"1"}},
 	},
+	{
+		q: `SELECT CHOOSE(2, 'Jan', 'Feb', 'Mar');`,
+		v: [][]string{{"Feb"}},
+	},
+	{
+		q: `SELECT CHOOSE(0, 'Jan', 'Feb'), CHOOSE(3, 'Jan', 'Feb');`,
+		v: [][]string{{"NULL", "NULL"}},
+	},
+	{
+		q: `SELECT CHOOSE(NULL, 'Jan', 'Feb');`,
+		v: [][]string{{"NULL"}},
+	},
+	{
+		q: `SELECT CHOOSE(1, 'ok', 1 / 0);`,
+		v: [][]string{{"ok"}},
+	},
+	{
+		q: `
+SELECT CHOOSE(Month, 'Q1', 'Q1', 'Q1', 'Q2') AS Quarter
+FROM ` + "```csv" + `
+Month
+1
+4
+5
+` + "```" + `;`,
+		v: [][]string{{"Q1"}, {"Q2"}, {"NULL"}},
+	},
 
 	// CAST tests.
 	{
@@ -158,20 +263,134 @@ SELECT 5 / NULLIF(5.0, 0.0);`,
 	},
 
 	// Mathematical functions.
+	{
+		q: `SELECT ABS(-5), ABS(5), ABS(-3.14), ABS(0.5), ABS(NULL);`,
+		v: [][]string{{"5", "5", "3.14", "0.5", "NULL"}},
+	},
+	{
+		q: `SELECT BITNOT(0), BITNOT(-1), BITNOT(0xf0), BITNOT(NULL);`,
+		v: [][]string{{"-1", "0", "-241", "NULL"}},
+	},
+	{
+		q: `SELECT CEILING(123.45), CEILING(-123.45), CEILING(7), CEILING(NULL);`,
+		v: [][]string{{"124", "-123", "7", "NULL"}},
+	},
+	{
+		q: `SELECT EXP(0), EXP(1), EXP(NULL);`,
+		v: [][]string{{"1", "2.718281828459045", "NULL"}},
+	},
 	{
 		q: `SELECT FLOOR(123.45), FLOOR(-123.45);`,
 		v: [][]string{{"123", "-124"}},
 	},
+	{
+		q: `SELECT FROM_BASE('ff', 16), FROM_BASE('FF', 16), FROM_BASE('-101', 2);`,
+		v: [][]string{{"255", "255", "-5"}},
+	},
+	{
+		q: `SELECT FROM_BASE(NULL, 16), FROM_BASE('ff', NULL);`,
+		v: [][]string{{"NULL", "NULL"}},
+	},
 	{
 		q: `SELECT LOG(10);`,
 		v: [][]string{{"2.302585092994046"}},
 	},
+	{
+		q: `SELECT LOG(8, 2), LOG(1000, 10), LOG(32, 4), LOG(1);`,
+		v: [][]string{{"3", "3", "2.5", "0"}},
+	},
+	{
+		q: `SELECT LOG(0), LOG(-1), LOG(8, 1), LOG(8, 0), LOG(NULL), LOG(8, NULL);`,
+		v: [][]string{{"NULL", "NULL", "NULL", "NULL", "NULL", "NULL"}},
+	},
 	{
 		q: `SELECT LOG10(145.175643);`,
 		v: [][]string{{"2.1618937582509687"}},
 	},
+	{
+		q: `SELECT LOG10(1000), LOG10(0), LOG10(-10), LOG10(NULL);`,
+		v: [][]string{{"3", "NULL", "NULL", "NULL"}},
+	},
+	{
+		q: `SELECT POPCOUNT(7), POPCOUNT(0), POPCOUNT(-1), POPCOUNT(BITNOT(0xff));`,
+		v: [][]string{{"3", "0", "64", "56"}},
+	},
+	{
+		q: `SELECT POPCOUNT(NULL);`,
+		v: [][]string{{"NULL"}},
+	},
+	{
+		q: `SELECT POWER(2, 10), POWER(2.5, 2), POWER(4, 0.5), POWER(2, -1);`,
+		v: [][]string{{"1024", "6.25", "2", "0.5"}},
+	},
+	{
+		q: `SELECT POWER(NULL, 2), POWER(2, NULL);`,
+		v: [][]string{{"NULL", "NULL"}},
+	},
+	{
+		q: `SELECT ROUND(2.345, 2), ROUND(2.5), ROUND(7, 0), ROUND(1234.56, -2);`,
+		v: [][]string{{"2.35", "3", "7", "1200"}},
+	},
+	{
+		q: `SELECT ROUND(-2.5, 0), ROUND(-2.345, 2), ROUND(-0.4), ROUND(-1250, -2);`,
+		v: [][]string{{"-3", "-2.35", "0", "-1300"}},
+	},
+	{
+		q: `SELECT ROUND(0.5), ROUND(1234.56, -4), ROUND(5678, -4), ROUND(1.25, 5);`,
+		v: [][]string{{"1", "0", "10000", "1.25"}},
+	},
+	{
+		q: `SELECT ROUND(NULL, 2), ROUND(NULL), ROUND(2.5, NULL);`,
+		v: [][]string{{"NULL", "NULL", "NULL"}},
+	},
+	{
+		q: `SELECT SQRT(16), SQRT(2.25), SQRT(0), SQRT(-1), SQRT(NULL);`,
+		v: [][]string{{"4", "1.5", "0", "NULL", "NULL"}},
+	},
+	{
+		q: `SELECT SAFE_DIVIDE(7, 2), SAFE_DIVIDE(7.0, 2);`,
+		v: [][]string{{"3", "3.5"}},
+	},
+	{
+		q: `SELECT SAFE_DIVIDE(7, 0), SAFE_DIVIDE(7.0, 0.0), SAFE_DIVIDE(7, NULL);`,
+		v: [][]string{{"NULL", "NULL", "NULL"}},
+	},
+	{
+		q: `SELECT TO_BASE(255, 16), TO_BASE(5, 2), TO_BASE(-35, 36), TO_BASE(NULL, 2);`,
+		v: [][]string{{"ff", "101", "-z", "NULL"}},
+	},
+	{
+		q: `SELECT FROM_BASE(TO_BASE(0x7fffffffffffffff, 2), 2),
+	      FROM_BASE(TO_BASE(48879, 16), 16),
+	      FROM_BASE(TO_BASE(1295, 36), 36), TO_BASE(1295, 36);`,
+		v: [][]string{{"9223372036854775807", "48879", "1295", "zz"}},
+	},
 
 	// String functions.
+	{
+		q: `SELECT ASCII('A'), ASCII('abc'), ASCII('');`,
+		v: [][]string{{"65", "97", "NULL"}},
+	},
+	{
+		q: `SELECT ASCII('Åkergatan'), UNICODE('Åkergatan');`,
+		v: [][]string{{"NULL", "197"}},
+	},
+	{
+		q: `SELECT ASCII(NULL);`,
+		v: [][]string{{"NULL"}},
+	},
+	{
+		q: `SELECT CENTER('ABC', 7, '*'), CENTER('ABC', 8, '*');`,
+		v: [][]string{{"**ABC**", "**ABC***"}},
+	},
+	{
+		q: `SELECT CENTER('AB', 7), CENTER('AB', 6, '-');`,
+		v: [][]string{{"  AB   ", "--AB--"}},
+	},
+	{
+		q: `SELECT CENTER('ABCDEF', 4, '*'), CENTER('漢字', 7, '*', true);`,
+		v: [][]string{{"ABCD", "*漢字**"}},
+	},
 	{
 		q: `SELECT CHAR(-1);`,
 		v: [][]string{{"NULL"}},
@@ -208,6 +427,18 @@ CHAR(49) AS [49], CHAR(50) AS [50];`,
                              'bike');`,
 		v: [][]string{{"0"}},
 	},
+	{
+		q: `SELECT CODEPOINT_AT('Åkergatan', 1), CODEPOINT_AT('Åkergatan', 2);`,
+		v: [][]string{{"197", "107"}},
+	},
+	{
+		q: `SELECT CODEPOINT_AT('abc', 0), CODEPOINT_AT('abc', 4);`,
+		v: [][]string{{"NULL", "NULL"}},
+	},
+	{
+		q: `SELECT CODEPOINT_AT(NULL, 1), CODEPOINT_AT('abc', NULL);`,
+		v: [][]string{{"NULL", "NULL"}},
+	},
 	{
 		q: `SELECT CONCAT('Happy ', 'Birthday ', 11, '/', '25');`,
 		v: [][]string{{"Happy Birthday 11/25"}},
@@ -257,6 +488,18 @@ CHAR(49) AS [49], CHAR(50) AS [50];`,
 		q: `SELECT LEN('Hello, world!');`,
 		v: [][]string{{"13"}},
 	},
+	{
+		q: `SELECT BYTELEN('Hello, world!');`,
+		v: [][]string{{"13"}},
+	},
+	{
+		q: `SELECT LEN('Åkergatan'), BYTELEN('Åkergatan');`,
+		v: [][]string{{"9", "10"}},
+	},
+	{
+		q: `SELECT BYTELEN(NULL), BYTELEN('');`,
+		v: [][]string{{"NULL", "0"}},
+	},
 	{
 		q: `SELECT LOWER('Hello, world!');`,
 		v: [][]string{{"hello, world!"}},
@@ -273,6 +516,10 @@ CHAR(49) AS [49], CHAR(50) AS [50];`,
 		q: `SELECT LPAD('ABCDEF', 5, '*');`,
 		v: [][]string{{"ABCDE"}},
 	},
+	{
+		q: `SELECT LPAD('漢字', 6, '*', true), LPAD('漢字', 5, '*', true);`,
+		v: [][]string{{"**漢字", "*漢字"}},
+	},
 	{
 		q: `SELECT LTRIM('  Hello, World!  ');`,
 		v: [][]string{{"Hello, World!  "}},
@@ -289,6 +536,18 @@ CHAR(49) AS [49], CHAR(50) AS [50];`,
 		q: `SELECT REPLICATE('0', -1);`,
 		v: [][]string{{"NULL"}},
 	},
+	{
+		q: `SELECT REPLICATE('', 1000000000000000000);`,
+		v: [][]string{{""}},
+	},
+	{
+		q: `SELECT REPLICATE('0', 0);`,
+		v: [][]string{{""}},
+	},
+	{
+		q: `SELECT REPEAT('ab', 3);`,
+		v: [][]string{{"ababab"}},
+	},
 	{
 		q: `SELECT REVERSE('Ken');`,
 		v: [][]string{{"neK"}},
@@ -317,6 +576,30 @@ CHAR(49) AS [49], CHAR(50) AS [50];`,
 		q: `SELECT RIGHT('abcdefg', 100000);`,
 		v: [][]string{{"abcdefg"}},
 	},
+	{
+		q: `SELECT RPAD('ABC', 5, '*');`,
+		v: [][]string{{"ABC**"}},
+	},
+	{
+		q: `SELECT RPAD('ABC', 5);`,
+		v: [][]string{{"ABC  "}},
+	},
+	{
+		q: `SELECT RPAD('ABCDEF', 5, '*');`,
+		v: [][]string{{"ABCDE"}},
+	},
+	{
+		q: `SELECT RPAD('ABC', -1), RPAD('', 2, '-');`,
+		v: [][]string{{"", "--"}},
+	},
+	{
+		q: `SELECT RPAD('漢字', 6, '*', true), RPAD('漢字x', 4, '*', true);`,
+		v: [][]string{{"漢字**", "漢字"}},
+	},
+	{
+		q: `SELECT RPAD('漢字', 6, '*');`,
+		v: [][]string{{"漢字****"}},
+	},
 	{
 		q: `SELECT RTRIM('  Hello, World!  ');`,
 		v: [][]string{{"  Hello, World!"}},
@@ -407,6 +690,14 @@ SELECT UNICODE(nstring), NCHAR(UNICODE(nstring));`,
 		q: `SELECT UPPER('Hello, world!');`,
 		v: [][]string{{"HELLO, WORLD!"}},
 	},
+	{
+		q: `SELECT WRAP('The quick brown fox jumps over the lazy dog', 10);`,
+		v: [][]string{{"The quick\nbrown fox\njumps over\nthe lazy\ndog"}},
+	},
+	{
+		q: `SELECT WRAP('abcdefghij klm', 4), WRAP('漢字漢字', 5);`,
+		v: [][]string{{"abcd\nefgh\nij\nklm", "漢字\n漢字"}},
+	},
 
 	// Datetime literals.
 	{
@@ -514,6 +805,62 @@ SELECT DATEDIFF(year, now, now);`,
 		q: `SELECT YEAR('2005-12-31 23:59:59.9999999');`,
 		v: [][]string{{"2005"}},
 	},
+	{
+		q: `SELECT DATE_TRUNC(month, '2021-03-17 13:45:10'),
+       DATE_TRUNC(year, '2021-03-17 13:45:10'),
+       DATE_TRUNC(quarter, '2021-08-17 13:45:10'),
+       DATE_TRUNC(week, '2021-03-17 13:45:10'),
+       DATE_TRUNC(day, '2021-03-17 13:45:10'),
+       DATE_TRUNC(hour, '2021-03-17 13:45:10');`,
+		v: [][]string{{
+			"2021-03-01 00:00:00",
+			"2021-01-01 00:00:00",
+			"2021-07-01 00:00:00",
+			"2021-03-15 00:00:00",
+			"2021-03-17 00:00:00",
+			"2021-03-17 13:00:00",
+		}},
+	},
+	{
+		q: `SELECT EOMONTH('2021-01-15'), EOMONTH('2021-04-15 10:00:00'),
+       EOMONTH('2020-02-10'), EOMONTH('2021-01-31', 1),
+       EOMONTH('2021-03-31', -1);`,
+		v: [][]string{{
+			"2021-01-31 00:00:00",
+			"2021-04-30 00:00:00",
+			"2020-02-29 00:00:00",
+			"2021-02-28 00:00:00",
+			"2021-02-28 00:00:00",
+		}},
+	},
+	{
+		q: `SELECT DAYOFWEEK('2021-03-14'), DAYOFWEEK('2021-03-15'),
+       DAYOFWEEK('2021-03-14', 1), DAYOFWEEK('2021-03-15', 1);`,
+		v: [][]string{{"1", "2", "7", "1"}},
+	},
+	{
+		q: `SELECT WEEKDAY('2021-03-15'), WEEKDAY('2021-03-14');`,
+		v: [][]string{{"0", "6"}},
+	},
+	{
+		q: `SELECT ISOWEEK('2021-03-15'), ISOWEEK('2021-01-03'),
+       ISOWEEK('2020-12-31');`,
+		v: [][]string{{"11", "53", "53"}},
+	},
+
+	// Type functions.
+	{
+		q: `SELECT TYPEOF(true), TYPEOF(42), TYPEOF(3.14), TYPEOF('foo');`,
+		v: [][]string{{"boolean", "integer", "real", "varchar"}},
+	},
+	{
+		q: `SELECT TYPEOF(GETDATE()), TYPEOF(NULL);`,
+		v: [][]string{{"datetime", "null"}},
+	},
+	{
+		q: `SELECT TYPEOF(CAST('5' AS INTEGER)), TYPEOF(CAST(5 AS VARCHAR));`,
+		v: [][]string{{"integer", "varchar"}},
+	},
 
 	// Visualization functions.
 	{
@@ -530,6 +877,67 @@ SELECT DATEDIFF(year, now, now);`,
 	},
 }
 
+var builtInErrorTests = []string{
+	`SELECT TO_BASE(255, 1);`,
+	`SELECT TO_BASE(255, 37);`,
+	`SELECT FROM_BASE('ff', 10);`,
+	`SELECT FROM_BASE('z', 37);`,
+	`SELECT ABS(-9223372036854775807 - 1);`,
+	`SELECT REPLICATE('x', 1000000000000000000);`,
+	`SELECT REPEAT('xy', 600000);`,
+	`SELECT SPACE(0x7fffffffffffffff);`,
+	`SELECT RPAD('ABC', 5, '**');`,
+	`SELECT WRAP('abc', 0);`,
+	`SELECT CHOOSE('x', 'a', 'b');`,
+	`SELECT LPAD('ABC', 0x7fffffffffffffff);`,
+	`SELECT CUME_DIST();`,
+	`SELECT PERCENT_RANK();`,
+	`SELECT FILL_FORWARD(1);`,
+	`SELECT RUNLENGTH(true);`,
+	`SELECT RUNLENGTH(1) ORDER BY 1;`,
+	`SELECT NTILE(2);`,
+	`SELECT NTILE(0) ORDER BY 1;`,
+	`SELECT DAYOFWEEK('2021-03-15', 8);`,
+	`SELECT DATE_TRUNC(minute, '2021-03-15');`,
+}
+
+func TestBuiltInErrors(t *testing.T) {
+	for testID, input := range builtInErrorTests {
+		name := fmt.Sprintf("Test %d", testID)
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			name, os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", name, err)
+		}
+		_, err = q.Get()
+		if err == nil {
+			t.Errorf("%s: expected error:\n%s\n", name, input)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog and keeps running " +
+		"through the extraordinarily long meadow."
+	wrapped := wrap(text, 20)
+	lines := strings.Split(wrapped, "\n")
+	if len(lines) != 6 {
+		t.Errorf("got %d lines, expected 6:\n%s", len(lines), wrapped)
+	}
+	for idx, line := range lines {
+		if utf8.RuneCountInString(line) > 20 {
+			t.Errorf("line %d too long: %q", idx, line)
+		}
+		if strings.TrimSpace(line) != line {
+			t.Errorf("line %d has extra whitespace: %q", idx, line)
+		}
+	}
+	if strings.Join(strings.Fields(wrapped), " ") != text {
+		t.Errorf("wrap changed words: %q", wrapped)
+	}
+}
+
 func TestBuiltIn(t *testing.T) {
 	data := fmt.Sprintf("data:text/csv;base64,%s",
 		base64.StdEncoding.EncodeToString([]byte(builtInData)))
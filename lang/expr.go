@@ -8,8 +8,12 @@ package lang
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/markkurossi/iql/types"
 )
@@ -20,16 +24,130 @@ var (
 	_ Expr = &In{}
 	_ Expr = &Unary{}
 	_ Expr = &And{}
+	_ Expr = &Or{}
 	_ Expr = &Constant{}
 	_ Expr = &Reference{}
 	_ Expr = &Cast{}
 	_ Expr = &Case{}
 )
 
-// Row implements a row that is evaluated against the query.
+// Row implements a row that is evaluated against the query. The
+// Ordinal is the 1-based position of the row in the source scan. The
+// Now specifies the query execution time which GETDATE returns for
+// all rows of the query.
 type Row struct {
-	Data  []types.Row
-	Order []types.Value
+	Data    []types.Row
+	Order   []types.Value
+	Window  *Window
+	Index   int
+	Ordinal int64
+	Now     time.Time
+}
+
+// Window defines the ordered result rows for window functions. The
+// Row.Index specifies the row's position in its window.
+type Window struct {
+	Rows    []*Row
+	Groups  [][]*Row
+	OrderBy []Order
+	query   *Query
+	peers   [][2]int
+	fills   map[Expr][]types.Value
+	runs    map[Expr][]int64
+}
+
+// Peers returns the index range [first, last] of the rows that have
+// the same ORDER BY values as the row at index idx.
+func (w *Window) Peers(idx int) (int, int, error) {
+	if w.peers == nil {
+		// The row order values end with the row sequence number
+		// which is not part of the ORDER BY values.
+		n := len(w.OrderBy)
+		peers := make([][2]int, len(w.Rows))
+		for first := 0; first < len(w.Rows); {
+			last := first
+			for last+1 < len(w.Rows) {
+				cmp, err := w.query.compareOrderBy(w.OrderBy,
+					w.Rows[first].Order[:n], w.Rows[last+1].Order[:n])
+				if err != nil {
+					return 0, 0, err
+				}
+				if cmp != 0 {
+					break
+				}
+				last++
+			}
+			for i := first; i <= last; i++ {
+				peers[i] = [2]int{first, last}
+			}
+			first = last + 1
+		}
+		w.peers = peers
+	}
+	return w.peers[idx][0], w.peers[idx][1], nil
+}
+
+// FillForward returns the last non-NULL value of the expression at
+// or before the row at index idx. The values are resolved for all
+// rows of the window in one pass when the function is first called
+// for the expression.
+func (w *Window) FillForward(expr Expr, idx int) (types.Value, error) {
+	if w.fills == nil {
+		w.fills = make(map[Expr][]types.Value)
+	}
+	fills, ok := w.fills[expr]
+	if !ok {
+		fills = make([]types.Value, len(w.Rows))
+		var last types.Value = types.Null
+		for i, row := range w.Rows {
+			val, err := expr.Eval(row, w.Groups[i])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := val.(types.NullValue); !ok {
+				last = val
+			}
+			fills[i] = last
+		}
+		w.fills[expr] = fills
+	}
+	return fills[idx], nil
+}
+
+// RunLength returns the number of consecutive rows ending at the row
+// at index idx where the expression is true. The NULL values end the
+// run. The run lengths are resolved for all rows of the window in one
+// pass when the function is first called for the expression.
+func (w *Window) RunLength(expr Expr, idx int) (int64, error) {
+	if w.runs == nil {
+		w.runs = make(map[Expr][]int64)
+	}
+	runs, ok := w.runs[expr]
+	if !ok {
+		runs = make([]int64, len(w.Rows))
+		var count int64
+		for i, row := range w.Rows {
+			val, err := expr.Eval(row, w.Groups[i])
+			if err != nil {
+				return 0, err
+			}
+			var b bool
+			if _, ok := val.(types.NullValue); !ok {
+				b, err = val.Bool()
+				if err != nil {
+					return 0, err
+				}
+			}
+			if b {
+				count++
+			} else {
+				count = 0
+			}
+			runs[i] = count
+		}
+		w.runs[expr] = runs
+	}
+	return runs[idx], nil
 }
 
 func (r *Row) String() string {
@@ -45,16 +163,56 @@ type Expr interface {
 	References() []types.Reference
 }
 
+// NullsMode specifies the NULL handling modifier of aggregate calls.
+type NullsMode int
+
+// Aggregate NULL handling modifiers.
+const (
+	NullsDefault NullsMode = iota
+	NullsIgnore
+	NullsRespect
+)
+
+var nullsModes = map[NullsMode]string{
+	NullsDefault: "",
+	NullsIgnore:  "IGNORE NULLS",
+	NullsRespect: "RESPECT NULLS",
+}
+
+func (m NullsMode) String() string {
+	name, ok := nullsModes[m]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{NullsMode %d}", m)
+}
+
+// Over defines the window of the window function calls. The rows are
+// partitioned by the PartitionBy values and each partition is ordered
+// by the OrderBy values.
+type Over struct {
+	PartitionBy []Expr
+	OrderBy     []Order
+}
+
 // Call implements function call expressions.
 type Call struct {
 	Name      string
 	Arguments []Expr
 	Function  *Function
 	Env       *Query
+	Nulls     NullsMode
+	Distinct  bool
+	Over      *Over
+	nanNull   bool
+	overBase  *Window
+	overRows  []*Row
 }
 
 // Bind implements the Expr.Bind().
 func (call *Call) Bind(iql *Query) error {
+	call.nanNull = NaNNull(iql.Global)
+
 	for i := call.Function.FirstBound; i < len(call.Arguments); i++ {
 		err := call.Arguments[i].Bind(iql)
 		if err != nil {
@@ -62,6 +220,19 @@ func (call *Call) Bind(iql *Query) error {
 		}
 	}
 
+	if call.Over != nil {
+		for _, expr := range call.Over.PartitionBy {
+			if err := expr.Bind(iql); err != nil {
+				return err
+			}
+		}
+		for _, order := range call.Over.OrderBy {
+			if err := order.Expr.Bind(iql); err != nil {
+				return err
+			}
+		}
+	}
+
 	if call.Function.Impl == nil {
 		call.Env = NewQuery(iql.Global)
 
@@ -119,19 +290,122 @@ func (call *Call) Eval(row *Row, rows []*Row) (types.Value, error) {
 		return call.Function.Ret.Eval(row, rows)
 	}
 
-	v, err := call.Function.Impl(call.Arguments, row, rows)
+	if call.Over != nil {
+		var err error
+		row, err = call.overRow(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var v types.Value
+	var err error
+	if call.Distinct {
+		v, err = call.Function.Distinct(call.Arguments, row, rows)
+	} else if call.Nulls != NullsDefault {
+		v, err = call.Function.Nulls(call.Arguments, row, rows,
+			call.Nulls == NullsRespect)
+	} else {
+		v, err = call.Function.Impl(call.Arguments, row, rows)
+	}
 	if err != nil {
 		return v, fmt.Errorf("%s%s", err, usage)
 	}
+	if f, ok := v.(types.FloatValue); ok {
+		return floatResult(float64(f), call.nanNull), nil
+	}
 	return v, nil
 }
 
+// overRow returns the row in its OVER partition window. The
+// partition windows are created from the result window of the row and
+// they are cached until the result window changes.
+func (call *Call) overRow(row *Row) (*Row, error) {
+	base := row.Window
+	if base == nil {
+		return nil, fmt.Errorf("%s: OVER without result window", call.Name)
+	}
+	if call.overBase == base {
+		return call.overRows[row.Index], nil
+	}
+
+	grouping := NewGrouping()
+	for idx, r := range base.Rows {
+		group := base.Groups[idx]
+		var key []types.Value
+		for _, expr := range call.Over.PartitionBy {
+			v, err := expr.Eval(r, group)
+			if err != nil {
+				return nil, err
+			}
+			key = append(key, v)
+		}
+		p := &Row{
+			Data:    r.Data,
+			Index:   idx,
+			Ordinal: r.Ordinal,
+			Now:     r.Now,
+		}
+		for _, order := range call.Over.OrderBy {
+			v, err := order.Expr.Eval(r, group)
+			if err != nil {
+				return nil, err
+			}
+			p.Order = append(p.Order, v)
+		}
+		// The result window position keeps the sort stable.
+		p.Order = append(p.Order, types.IntValue(idx))
+		grouping.Add(key, p)
+	}
+
+	rows := make([]*Row, len(base.Rows))
+	for _, partition := range grouping.Get() {
+		var sortErr error
+		sort.Slice(partition, func(i, j int) bool {
+			cmp, err := base.query.compareOrderBy(call.Over.OrderBy,
+				partition[i].Order, partition[j].Order)
+			if err != nil {
+				sortErr = err
+			}
+			return cmp < 0
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+		window := &Window{
+			OrderBy: call.Over.OrderBy,
+			query:   base.query,
+		}
+		for idx, p := range partition {
+			window.Rows = append(window.Rows, p)
+			window.Groups = append(window.Groups, base.Groups[p.Index])
+			rows[p.Index] = p
+			p.Window = window
+			p.Index = idx
+		}
+	}
+	call.overBase = base
+	call.overRows = rows
+
+	return rows[row.Index], nil
+}
+
 // IsIdempotent implements the Expr.IsIdempotent().
 func (call *Call) IsIdempotent() bool {
 	return call.Function.IsIdempotent(call.Arguments)
 }
 
 func (call *Call) String() string {
+	if call.Over != nil {
+		return fmt.Sprintf("%s(%q) OVER (%q %v)", call.Name, call.Arguments,
+			call.Over.PartitionBy, call.Over.OrderBy)
+	}
+	if call.Distinct {
+		return fmt.Sprintf("%s(DISTINCT %q)", call.Name, call.Arguments)
+	}
+	if call.Nulls != NullsDefault {
+		return fmt.Sprintf("%s(%q %s)", call.Name, call.Arguments, call.Nulls)
+	}
 	return fmt.Sprintf("%s(%q)", call.Name, call.Arguments)
 }
 
@@ -142,14 +416,27 @@ func (call *Call) References() (result []types.Reference) {
 			result = append(result, arg.References()...)
 		}
 	}
+	if call.Over != nil {
+		for _, expr := range call.Over.PartitionBy {
+			result = append(result, expr.References()...)
+		}
+		for _, order := range call.Over.OrderBy {
+			result = append(result, order.Expr.References()...)
+		}
+	}
 	return result
 }
 
 // Binary implements binary expressions.
 type Binary struct {
-	Type  BinaryType
-	Left  Expr
-	Right Expr
+	Type      BinaryType
+	Left      Expr
+	Right     Expr
+	collation types.Collation
+	nanNull   bool
+	query     *Query
+	pattern   string
+	regexp    *regexp.Regexp
 }
 
 // BinaryType specifies binary expression types.
@@ -165,6 +452,7 @@ const (
 	BinGe
 	BinMult
 	BinDiv
+	BinMod
 	BinAdd
 	BinSub
 	BinRegexpEq
@@ -180,12 +468,36 @@ var binaries = map[BinaryType]string{
 	BinGe:        ">=",
 	BinMult:      "*",
 	BinDiv:       "/",
+	BinMod:       "%",
 	BinAdd:       "+",
 	BinSub:       "-",
 	BinRegexpEq:  "~",
 	BinRegexpNEq: "!~",
 }
 
+// overflow records the integer overflow warning of the expression.
+// The result of the overflowing operation wraps around.
+func (b *Binary) overflow(row *Row, l, r int64) {
+	if b.query == nil {
+		return
+	}
+	var ordinal int64
+	if row != nil {
+		ordinal = row.Ordinal
+	}
+	b.query.warnf(b.String(), ordinal, "integer overflow: %d %s %d",
+		l, b.Type, r)
+}
+
+// floatResult returns the float value v. If nanNull is set, the NaN
+// and infinite values are returned as NULL.
+func floatResult(v float64, nanNull bool) types.Value {
+	if nanNull && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return types.Null
+	}
+	return types.FloatValue(v)
+}
+
 func (t BinaryType) String() string {
 	name, ok := binaries[t]
 	if ok {
@@ -196,6 +508,9 @@ func (t BinaryType) String() string {
 
 // Bind implements the Expr.Bind().
 func (b *Binary) Bind(iql *Query) error {
+	b.collation = Collation(iql.Global)
+	b.nanNull = NaNNull(iql.Global)
+	b.query = iql
 	err := b.Left.Bind(iql)
 	if err != nil {
 		return err
@@ -229,6 +544,26 @@ func (b *Binary) Eval(row *Row, rows []*Row) (types.Value, error) {
 		}
 	}
 
+	// Regular expressions match the string values of their operands.
+	if b.Type == BinRegexpEq || b.Type == BinRegexpNEq {
+		match, err := b.match(left.String(), right.String())
+		if err != nil {
+			return nil, err
+		}
+		if b.Type == BinRegexpNEq {
+			match = !match
+		}
+		return types.BoolValue(match), nil
+	}
+
+	// Datetime and interval arithmetic. The datetime operands of
+	// intervals can be any values that convert to datetime.
+	if left.Type() == types.Interval || right.Type() == types.Interval ||
+		(left.Type() == types.Date && right.Type() == types.Date &&
+			b.Type == BinSub) {
+		return b.evalInterval(left, right)
+	}
+
 	// Resolve operation type.
 	opType, err := superType(left.Type(), right.Type(), b.Type.String())
 	if err != nil {
@@ -278,16 +613,33 @@ func (b *Binary) Eval(row *Row, rows []*Row) (types.Value, error) {
 		case BinGe:
 			return types.BoolValue(l >= r), nil
 		case BinMult:
-			return types.IntValue(l * r), nil
+			v := l * r
+			if l != 0 && (v/l != r || (l == -1 && r == math.MinInt64)) {
+				b.overflow(row, l, r)
+			}
+			return types.IntValue(v), nil
 		case BinDiv:
 			if r == 0 {
 				return nil, fmt.Errorf("integer divide by zero")
 			}
 			return types.IntValue(l / r), nil
+		case BinMod:
+			if r == 0 {
+				return nil, fmt.Errorf("integer divide by zero")
+			}
+			return types.IntValue(l % r), nil
 		case BinAdd:
-			return types.IntValue(l + r), nil
+			v := l + r
+			if (l >= 0) == (r >= 0) && (v >= 0) != (l >= 0) {
+				b.overflow(row, l, r)
+			}
+			return types.IntValue(v), nil
 		case BinSub:
-			return types.IntValue(l - r), nil
+			v := l - r
+			if (l >= 0) != (r >= 0) && (v >= 0) != (l >= 0) {
+				b.overflow(row, l, r)
+			}
+			return types.IntValue(v), nil
 		default:
 			return nil, fmt.Errorf("unknown int binary expression: %s %s %s",
 				left, b.Type, right)
@@ -309,16 +661,22 @@ func (b *Binary) Eval(row *Row, rows []*Row) (types.Value, error) {
 			return types.BoolValue(l != r), nil
 		case BinLt:
 			return types.BoolValue(l < r), nil
+		case BinLe:
+			return types.BoolValue(l <= r), nil
 		case BinGt:
 			return types.BoolValue(l > r), nil
+		case BinGe:
+			return types.BoolValue(l >= r), nil
 		case BinMult:
-			return types.FloatValue(l * r), nil
+			return floatResult(l*r, b.nanNull), nil
 		case BinDiv:
-			return types.FloatValue(l / r), nil
+			return floatResult(l/r, b.nanNull), nil
+		case BinMod:
+			return floatResult(math.Mod(l, r), b.nanNull), nil
 		case BinAdd:
-			return types.FloatValue(l + r), nil
+			return floatResult(l+r, b.nanNull), nil
 		case BinSub:
-			return types.FloatValue(l - r), nil
+			return floatResult(l-r, b.nanNull), nil
 		default:
 			return nil, fmt.Errorf("unknown float binary expression: %s %s %s",
 				left, b.Type, right)
@@ -329,24 +687,19 @@ func (b *Binary) Eval(row *Row, rows []*Row) (types.Value, error) {
 		r := right.String()
 		switch b.Type {
 		case BinEq:
-			return types.BoolValue(l == r), nil
+			return types.BoolValue(b.collation.Compare(l, r) == 0), nil
 		case BinNeq:
-			return types.BoolValue(l != r), nil
+			return types.BoolValue(b.collation.Compare(l, r) != 0), nil
 		case BinLt:
-			return types.BoolValue(l < r), nil
+			return types.BoolValue(b.collation.Compare(l, r) < 0), nil
+		case BinLe:
+			return types.BoolValue(b.collation.Compare(l, r) <= 0), nil
 		case BinGt:
-			return types.BoolValue(l > r), nil
+			return types.BoolValue(b.collation.Compare(l, r) > 0), nil
+		case BinGe:
+			return types.BoolValue(b.collation.Compare(l, r) >= 0), nil
 		case BinAdd:
 			return types.StringValue(l + r), nil
-		case BinRegexpEq, BinRegexpNEq:
-			match, err := regexp.MatchString(r, l)
-			if err != nil {
-				return nil, err
-			}
-			if b.Type == BinRegexpNEq {
-				match = !match
-			}
-			return types.BoolValue(match), nil
 		default:
 			return nil, fmt.Errorf("unknown string binary expression: %s %s %s",
 				left, b.Type, right)
@@ -359,6 +712,95 @@ func (b *Binary) Eval(row *Row, rows []*Row) (types.Value, error) {
 	}
 }
 
+func (b *Binary) evalInterval(left, right types.Value) (types.Value, error) {
+	li, lInterval := left.(types.IntervalValue)
+	ri, rInterval := right.(types.IntervalValue)
+
+	switch b.Type {
+	case BinAdd:
+		if lInterval && rInterval {
+			return li.Add(ri), nil
+		}
+		if rInterval {
+			t, err := left.Date()
+			if err != nil {
+				return nil, err
+			}
+			return types.DateValue(ri.AddTo(t)), nil
+		}
+		if lInterval {
+			t, err := right.Date()
+			if err != nil {
+				return nil, err
+			}
+			return types.DateValue(li.AddTo(t)), nil
+		}
+
+	case BinSub:
+		if lInterval && rInterval {
+			return li.Add(ri.Neg()), nil
+		}
+		if rInterval {
+			t, err := left.Date()
+			if err != nil {
+				return nil, err
+			}
+			return types.DateValue(ri.Neg().AddTo(t)), nil
+		}
+		if left.Type() == types.Date && right.Type() == types.Date {
+			t1, err := left.Date()
+			if err != nil {
+				return nil, err
+			}
+			t2, err := right.Date()
+			if err != nil {
+				return nil, err
+			}
+			return types.Since(t1, t2), nil
+		}
+
+	case BinEq, BinNeq, BinLt, BinLe, BinGt, BinGe:
+		if lInterval && rInterval {
+			cmp, err := types.Compare(li, ri)
+			if err != nil {
+				return nil, err
+			}
+			switch b.Type {
+			case BinEq:
+				return types.BoolValue(cmp == 0), nil
+			case BinNeq:
+				return types.BoolValue(cmp != 0), nil
+			case BinLt:
+				return types.BoolValue(cmp < 0), nil
+			case BinLe:
+				return types.BoolValue(cmp <= 0), nil
+			case BinGt:
+				return types.BoolValue(cmp > 0), nil
+			default:
+				return types.BoolValue(cmp >= 0), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid types: %s{%s} %s %s{%s}",
+		left, left.Type(), b.Type, right, right.Type())
+}
+
+// match matches the value against the regular expression pattern.
+// The compiled pattern is cached so that it is recompiled only if the
+// pattern changes between rows.
+func (b *Binary) match(val, pattern string) (bool, error) {
+	if b.regexp == nil || b.pattern != pattern {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression '%s': %s",
+				pattern, err)
+		}
+		b.pattern = pattern
+		b.regexp = re
+	}
+	return b.regexp.MatchString(val), nil
+}
+
 func superType(left, right types.Type, op string) (types.Type, error) {
 	switch left {
 	case types.Bool:
@@ -399,7 +841,58 @@ func superType(left, right types.Type, op string) (types.Type, error) {
 	}
 }
 
-func equal(left, right types.Value, opType types.Type) (bool, error) {
+// staticType returns the result type of the expression if it is
+// known before the expression is evaluated. The function returns
+// types.Any if the type is not known.
+func staticType(expr Expr) types.Type {
+	switch e := expr.(type) {
+	case *Constant:
+		return e.Value.Type()
+	case *Cast:
+		return e.Type
+	case *Reference:
+		if e.binding != nil {
+			return e.binding.Type
+		}
+		if e.alias != nil {
+			return staticType(e.alias)
+		}
+		if e.index != nil {
+			return e.index.Type
+		}
+	case *Unary:
+		return staticType(e.Expr)
+	case *Binary:
+		switch e.Type {
+		case BinMult, BinDiv, BinMod, BinAdd, BinSub:
+			t, err := superType(staticType(e.Left), staticType(e.Right),
+				e.Type.String())
+			if err == nil && (t == types.Int || t == types.Float) {
+				return t
+			}
+		default:
+			return types.Bool
+		}
+	case *In, *And, *Or, *IsNull:
+		return types.Bool
+	case *Case:
+		return e.typ
+	case *Call:
+		// The user-defined functions declare their return types.
+		if e.Function != nil && e.Function.Impl == nil {
+			return e.Function.RetType
+		}
+		if e.Function != nil && e.Function.Type != nil &&
+			len(e.Arguments) > 0 {
+			return e.Function.Type(e.Arguments)
+		}
+	}
+	return types.Any
+}
+
+func equal(left, right types.Value, opType types.Type,
+	collation types.Collation) (bool, error) {
+
 	switch opType {
 	case types.Bool:
 		l, err := left.Bool()
@@ -437,7 +930,7 @@ func equal(left, right types.Value, opType types.Type) (bool, error) {
 	case types.String:
 		l := left.String()
 		r := right.String()
-		return l == r, nil
+		return collation.Compare(l, r) == 0, nil
 
 	default:
 		return false, fmt.Errorf("unsupported type: %s", opType)
@@ -462,14 +955,31 @@ func (b *Binary) References() (result []types.Reference) {
 
 // In implements `WHERE IN' expressions.
 type In struct {
-	Left  Expr
-	Not   bool
-	Exprs []Expr
-	Query *Query
+	Left      Expr
+	Not       bool
+	Exprs     []Expr
+	Query     *Query
+	Table     *Binding
+	TableName string
+	Range     *Range
+	collation types.Collation
+	set       map[string]bool
+	setType   types.Type
+	querySets map[types.Type]*querySet
+	queryRows []types.Row
+}
+
+// querySet holds the IN SELECT values converted into the comparison
+// type.
+type querySet struct {
+	values  map[string]bool
+	hasNull bool
+	empty   bool
 }
 
 // Bind implements the Expr.Bind().
 func (in *In) Bind(iql *Query) error {
+	in.collation = Collation(iql.Global)
 	err := in.Left.Bind(iql)
 	if err != nil {
 		return err
@@ -480,86 +990,270 @@ func (in *In) Bind(iql *Query) error {
 			return err
 		}
 	}
+	if in.Range != nil {
+		err = in.Range.Bind(iql)
+		if err != nil {
+			return err
+		}
+	}
+	in.bindSet()
+	in.querySets = nil
+	in.queryRows = nil
 	return nil
 }
 
+// bindSet creates a lookup set for the IN expressions if all
+// expressions are constants of the same boolean, integer, or string
+// type.
+func (in *In) bindSet() {
+	in.set = nil
+	if len(in.Exprs) == 0 {
+		return
+	}
+	set := make(map[string]bool)
+	for idx, e := range in.Exprs {
+		c, ok := e.(*Constant)
+		if !ok {
+			return
+		}
+		t := c.Value.Type()
+		switch t {
+		case types.Bool, types.Int, types.String:
+		default:
+			return
+		}
+		if idx == 0 {
+			in.setType = t
+		} else if t != in.setType {
+			return
+		}
+		set[in.setKey(c.Value)] = true
+	}
+	in.set = set
+}
+
+func (in *In) setKey(v types.Value) string {
+	if in.setType == types.String && in.collation == types.CollateNoCase {
+		return strings.ToLower(v.String())
+	}
+	return v.String()
+}
+
+// source returns the IN SELECT query or the IN table variable source
+// and its name for error messages.
+func (in *In) source() (types.Source, string, error) {
+	if in.Query != nil {
+		return in.Query, "IN SELECT", nil
+	}
+	table, ok := in.Table.Value.(types.TableValue)
+	if !ok {
+		return nil, "", fmt.Errorf("IN %s: invalid table value", in.TableName)
+	}
+	return table.Source, "IN " + in.TableName, nil
+}
+
+// reset drops the cached IN SELECT and IN table value sets and resets
+// the subquery so that they are evaluated again.
+func (in *In) reset() {
+	in.querySets = nil
+	in.queryRows = nil
+	if in.Query != nil {
+		in.Query.reset()
+	}
+	resetExpr(in.Left)
+	for _, e := range in.Exprs {
+		resetExpr(e)
+	}
+	if in.Range != nil {
+		resetExpr(in.Range.From)
+		resetExpr(in.Range.To)
+		resetExpr(in.Range.Step)
+	}
+}
+
+// querySet returns the IN SELECT or IN table result rows converted
+// into the type opType. The sets are created once per comparison type
+// so the IN SELECT is evaluated with set lookups. The sets are valid
+// only for the result rows they were created from and they are
+// dropped when the source returns a new result.
+func (in *In) querySet(rows []types.Row, opType types.Type) (
+	*querySet, error) {
+
+	if len(rows) != len(in.queryRows) ||
+		(len(rows) > 0 && &rows[0] != &in.queryRows[0]) {
+		in.querySets = nil
+		in.queryRows = rows
+	}
+	set, ok := in.querySets[opType]
+	if ok {
+		return set, nil
+	}
+	var err error
+	set = &querySet{
+		values: make(map[string]bool),
+		empty:  len(rows) == 0,
+	}
+	for _, row := range rows {
+		col := row[0]
+		if _, ok := col.(types.NullColumn); ok {
+			set.hasNull = true
+			continue
+		}
+		var v types.Value
+		switch opType {
+		case types.Bool:
+			v, err = col.Bool()
+		case types.Int:
+			v, err = col.Int()
+		case types.Float:
+			v, err = col.Float()
+		case types.String:
+			v = types.StringValue(col.String())
+		default:
+			return nil, fmt.Errorf("invalid types: IN SELECT %s", opType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := v.(types.NullValue); ok {
+			set.hasNull = true
+			continue
+		}
+		key, ok, err := in.queryKey(v, opType)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			set.values[key] = true
+		}
+	}
+	if in.querySets == nil {
+		in.querySets = make(map[types.Type]*querySet)
+	}
+	in.querySets[opType] = set
+	return set, nil
+}
+
+// queryKey returns the IN SELECT set key of the value v in the
+// comparison type opType. The function returns false if the value
+// does not equal to any value, for example, if it is NaN.
+func (in *In) queryKey(v types.Value, opType types.Type) (
+	string, bool, error) {
+
+	switch opType {
+	case types.Bool:
+		b, err := v.Bool()
+		if err != nil {
+			return "", false, err
+		}
+		return strconv.FormatBool(b), true, nil
+
+	case types.Int:
+		i, err := v.Int()
+		if err != nil {
+			return "", false, err
+		}
+		return strconv.FormatInt(i, 10), true, nil
+
+	case types.Float:
+		f, err := v.Float()
+		if err != nil {
+			return "", false, err
+		}
+		if math.IsNaN(f) {
+			return "", false, nil
+		}
+		if f == 0 {
+			// Negative zero equals zero.
+			f = 0
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), true, nil
+
+	case types.String:
+		if in.collation == types.CollateNoCase {
+			return strings.ToLower(v.String()), true, nil
+		}
+		return v.String(), true, nil
+
+	default:
+		return "", false, fmt.Errorf("invalid types: %s IN SELECT %s",
+			v.Type(), opType)
+	}
+}
+
 // Eval implements the Expr.Eval().
 func (in *In) Eval(row *Row, rows []*Row) (types.Value, error) {
 	left, err := in.Left.Eval(row, rows)
 	if err != nil {
 		return nil, err
 	}
+	// The IN follows the SQL three-valued logic: if no values match
+	// and either the left value or any of the compared values is
+	// NULL, the result is NULL (unknown).
 	_, lNull := left.(types.NullValue)
+	var unknown bool
 
-	if in.Query != nil {
-		rows, err := in.Query.Get()
+	if in.Range != nil {
+		if lNull {
+			return types.Null, nil
+		}
+		v, err := in.Range.contains(left, row, rows)
+		if err != nil || v == types.Null {
+			return v, err
+		}
+		return types.BoolValue(v == types.BoolValue(!in.Not)), nil
+	}
+
+	if in.Query != nil || in.Table != nil {
+		source, name, err := in.source()
 		if err != nil {
 			return nil, err
 		}
-		columns := in.Query.Columns()
+		result, err := source.Get()
+		if err != nil {
+			return nil, err
+		}
+		columns := source.Columns()
 		if len(columns) != 1 {
-			return nil, fmt.Errorf("IN SELECT must return one column")
+			if in.Query != nil {
+				return nil, fmt.Errorf("IN SELECT must return one column")
+			}
+			return nil, fmt.Errorf("%s: table must have one column, got %d",
+				name, len(columns))
 		}
-		opType, err := superType(left.Type(), columns[0].Type, "IN SELECT")
+		// The column type of an empty result is not resolved.
+		if len(result) == 0 {
+			return types.BoolValue(in.Not), nil
+		}
+		opType, err := superType(left.Type(), columns[0].Type, name)
 		if err != nil {
 			return nil, err
 		}
-
-		for _, row := range rows {
-			col := row[0]
-
-			var eq bool
-
-			_, rNull := col.(types.NullColumn)
-			if lNull || rNull {
-				eq = lNull && rNull
-			} else {
-				var right types.Value
-				switch opType {
-				case types.Bool:
-					right, err = col.Bool()
-					if err != nil {
-						return nil, err
-					}
-					eq, err = equal(left, right, opType)
-					if err != nil {
-						return nil, err
-					}
-
-				case types.Int:
-					right, err = col.Int()
-					if err != nil {
-						return nil, err
-					}
-					eq, err = equal(left, right, opType)
-					if err != nil {
-						return nil, err
-					}
-
-				case types.Float:
-					right, err = col.Float()
-					if err != nil {
-						return nil, err
-					}
-					eq, err = equal(left, right, opType)
-					if err != nil {
-						return nil, err
-					}
-
-				case types.String:
-					l := left.String()
-					r := right.String()
-					eq = l == r
-
-				default:
-					return nil, fmt.Errorf("invalid types: %s IN SELECT %s",
-						left.Type(), right.Type())
-				}
-			}
-			if eq {
-				return types.BoolValue(!in.Not), nil
-			}
+		set, err := in.querySet(result, opType)
+		if err != nil {
+			return nil, err
 		}
+		if set.empty {
+			return types.BoolValue(in.Not), nil
+		}
+		if lNull {
+			return types.Null, nil
+		}
+		key, ok, err := in.queryKey(left, opType)
+		if err != nil {
+			return nil, err
+		}
+		if ok && set.values[key] {
+			return types.BoolValue(!in.Not), nil
+		}
+		if set.hasNull {
+			return types.Null, nil
+		}
+		return types.BoolValue(in.Not), nil
+	}
+
+	if in.set != nil && left.Type() == in.setType {
+		return types.BoolValue(in.set[in.setKey(left)] != in.Not), nil
 	}
 
 	for _, expr := range in.Exprs {
@@ -571,13 +1265,13 @@ func (in *In) Eval(row *Row, rows []*Row) (types.Value, error) {
 
 		_, rNull := right.(types.NullValue)
 		if lNull || rNull {
-			eq = lNull && rNull
+			unknown = true
 		} else {
 			opType, err := superType(left.Type(), right.Type(), "IN")
 			if err != nil {
 				return nil, err
 			}
-			eq, err = equal(left, right, opType)
+			eq, err = equal(left, right, opType, in.collation)
 			if err != nil {
 				return nil, err
 			}
@@ -586,6 +1280,9 @@ func (in *In) Eval(row *Row, rows []*Row) (types.Value, error) {
 			return types.BoolValue(!in.Not), nil
 		}
 	}
+	if unknown {
+		return types.Null, nil
+	}
 
 	return types.BoolValue(in.Not), nil
 }
@@ -600,6 +1297,9 @@ func (in *In) IsIdempotent() bool {
 			return false
 		}
 	}
+	if in.Range != nil {
+		return in.Range.IsIdempotent()
+	}
 	return true
 }
 
@@ -609,6 +1309,12 @@ func (in *In) String() string {
 	if in.Not {
 		str = "NOT "
 	}
+	if in.Range != nil {
+		return str + "IN " + in.Range.String()
+	}
+	if in.Table != nil {
+		return str + "IN " + in.TableName
+	}
 	str += "IN ("
 
 	for idx, expr := range in.Exprs {
@@ -626,9 +1332,132 @@ func (in *In) References() (result []types.Reference) {
 	for _, expr := range in.Exprs {
 		result = append(result, expr.References()...)
 	}
+	if in.Range != nil {
+		result = append(result, in.Range.From.References()...)
+		result = append(result, in.Range.To.References()...)
+		if in.Range.Step != nil {
+			result = append(result, in.Range.Step.References()...)
+		}
+	}
 	return result
 }
 
+// Range implements the `IN from..to [STEP step]' numeric ranges. The
+// range contains the values from From to To, inclusive, which are
+// from From a multiple of Step apart. The default step is 1.
+type Range struct {
+	From Expr
+	To   Expr
+	Step Expr
+}
+
+// Bind binds the range expressions.
+func (r *Range) Bind(iql *Query) error {
+	err := r.From.Bind(iql)
+	if err != nil {
+		return err
+	}
+	err = r.To.Bind(iql)
+	if err != nil {
+		return err
+	}
+	if r.Step != nil {
+		return r.Step.Bind(iql)
+	}
+	return nil
+}
+
+// IsIdempotent tests if the range expressions are idempotent.
+func (r *Range) IsIdempotent() bool {
+	if !r.From.IsIdempotent() || !r.To.IsIdempotent() {
+		return false
+	}
+	return r.Step == nil || r.Step.IsIdempotent()
+}
+
+func (r *Range) String() string {
+	str := fmt.Sprintf("%s..%s", r.From, r.To)
+	if r.Step != nil {
+		str += fmt.Sprintf(" STEP %s", r.Step)
+	}
+	return str
+}
+
+// contains tests if the value v is in the range. The function
+// returns NULL if any of the range expressions is NULL.
+func (r *Range) contains(v types.Value, row *Row, rows []*Row) (
+	types.Value, error) {
+
+	values := []types.Value{v}
+	exprs := []Expr{r.From, r.To}
+	if r.Step != nil {
+		exprs = append(exprs, r.Step)
+	}
+	for _, expr := range exprs {
+		val, err := expr.Eval(row, rows)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := val.(types.NullValue); ok {
+			return types.Null, nil
+		}
+		values = append(values, val)
+	}
+	if r.Step == nil {
+		values = append(values, types.IntValue(1))
+	}
+
+	opType := types.Int
+	for _, val := range values {
+		switch val.Type() {
+		case types.Int:
+		case types.Float:
+			opType = types.Float
+		default:
+			return nil, fmt.Errorf("invalid types: %s IN %s", v.Type(), r)
+		}
+	}
+
+	if opType == types.Int {
+		var ints []int64
+		for _, val := range values {
+			i64, err := val.Int()
+			if err != nil {
+				return nil, err
+			}
+			ints = append(ints, i64)
+		}
+		if ints[3] <= 0 {
+			return nil, fmt.Errorf("IN %s: invalid step: %d", r, ints[3])
+		}
+		return types.BoolValue(ints[0] >= ints[1] && ints[0] <= ints[2] &&
+			(ints[0]-ints[1])%ints[3] == 0), nil
+	}
+
+	var floats []float64
+	for _, val := range values {
+		f64, err := val.Float()
+		if err != nil {
+			return nil, err
+		}
+		floats = append(floats, f64)
+	}
+	if floats[3] <= 0 {
+		return nil, fmt.Errorf("IN %s: invalid step: %v", r, floats[3])
+	}
+	if floats[0] < floats[1] || floats[0] > floats[2] {
+		return types.BoolValue(false), nil
+	}
+	// The steps are not exactly representable in binary so the step
+	// count is compared with a relative tolerance.
+	n := (floats[0] - floats[1]) / floats[3]
+	return types.BoolValue(math.Abs(n-math.Round(n)) <=
+		rangeEpsilon*math.Max(1, math.Abs(n))), nil
+}
+
+// rangeEpsilon is the relative tolerance of the real range steps.
+const rangeEpsilon = 1e-9
+
 // Unary implements unary expressions.
 type Unary struct {
 	Type UnaryType
@@ -764,6 +1593,101 @@ func (and *And) References() (result []types.Reference) {
 	return result
 }
 
+// Or implements logical OR expressions.
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+// Bind implements the Expr.Bind().
+func (or *Or) Bind(iql *Query) error {
+	err := or.Left.Bind(iql)
+	if err != nil {
+		return err
+	}
+	return or.Right.Bind(iql)
+}
+
+// Eval implements the Expr.Eval().
+func (or *Or) Eval(row *Row, rows []*Row) (types.Value, error) {
+
+	left, err := or.Left.Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	l, err := left.Bool()
+	if err != nil {
+		return nil, err
+	}
+	if l {
+		return types.BoolValue(true), nil
+	}
+
+	right, err := or.Right.Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	r, err := right.Bool()
+	if err != nil {
+		return nil, err
+	}
+	return types.BoolValue(r), nil
+}
+
+// IsIdempotent implements the Expr.IsIdempotent().
+func (or *Or) IsIdempotent() bool {
+	return or.Left.IsIdempotent() && or.Right.IsIdempotent()
+}
+
+func (or *Or) String() string {
+	return fmt.Sprintf("%s OR %s", or.Left, or.Right)
+}
+
+// References implements the Expr.References().
+func (or *Or) References() (result []types.Reference) {
+	result = append(result, or.Left.References()...)
+	result = append(result, or.Right.References()...)
+	return result
+}
+
+// IsNull implements the IS NULL and IS NOT NULL predicates.
+type IsNull struct {
+	Not  bool
+	Expr Expr
+}
+
+// Bind implements the Expr.Bind().
+func (n *IsNull) Bind(iql *Query) error {
+	return n.Expr.Bind(iql)
+}
+
+// Eval implements the Expr.Eval().
+func (n *IsNull) Eval(row *Row, rows []*Row) (types.Value, error) {
+	val, err := n.Expr.Eval(row, rows)
+	if err != nil {
+		return nil, err
+	}
+	_, null := val.(types.NullValue)
+	return types.BoolValue(null != n.Not), nil
+}
+
+// IsIdempotent implements the Expr.IsIdempotent().
+func (n *IsNull) IsIdempotent() bool {
+	return n.Expr.IsIdempotent()
+}
+
+func (n *IsNull) String() string {
+	if n.Not {
+		return fmt.Sprintf("%s IS NOT NULL", n.Expr)
+	}
+	return fmt.Sprintf("%s IS NULL", n.Expr)
+}
+
+// References implements the Expr.References().
+func (n *IsNull) References() []types.Reference {
+	return n.Expr.References()
+}
+
 // Constant implements contant expressions.
 type Constant struct {
 	Value types.Value
@@ -794,11 +1718,48 @@ func (c *Constant) References() (result []types.Reference) {
 	return
 }
 
+// Wildcard implements the `*' argument of the COUNT(*) function. It
+// evaluates to a non-NULL value for every row. In the SELECT list, the
+// wildcard selects the columns of all sources, or the columns of the
+// Source if it is set, excluding the Except columns.
+type Wildcard struct {
+	Source string
+	Except []types.Reference
+}
+
+// Bind implements the Expr.Bind().
+func (w *Wildcard) Bind(iql *Query) error {
+	return nil
+}
+
+// Eval implements the Expr.Eval().
+func (w *Wildcard) Eval(row *Row, rows []*Row) (types.Value, error) {
+	return types.BoolValue(true), nil
+}
+
+// IsIdempotent implements the Expr.IsIdempotent().
+func (w *Wildcard) IsIdempotent() bool {
+	return true
+}
+
+func (w *Wildcard) String() string {
+	if len(w.Source) > 0 {
+		return w.Source + ".*"
+	}
+	return "*"
+}
+
+// References implements the Expr.References().
+func (w *Wildcard) References() (result []types.Reference) {
+	return
+}
+
 // Reference implements column reference expressions.
 type Reference struct {
 	types.Reference
-	index   ColumnIndex
+	index   *ColumnIndex
 	binding *Binding
+	alias   Expr
 	public  bool
 	bound   bool
 }
@@ -833,6 +1794,7 @@ func (ref *Reference) Bind(iql *Query) error {
 	}
 	ref.index = r.index
 	ref.binding = r.binding
+	ref.alias = r.alias
 	ref.bound = true
 
 	return nil
@@ -847,8 +1809,14 @@ func (ref *Reference) Eval(row *Row, rows []*Row) (types.Value, error) {
 	if ref.binding != nil {
 		return ref.binding.Value, nil
 	}
+	if ref.alias != nil {
+		return ref.alias.Eval(row, rows)
+	}
 
 	col := row.Data[ref.index.Source][ref.index.Column]
+	if _, ok := col.(types.NullColumn); ok {
+		return types.Null, nil
+	}
 
 	switch ref.index.Type {
 	case types.Bool:
@@ -868,6 +1836,11 @@ func (ref *Reference) IsIdempotent() bool {
 	if ref.binding != nil {
 		return true
 	}
+	// Column alias references are idempotent if the aliased
+	// expression is.
+	if ref.alias != nil {
+		return ref.alias.IsIdempotent()
+	}
 	return false
 }
 
@@ -916,6 +1889,13 @@ func (c *Cast) Eval(row *Row, rows []*Row) (types.Value, error) {
 		}
 		return types.FloatValue(v), nil
 
+	case types.Date:
+		v, err := val.Date()
+		if err != nil {
+			return nil, err
+		}
+		return types.DateValue(v), nil
+
 	case types.String:
 		return types.StringValue(val.String()), nil
 
@@ -943,6 +1923,7 @@ type Case struct {
 	Input    Expr
 	Branches []Branch
 	Else     Expr
+	typ      types.Type
 }
 
 // Branch implements a case branch.
@@ -967,8 +1948,11 @@ func (c *Case) Bind(iql *Query) error {
 		}
 	}
 	if c.Else != nil {
-		return c.Else.Bind(iql)
+		if err := c.Else.Bind(iql); err != nil {
+			return err
+		}
 	}
+	c.typ = c.resultType()
 	return nil
 }
 
@@ -1002,15 +1986,60 @@ func (c *Case) Eval(row *Row, rows []*Row) (types.Value, error) {
 		}
 
 		if bval {
-			return b.Then.Eval(row, rows)
+			return c.coerce(b.Then.Eval(row, rows))
 		}
 	}
 	if c.Else != nil {
-		return c.Else.Eval(row, rows)
+		return c.coerce(c.Else.Eval(row, rows))
 	}
 	return types.Null, nil
 }
 
+// resultType unifies the result types of the case branches. The
+// integer and real branches unify to real. The function returns
+// types.Any if the branch types can't be unified.
+func (c *Case) resultType() types.Type {
+	exprs := make([]Expr, 0, len(c.Branches)+1)
+	for _, b := range c.Branches {
+		exprs = append(exprs, b.Then)
+	}
+	if c.Else != nil {
+		exprs = append(exprs, c.Else)
+	}
+
+	result := types.Any
+	for _, expr := range exprs {
+		t := staticType(expr)
+		if t == types.Any || t == result {
+			continue
+		}
+		if result == types.Any {
+			result = t
+			continue
+		}
+		st, err := superType(result, t, "CASE")
+		if err != nil || (st != types.Int && st != types.Float) {
+			return types.Any
+		}
+		result = st
+	}
+	return result
+}
+
+// coerce converts the branch value to the unified result type of the
+// case expression. The result type is resolved when the expression is
+// bound.
+func (c *Case) coerce(v types.Value, err error) (types.Value, error) {
+	if err != nil {
+		return nil, err
+	}
+	iv, ok := v.(types.IntValue)
+	if ok && c.typ == types.Float {
+		return types.FloatValue(iv), nil
+	}
+	return v, nil
+}
+
 // IsIdempotent implements the Expr.IsIdempotent().
 func (c *Case) IsIdempotent() bool {
 	if c.Input != nil && !c.Input.IsIdempotent() {
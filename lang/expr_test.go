@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+func benchmarkIn(b *testing.B, element func(i int) string) {
+	var data strings.Builder
+	data.WriteString("Value\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&data, "%d\n", i*2)
+	}
+	var list []string
+	for i := 0; i < 1000; i++ {
+		list = append(list, element(i))
+	}
+	query := fmt.Sprintf(`
+SELECT COUNT(Value)
+FROM 'data:text/csv;base64,%s'
+WHERE Value IN (%s);`,
+		base64.StdEncoding.EncodeToString([]byte(data.String())),
+		strings.Join(list, ", "))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(query)),
+			"bench", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			b.Fatalf("Parse failed: %v", err)
+		}
+		rows, err := q.Get()
+		if err != nil {
+			b.Fatalf("q.Get failed: %v", err)
+		}
+		if rows[0][0].String() != "500" {
+			b.Fatalf("unexpected result: %v", rows[0][0])
+		}
+	}
+}
+
+func BenchmarkInConstants(b *testing.B) {
+	benchmarkIn(b, func(i int) string {
+		return fmt.Sprintf("%d", i)
+	})
+}
+
+func BenchmarkInExpressions(b *testing.B) {
+	benchmarkIn(b, func(i int) string {
+		return fmt.Sprintf("%d + 0", i)
+	})
+}
+
+func BenchmarkSumCase(b *testing.B) {
+	var data strings.Builder
+	data.WriteString("Name,Unit,Count\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&data, "%c,%d,%d\n", 'a'+i%3, i%4, i)
+	}
+	query := fmt.Sprintf(`
+SELECT Name,
+       SUM(CASE WHEN Unit = 0 THEN Count END) AS U0,
+       SUM(CASE WHEN Unit = 1 THEN Count END) AS U1,
+       SUM(CASE WHEN Unit = 2 THEN Count END) AS U2,
+       SUM(CASE WHEN Unit = 3 THEN Count END) AS U3
+FROM 'data:text/csv;base64,%s'
+GROUP BY Name;`,
+		base64.StdEncoding.EncodeToString([]byte(data.String())))
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(query)),
+		"bench", os.Stdout)
+	p, err := parser.Prepare()
+	if err != nil {
+		b.Fatalf("Prepare failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := p.Execute(nil)
+		if err != nil {
+			b.Fatalf("Execute failed: %v", err)
+		}
+		if len(rows) != 3 {
+			b.Fatalf("unexpected result: %v", rows)
+		}
+	}
+}
+
+func TestStaticTypePredicates(t *testing.T) {
+	exprs := []Expr{
+		&IsNull{
+			Expr: &Constant{Value: types.Null},
+		},
+		&IsNull{
+			Not:  true,
+			Expr: &Constant{Value: types.IntValue(1)},
+		},
+	}
+	for _, expr := range exprs {
+		if typ := staticType(expr); typ != types.Bool {
+			t.Errorf("%s: got type %s, expected %s", expr, typ, types.Bool)
+		}
+	}
+}
+
+func TestInQuerySet(t *testing.T) {
+	in := &In{}
+	for _, val := range []int64{1, 2, 2} {
+		rows := []types.Row{
+			{types.NewValueColumn(types.IntValue(val))},
+		}
+		set, err := in.querySet(rows, types.Int)
+		if err != nil {
+			t.Fatalf("querySet failed: %v", err)
+		}
+		if len(set.values) != 1 || !set.values[fmt.Sprintf("%d", val)] {
+			t.Errorf("querySet %d: got %v", val, set.values)
+		}
+	}
+}
@@ -24,6 +24,25 @@ type Function struct {
 	FirstBound   int
 	IsIdempotent IsIdempotent
 	Usage        string
+
+	// Type returns the static result type of the built-in function
+	// for its arguments. The result type of the functions without
+	// Type is known only after evaluation.
+	Type TypeOf
+
+	// Nulls implements the aggregate for the explicit IGNORE NULLS
+	// and RESPECT NULLS modifiers. The modifiers are not allowed for
+	// functions without the Nulls implementation.
+	Nulls NullsImpl
+
+	// Distinct implements the aggregate over the distinct argument
+	// values for the DISTINCT modifier. The modifier is not allowed
+	// for functions without the Distinct implementation.
+	Distinct FunctionImpl
+
+	// Window specifies if the function is a window function. The
+	// window functions can have the OVER clause.
+	Window bool
 }
 
 func (f *Function) String() string {
@@ -44,6 +63,33 @@ type FunctionArg struct {
 // FunctionImpl implements the built-in IQL functions.
 type FunctionImpl func(args []Expr, row *Row, rows []*Row) (types.Value, error)
 
+// NullsImpl implements aggregates with the NULL handling
+// modifier. The respect argument is true for RESPECT NULLS and false
+// for IGNORE NULLS.
+type NullsImpl func(args []Expr, row *Row, rows []*Row, respect bool) (
+	types.Value, error)
+
+// TypeOf returns the static result type of the function when
+// applied to its arguments. The function returns types.Any if the
+// type is not known before evaluation.
+type TypeOf func(args []Expr) types.Type
+
+func typeFloat(args []Expr) types.Type {
+	return types.Float
+}
+
+func typeFirstArg(args []Expr) types.Type {
+	return staticType(args[0])
+}
+
+func typeNumericArg(args []Expr) types.Type {
+	t := staticType(args[0])
+	if t == types.Int || t == types.Float {
+		return t
+	}
+	return types.Any
+}
+
 // IsIdempotent tests if the function is idempotent when applied to
 // its arguments.
 type IsIdempotent func(args []Expr) bool
@@ -7,13 +7,21 @@
 package lang
 
 import (
+	"encoding/binary"
+	"math"
+	"time"
+
 	"github.com/markkurossi/iql/types"
 )
 
-// Grouping implements grouping for rows.
+// Grouping implements grouping for rows. The groups are returned in
+// the first appearance order of their keys.
 type Grouping struct {
 	Children map[types.Value]*Grouping
 	Rows     []*Row
+	index    map[string]*Grouping
+	groups   []*Grouping
+	buf      []byte
 }
 
 // NewGrouping creates a new grouping object.
@@ -25,30 +33,134 @@ func NewGrouping() *Grouping {
 
 // Add adds a row with the grouping key.
 func (g *Grouping) Add(key []types.Value, row *Row) {
-	if len(key) == 0 {
-		g.Rows = append(g.Rows, row)
-		return
+	g.buf = g.buf[:0]
+	for _, k := range key {
+		g.buf = appendGroupKey(g.buf, k)
 	}
-
-	child, ok := g.Children[key[0]]
+	// The map lookup with the string conversion does not allocate.
+	group, ok := g.index[string(g.buf)]
 	if !ok {
-		child = NewGrouping()
-		g.Children[key[0]] = child
+		group = g.child(key)
+		if g.index == nil {
+			g.index = make(map[string]*Grouping)
+		}
+		g.index[string(g.buf)] = group
+		g.groups = append(g.groups, group)
 	}
-	child.Add(key[1:], row)
+	group.Rows = append(group.Rows, row)
 }
 
-// Get gets the row groups.
-func (g *Grouping) Get() [][]*Row {
-	return g.get(nil)
+// child returns the Children node for the key, creating the missing
+// nodes.
+func (g *Grouping) child(key []types.Value) *Grouping {
+	group := g
+	for _, k := range key {
+		child, ok := group.Children[k]
+		if !ok {
+			child = NewGrouping()
+			group.Children[k] = child
+		}
+		group = child
+	}
+	return group
 }
 
-func (g *Grouping) get(rows [][]*Row) [][]*Row {
-	if len(g.Rows) > 0 {
-		rows = append(rows, g.Rows)
-	}
-	for _, child := range g.Children {
-		rows = child.get(rows)
+// Get gets the row groups in the first appearance order of their
+// keys.
+func (g *Grouping) Get() [][]*Row {
+	var rows [][]*Row
+	for _, group := range g.groups {
+		rows = append(rows, group.Rows)
 	}
 	return rows
 }
+
+// Grouping key type tags. The tags keep the values of different types
+// separate so that, for example, the integer 1 and the string '1' are
+// different keys.
+const (
+	keyNull byte = iota
+	keyBool
+	keyInt
+	keyFloat
+	keyDate
+	keyString
+	keyInterval
+	keyArray
+	keyOther
+)
+
+// appendGroupKey appends the type-aware encoding of the value v into
+// buf. The encoding is unambiguous so the concatenated value
+// encodings form a collision-free key.
+func appendGroupKey(buf []byte, v types.Value) []byte {
+	switch val := v.(type) {
+	case types.NullValue:
+		return append(buf, keyNull)
+
+	case types.BoolValue:
+		if val {
+			return append(buf, keyBool, 1)
+		}
+		return append(buf, keyBool, 0)
+
+	case types.IntValue:
+		buf = append(buf, keyInt)
+		return appendUint64(buf, uint64(val))
+
+	case types.FloatValue:
+		f := float64(val)
+		if f == 0 {
+			// Negative zero equals zero.
+			f = 0
+		} else if math.IsNaN(f) {
+			f = math.NaN()
+		}
+		buf = append(buf, keyFloat)
+		return appendUint64(buf, math.Float64bits(f))
+
+	case types.DateValue:
+		// Equal instants in different locations are the same key.
+		buf = append(buf, keyDate)
+		return appendUint64(buf, uint64(time.Time(val).UnixNano()))
+
+	case types.StringValue:
+		buf = append(buf, keyString)
+		return appendBytes(buf, string(val))
+
+	case types.IntervalValue:
+		buf = append(buf, keyInterval)
+		buf = appendUint64(buf, uint64(val.Months))
+		buf = appendUint64(buf, uint64(val.Days))
+		return appendUint64(buf, uint64(val.Duration))
+
+	case types.ArrayValue:
+		buf = append(buf, keyArray)
+		buf = appendUvarint(buf, uint64(len(val.Data)))
+		for _, elem := range val.Data {
+			buf = appendGroupKey(buf, elem)
+		}
+		return buf
+
+	default:
+		buf = append(buf, keyOther, byte(v.Type()))
+		return appendBytes(buf, v.String())
+	}
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendBytes(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
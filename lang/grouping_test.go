@@ -7,6 +7,7 @@
 package lang
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/markkurossi/iql/types"
@@ -52,11 +53,109 @@ func TestGrouping(t *testing.T) {
 	if len(groups) != 2 {
 		t.Errorf("unexpected groups: got %d, expected 2", len(groups))
 	}
-	// Shorter keys first
-	if len(groups[0]) != 1 {
+	// Groups in the first appearance order.
+	if len(groups[0]) != 2 {
 		t.Errorf("unexpected number of rows in group 0")
 	}
-	if len(groups[1]) != 2 {
+	if len(groups[1]) != 1 {
 		t.Errorf("unexpected number of rows in group 1")
 	}
+
+	// The groups are also available in the Children tree.
+	group := g
+	for _, k := range key2 {
+		group = group.Children[k]
+		if group == nil {
+			t.Fatalf("group for key %v not found", k)
+		}
+	}
+	if len(group.Rows) != 1 || group.Rows[0] != row2 {
+		t.Errorf("unexpected Children tree rows: %v", group.Rows)
+	}
+}
+
+func TestGroupingOrder(t *testing.T) {
+	g := NewGrouping()
+
+	keys := []string{"c", "a", "c", "b", "a", "b", "c"}
+	for _, key := range keys {
+		g.Add([]types.Value{types.StringValue(key)}, &Row{
+			Data: []types.Row{
+				[]types.Column{
+					types.StringColumn(key),
+				},
+			},
+		})
+	}
+	expected := []string{"c", "a", "b"}
+
+	groups := g.Get()
+	if len(groups) != len(expected) {
+		t.Fatalf("unexpected groups: got %d, expected %d", len(groups),
+			len(expected))
+	}
+	for i, group := range groups {
+		name := group[0].Data[0][0].String()
+		if name != expected[i] {
+			t.Errorf("group %d: got %s, expected %s", i, name, expected[i])
+		}
+	}
+}
+
+func TestGroupingKeyTypes(t *testing.T) {
+	g := NewGrouping()
+
+	keys := [][]types.Value{
+		{types.IntValue(1)},
+		{types.StringValue("1")},
+		{types.FloatValue(1)},
+		{types.BoolValue(true)},
+		{types.Null},
+		{types.StringValue("")},
+		{types.StringValue("a"), types.StringValue("bc")},
+		{types.StringValue("ab"), types.StringValue("c")},
+		{types.IntValue(1)},
+		{types.StringValue("1")},
+	}
+	for i, key := range keys {
+		g.Add(key, &Row{
+			Data: []types.Row{
+				[]types.Column{
+					types.NewValueColumn(types.IntValue(i)),
+				},
+			},
+		})
+	}
+	groups := g.Get()
+	if len(groups) != 8 {
+		t.Fatalf("unexpected groups: got %d, expected 8", len(groups))
+	}
+	if len(groups[0]) != 2 || len(groups[1]) != 2 {
+		t.Errorf("int and string keys not grouped separately")
+	}
+}
+
+func BenchmarkGrouping(b *testing.B) {
+	const numKeys = 100000
+
+	keys := make([][]types.Value, numKeys)
+	for i := range keys {
+		keys[i] = []types.Value{
+			types.IntValue(i),
+			types.StringValue(fmt.Sprintf("key%d", i)),
+		}
+	}
+	row := &Row{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := NewGrouping()
+		for _, key := range keys {
+			g.Add(key, row)
+		}
+		if len(g.Get()) != numKeys {
+			b.Fatalf("unexpected groups: got %d, expected %d",
+				len(g.Get()), numKeys)
+		}
+	}
 }
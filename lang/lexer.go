@@ -15,6 +15,7 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/markkurossi/iql/data"
 	"github.com/markkurossi/iql/types"
 )
 
@@ -61,6 +62,7 @@ const (
 	TSymReal
 	TSymDatetime
 	TSymVarchar
+	TSymInterval
 	TSymCast
 	TSymCase
 	TSymWhen
@@ -76,12 +78,28 @@ const (
 	TSymIf
 	TSymExists
 	TSymLimit
+	TSymOutfile
+	TSymInsert
+	TSymDelete
+	TSymUpdate
+	TSymCross
+	TSymJoin
+	TSymInner
+	TSymOn
+	TSymUsing
+	TSymNatural
+	TSymApply
+	TSymBetween
+	TSymIs
+	TSymDistinct
+	TSymHaving
 	TAnd
 	TOr
 	TNEq
 	TNMatch
 	TLe
 	TGe
+	TDotDot
 )
 
 var tokenTypes = map[TokenType]string{
@@ -111,6 +129,7 @@ var tokenTypes = map[TokenType]string{
 	TSymReal:     "REAL",
 	TSymDatetime: "DATETIME",
 	TSymVarchar:  "VARCHAR",
+	TSymInterval: "INTERVAL",
 	TSymCast:     "CAST",
 	TSymCase:     "CASE",
 	TSymWhen:     "WHEN",
@@ -126,12 +145,28 @@ var tokenTypes = map[TokenType]string{
 	TSymIf:       "IF",
 	TSymExists:   "EXISTS",
 	TSymLimit:    "LIMIT",
+	TSymOutfile:  "OUTFILE",
+	TSymInsert:   "INSERT",
+	TSymDelete:   "DELETE",
+	TSymUpdate:   "UPDATE",
+	TSymCross:    "CROSS",
+	TSymJoin:     "JOIN",
+	TSymInner:    "INNER",
+	TSymOn:       "ON",
+	TSymUsing:    "USING",
+	TSymNatural:  "NATURAL",
+	TSymApply:    "APPLY",
+	TSymBetween:  "BETWEEN",
+	TSymIs:       "IS",
+	TSymDistinct: "DISTINCT",
+	TSymHaving:   "HAVING",
 	TAnd:         "AND",
 	TOr:          "OR",
 	TNEq:         "<>",
 	TNMatch:      "!~",
 	TLe:          "<=",
 	TGe:          ">=",
+	TDotDot:      "..",
 }
 
 func (t TokenType) String() string {
@@ -168,6 +203,7 @@ var symbols = map[string]TokenType{
 	"REAL":     TSymReal,
 	"DATETIME": TSymDatetime,
 	"VARCHAR":  TSymVarchar,
+	"INTERVAL": TSymInterval,
 	"CAST":     TSymCast,
 	"CASE":     TSymCase,
 	"WHEN":     TSymWhen,
@@ -183,6 +219,21 @@ var symbols = map[string]TokenType{
 	"IF":       TSymIf,
 	"EXISTS":   TSymExists,
 	"LIMIT":    TSymLimit,
+	"OUTFILE":  TSymOutfile,
+	"INSERT":   TSymInsert,
+	"DELETE":   TSymDelete,
+	"UPDATE":   TSymUpdate,
+	"CROSS":    TSymCross,
+	"JOIN":     TSymJoin,
+	"INNER":    TSymInner,
+	"ON":       TSymOn,
+	"USING":    TSymUsing,
+	"NATURAL":  TSymNatural,
+	"APPLY":    TSymApply,
+	"BETWEEN":  TSymBetween,
+	"IS":       TSymIs,
+	"DISTINCT": TSymDistinct,
+	"HAVING":   TSymHaving,
 	"AND":      TAnd,
 	"OR":       TOr,
 }
@@ -218,7 +269,7 @@ type lexer struct {
 	trailingInjected bool
 	point            Point
 	tokenStart       Point
-	ungot            *Token
+	ungot            []*Token
 	unread           bool
 	unreadRune       rune
 	unreadSize       int
@@ -295,9 +346,9 @@ func (l *lexer) FlushEOL() error {
 }
 
 func (l *lexer) get() (*Token, error) {
-	if l.ungot != nil {
-		token := l.ungot
-		l.ungot = nil
+	if len(l.ungot) > 0 {
+		token := l.ungot[len(l.ungot)-1]
+		l.ungot = l.ungot[:len(l.ungot)-1]
 		return token, nil
 	}
 
@@ -313,7 +364,17 @@ lexer:
 		}
 
 		switch r {
-		case '+', '*', '~', '%', '=', '.', ',', '(', ')', ';':
+		case '+', '*', '~', '%', '=', ',', '(', ')', ';':
+			return l.token(TokenType(r)), nil
+
+		case '.':
+			dotDot, err := l.dotDot()
+			if err != nil {
+				return nil, err
+			}
+			if dotDot {
+				return l.token(TDotDot), nil
+			}
 			return l.token(TokenType(r)), nil
 
 		case '<':
@@ -498,6 +559,13 @@ lexer:
 				case '0', '1', '2', '3', '4', '5', '6', '7':
 					i64, err = l.readOctalLiteral([]rune{'0', r})
 				case '.':
+					dotDot, err := l.dotDot()
+					if err != nil {
+						return nil, err
+					}
+					if dotDot {
+						return l.intDotDot(0), nil
+					}
 					f64, err := l.readFloatLiteral([]rune{'0', r})
 					if err != nil {
 						return nil, err
@@ -561,6 +629,17 @@ lexer:
 					if unicode.IsDigit(r) {
 						val = append(val, r)
 					} else if r == '.' {
+						dotDot, err := l.dotDot()
+						if err != nil {
+							return nil, err
+						}
+						if dotDot {
+							i64, err := strconv.ParseInt(string(val), 10, 64)
+							if err != nil {
+								return nil, err
+							}
+							return l.intDotDot(i64), nil
+						}
 						f64, err := l.readFloatLiteral(append(val, r))
 						if err != nil {
 							return nil, err
@@ -638,11 +717,28 @@ func (l *lexer) readHereString() (*Token, error) {
 		}
 		parts := strings.Split(option, ":")
 		switch len(parts) {
+		case 1:
+			// Data format name: csv, html, json.
+			format, err := data.ParseFormat(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("unknown here option: %s", option)
+			}
+			val = hereDataURI(format.MediaType(), val)
+
 		case 2:
 			switch parts[0] {
 			case "datauri":
-				val = fmt.Sprintf("data:%s;base64,%s", parts[1],
-					base64.StdEncoding.EncodeToString([]byte(val)))
+				val = hereDataURI(parts[1], val)
+
+			case "file":
+				var resolver data.Resolver
+				resolver.ResolvePath(parts[1])
+				format, err := resolver.Format()
+				if err != nil {
+					return nil, fmt.Errorf("here option %s: %s", option, err)
+				}
+				val = hereDataURI(format.MediaType(), val)
+
 			default:
 				return nil, fmt.Errorf("Unknown here option: %s", option)
 			}
@@ -657,6 +753,12 @@ func (l *lexer) readHereString() (*Token, error) {
 	return token, nil
 }
 
+// hereDataURI creates a data URI for the here string content.
+func hereDataURI(mediatype, val string) string {
+	return fmt.Sprintf("data:%s;base64,%s", mediatype,
+		base64.StdEncoding.EncodeToString([]byte(val)))
+}
+
 func (l *lexer) readBinaryLiteral(val []rune) (int64, error) {
 loop:
 	for {
@@ -737,8 +839,37 @@ func (l *lexer) readFloatLiteral(val []rune) (float64, error) {
 	return strconv.ParseFloat(string(val), 64)
 }
 
+// dotDot checks if the '.' rune is followed by another '.' rune
+// forming the '..' range token.
+func (l *lexer) dotDot() (bool, error) {
+	r, _, err := l.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			return false, err
+		}
+		return false, nil
+	}
+	if r == '.' {
+		return true, nil
+	}
+	l.UnreadRune()
+	return false, nil
+}
+
+// intDotDot returns the integer token for the range start value. The
+// following '..' token is pushed back to the lexer.
+func (l *lexer) intDotDot(i64 int64) *Token {
+	dotDot := l.token(TDotDot)
+	token := l.token(TInt)
+	token.IntVal = i64
+	token.To.Col -= 2
+	dotDot.From = token.To
+	l.unget(dotDot)
+	return token
+}
+
 func (l *lexer) unget(t *Token) {
-	l.ungot = t
+	l.ungot = append(l.ungot, t)
 }
 
 func (l *lexer) token(t TokenType) *Token {
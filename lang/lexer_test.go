@@ -28,8 +28,11 @@ FROM ',reference.html' FILTER 'tbody > tr' AS ref,
 WHERE ref.link <> '' AND ref.Name = portfolio.name
 `,
 	`select 1 + 0x01 + 0b10 + 077 + 0o70`,
+	`select x in 1..10 step 2, y in 0..5, z in 1.5..2.5`,
 	"select ```\nHello, world!\n```;",
 	"select ``` datauri:text/csv \nInts,Floats\n1,3.14```;",
+	"select ``` csv\nInts,Floats\n1,3.14```;",
+	"select ```file:data.csv\nInts,Floats\n1,3.14```;",
 }
 
 func TestLexer(t *testing.T) {
@@ -50,3 +53,24 @@ func TestLexer(t *testing.T) {
 		fmt.Println()
 	}
 }
+
+func TestHereStringOptionError(t *testing.T) {
+	for _, input := range []string{
+		"select ```xml\n<a/>```;",
+		"select ```file:data.txt\nfoo```;",
+		"select ```foo:bar\nfoo```;",
+	} {
+		lexer := newLexer(bytes.NewReader([]byte(input)), "{data}")
+		var failed bool
+		for {
+			_, err := lexer.get()
+			if err != nil {
+				failed = err != io.EOF
+				break
+			}
+		}
+		if !failed {
+			t.Errorf("expected error: %q", input)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/markkurossi/iql/data"
@@ -32,10 +33,34 @@ func Int64ToInt(val int64) int {
 
 // Parser implements IQL parser.
 type Parser struct {
-	lexer   *lexer
-	nesting int
-	global  *Scope
-	output  io.Writer
+	lexer       *lexer
+	nesting     int
+	global      *Scope
+	output      io.Writer
+	diagnostics DiagnosticHandler
+}
+
+// Diagnostic defines a parser error diagnostic. It holds the error,
+// its input location, the input source line, and an indicator line
+// pointing to the error location within the source line.
+type Diagnostic struct {
+	Loc       Point
+	Err       error
+	Line      string
+	Indicator string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s\n%s\n%s\n", d.Loc, d.Err, d.Line, d.Indicator)
+}
+
+// DiagnosticHandler handles the parser error diagnostics.
+type DiagnosticHandler func(d *Diagnostic)
+
+// LogDiagnostic is the default diagnostic handler which prints the
+// diagnostics with the standard logger.
+func LogDiagnostic(d *Diagnostic) {
+	log.Print(d)
 }
 
 // NewParser creates a new IQL parser.
@@ -43,12 +68,19 @@ func NewParser(global *Scope, input io.Reader, source string,
 	output io.Writer) *Parser {
 
 	return &Parser{
-		lexer:  newLexer(input, source),
-		global: global,
-		output: output,
+		lexer:       newLexer(input, source),
+		global:      global,
+		output:      output,
+		diagnostics: LogDiagnostic,
 	}
 }
 
+// SetDiagnosticHandler sets the handler for the parser error
+// diagnostics. The nil handler discards the diagnostics.
+func (p *Parser) SetDiagnosticHandler(h DiagnosticHandler) {
+	p.diagnostics = h
+}
+
 // SetString defines the global string variable with value.
 func (p *Parser) SetString(name, value string) error {
 	b := p.global.Get(name)
@@ -124,7 +156,46 @@ func (p *Parser) Parse() (*Query, error) {
 			}
 
 		case TSymSelect:
-			return p.parseSelect()
+			q, err := p.parseSelect()
+			if err != nil {
+				return nil, err
+			}
+			if q.Into != nil {
+				v, err := q.scalar()
+				if err != nil {
+					return nil, err
+				}
+				err = q.Into.Set(strings.ToUpper(q.intoName), v)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if q.Outfile == nil {
+				return q, nil
+			}
+			err = q.writeOutfile()
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymInsert:
+			err = p.parseInsert()
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymDelete:
+			err = p.parseDelete()
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymUpdate:
+			err = p.parseUpdate()
+			if err != nil {
+				return nil, err
+			}
 
 		case TSymCreate:
 			err = p.parseCreate()
@@ -189,6 +260,8 @@ func (p *Parser) parseType() (types.Type, error) {
 		return types.Date, nil
 	case TSymVarchar:
 		return types.String, nil
+	case TSymInterval:
+		return types.Interval, nil
 	default:
 		return 0, p.errUnexpected(t)
 	}
@@ -209,6 +282,23 @@ func (p *Parser) parseSet() error {
 		return err
 	}
 
+	// Scalar query result.
+	t, err = p.optional(TSymSelect)
+	if err != nil {
+		return err
+	}
+	if t != nil {
+		q, err := p.parseSelect()
+		if err != nil {
+			return err
+		}
+		v, err := q.scalar()
+		if err != nil {
+			return err
+		}
+		return p.global.Set(name, v)
+	}
+
 	// Value to set.
 	expr, err := p.parseExpr()
 	if err != nil {
@@ -242,6 +332,10 @@ func (p *Parser) parsePrint() error {
 	if err != nil {
 		return err
 	}
+	err = expr.Bind(NewQuery(p.global))
+	if err != nil {
+		return err
+	}
 	v, err := expr.Eval(nil, nil)
 	if err != nil {
 		return err
@@ -253,28 +347,39 @@ func (p *Parser) parsePrint() error {
 func (p *Parser) parseSelect() (*Query, error) {
 	q := NewQuery(p.global)
 
-	// Columns. The columns list is empty for "SELECT *" queries.
-	t, err := p.get()
+	// DISTINCT
+	t, err := p.optional(TSymDistinct)
 	if err != nil {
 		return nil, err
 	}
-	if t.Type != '*' {
-		p.lexer.unget(t)
-		for {
+	q.Distinct = t != nil
+
+	// Columns. The wildcards are expanded into the source columns
+	// when the query is bound.
+	for {
+		w, err := p.parseWildcard()
+		if err != nil {
+			return nil, err
+		}
+		if w != nil {
+			q.Select = append(q.Select, ColumnSelector{
+				Expr: w,
+			})
+		} else {
 			col, err := p.parseColumn()
 			if err != nil {
 				return nil, err
 			}
 			q.Select = append(q.Select, *col)
+		}
 
-			t, err := p.get()
-			if err != nil {
-				return nil, err
-			}
-			if t.Type != ',' {
-				p.lexer.unget(t)
-				break
-			}
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			break
 		}
 	}
 
@@ -288,19 +393,70 @@ func (p *Parser) parseSelect() (*Query, error) {
 		if err != nil {
 			return nil, err
 		}
-		if t.Type != TIdentifier {
+		switch t.Type {
+		case TSymOutfile, TString:
+			if p.nesting > 1 {
+				return nil, p.errf(t.From, "OUTFILE in nested query")
+			}
+			if t.Type == TSymOutfile {
+				t, err = p.need(TString)
+				if err != nil {
+					return nil, err
+				}
+			}
+			q.Outfile = &Outfile{
+				Name: t.StrVal,
+			}
+			t, err = p.get()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "FORMAT" {
+				t, err = p.get()
+				if err != nil {
+					return nil, err
+				}
+				if t.Type != TIdentifier && t.Type != TString {
+					return nil, p.errUnexpected(t)
+				}
+				q.Outfile.Format, err = data.ParseFormat(t.StrVal)
+				if err != nil {
+					return nil, p.errf(t.From, "%s", err)
+				}
+			} else {
+				p.lexer.unget(t)
+			}
+			q.Outfile.Filter, err = p.parseKeyword(TSymFilter)
+			if err != nil {
+				return nil, err
+			}
+			err = p.parseOutfilePartition(q.Outfile)
+			if err != nil {
+				return nil, err
+			}
+
+		case TIdentifier:
+			b := q.Global.Get(t.StrVal)
+			if b != nil && b.Type != types.Table {
+				// Scalar variable.
+				q.Into = b
+				q.intoName = t.StrVal
+				break
+			}
+			err = q.Global.Declare(t.StrVal, types.Table, nil)
+			if err != nil {
+				return nil, err
+			}
+			err = q.Global.Set(t.StrVal, types.TableValue{
+				Source: q,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+		default:
 			return nil, p.errUnexpected(t)
 		}
-		err = q.Global.Declare(t.StrVal, types.Table, nil)
-		if err != nil {
-			return nil, err
-		}
-		err = q.Global.Set(t.StrVal, types.TableValue{
-			Source: q,
-		})
-		if err != nil {
-			return nil, err
-		}
 	} else {
 		p.lexer.unget(t)
 	}
@@ -310,22 +466,11 @@ func (p *Parser) parseSelect() (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	var join Expr
 	if t.Type == TSymFrom {
-		for {
-			source, err := p.parseSource(q)
-			if err != nil {
-				return nil, err
-			}
-			q.From = append(q.From, *source)
-
-			t, err := p.get()
-			if err != nil {
-				return nil, err
-			}
-			if t.Type != ',' {
-				p.lexer.unget(t)
-				break
-			}
+		join, err = p.parseFrom(q)
+		if err != nil {
+			return nil, err
 		}
 	} else {
 		p.lexer.unget(t)
@@ -344,6 +489,18 @@ func (p *Parser) parseSelect() (*Query, error) {
 	} else {
 		p.lexer.unget(t)
 	}
+	// The join conditions filter the combined rows with the WHERE
+	// condition.
+	if join != nil {
+		if q.Where == nil {
+			q.Where = join
+		} else {
+			q.Where = &And{
+				Left:  join,
+				Right: q.Where,
+			}
+		}
+	}
 
 	// GROUP BY
 	t, err = p.get()
@@ -359,6 +516,20 @@ func (p *Parser) parseSelect() (*Query, error) {
 		p.lexer.unget(t)
 	}
 
+	// HAVING
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TSymHaving {
+		q.Having, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.lexer.unget(t)
+	}
+
 	// ORDER BY
 	t, err = p.get()
 	if err != nil {
@@ -387,6 +558,17 @@ func (p *Parser) parseSelect() (*Query, error) {
 		p.lexer.unget(t)
 	}
 
+	// TRANSPOSE
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "TRANSPOSE" {
+		q.Transpose = true
+	} else {
+		p.lexer.unget(t)
+	}
+
 	// Terminator.
 	if p.nesting == 1 {
 		_, err = p.optional(';')
@@ -399,6 +581,128 @@ func (p *Parser) parseSelect() (*Query, error) {
 	return q, nil
 }
 
+// parseWildcard parses the SELECT wildcard: "*" or "source.*",
+// followed by the optional EXCEPT (column, ...) list. The function
+// returns nil if the input does not start with a wildcard.
+func (p *Parser) parseWildcard() (*Wildcard, error) {
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	w := new(Wildcard)
+	switch t.Type {
+	case '*':
+
+	case TIdentifier:
+		dot, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if dot.Type != '.' {
+			p.lexer.unget(dot)
+			p.lexer.unget(t)
+			return nil, nil
+		}
+		star, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if star.Type != '*' {
+			p.lexer.unget(star)
+			p.lexer.unget(dot)
+			p.lexer.unget(t)
+			return nil, nil
+		}
+		w.Source = t.StrVal
+
+	default:
+		p.lexer.unget(t)
+		return nil, nil
+	}
+
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type != TIdentifier || strings.ToUpper(t.StrVal) != "EXCEPT" {
+		p.lexer.unget(t)
+		return w, nil
+	}
+	_, err = p.need('(')
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+		p.lexer.unget(t)
+		expr, err := p.parseExprPostfix()
+		if err != nil {
+			return nil, err
+		}
+		ref, ok := expr.(*Reference)
+		if !ok {
+			return nil, p.errf(t.From, "EXCEPT: invalid column: %s", expr)
+		}
+		w.Except = append(w.Except, ref.Reference)
+
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == ')' {
+			return w, nil
+		}
+		if t.Type != ',' {
+			return nil, p.errUnexpected(t)
+		}
+	}
+}
+
+// parseOutfilePartition parses the optional PARTITION BY column, ...
+// clause of the OUTFILE. The outfile name must have the {column}
+// placeholder for each partition column.
+func (p *Parser) parseOutfilePartition(outfile *Outfile) error {
+	t, err := p.get()
+	if err != nil {
+		return err
+	}
+	if t.Type != TIdentifier || strings.ToUpper(t.StrVal) != "PARTITION" {
+		p.lexer.unget(t)
+		return nil
+	}
+	_, err = p.need(TSymBy)
+	if err != nil {
+		return err
+	}
+	for {
+		t, err = p.get()
+		if err != nil {
+			return err
+		}
+		if t.Type != TIdentifier && t.Type != TString {
+			return p.errUnexpected(t)
+		}
+		if !strings.Contains(outfile.Name, "{"+t.StrVal+"}") {
+			return p.errf(t.From,
+				"OUTFILE name '%s' does not have placeholder {%s}",
+				outfile.Name, t.StrVal)
+		}
+		outfile.PartitionBy = append(outfile.PartitionBy, t.StrVal)
+
+		t, err = p.get()
+		if err != nil {
+			return err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseColumn() (*ColumnSelector, error) {
 	expr, err := p.parseExpr()
 	if err != nil {
@@ -414,34 +718,260 @@ func (p *Parser) parseColumn() (*ColumnSelector, error) {
 		if err != nil {
 			return nil, err
 		}
-		if t.Type != TIdentifier {
+		// The quoted identifiers and strings allow aliases with
+		// spaces and other special characters.
+		switch t.Type {
+		case TIdentifier, TString:
+			as = t.StrVal
+		default:
 			return nil, p.errUnexpected(t)
 		}
-		as = t.StrVal
 	} else {
 		p.lexer.unget(t)
 	}
 
-	return &ColumnSelector{
-		Expr: expr,
-		As:   as,
-	}, nil
-}
-
-func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
-	var source types.Source
-	var as string
-
-	t, err := p.get()
+	// Optional datetime format.
+	var format string
+	t, err = p.get()
 	if err != nil {
 		return nil, err
 	}
-	if t.Type == '(' {
-		source, err = p.Parse()
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "FORMAT" {
+		t, err = p.need(TString)
 		if err != nil {
 			return nil, err
 		}
-		as, err = p.parseKeyword(TSymAs)
+		format = t.StrVal
+	} else {
+		p.lexer.unget(t)
+	}
+
+	// Optional column comment.
+	var comment string
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "COMMENT" {
+		t, err = p.need(TString)
+		if err != nil {
+			return nil, err
+		}
+		comment = t.StrVal
+	} else {
+		p.lexer.unget(t)
+	}
+
+	return &ColumnSelector{
+		Expr:    expr,
+		As:      as,
+		Comment: comment,
+		Format:  format,
+	}, nil
+}
+
+// parseFrom parses the FROM sources. The function returns the join
+// conditions of the sources or nil if the sources do not have join
+// conditions.
+func (p *Parser) parseFrom(q *Query) (Expr, error) {
+	var join Expr
+
+	source, err := p.parseSource(q)
+	if err != nil {
+		return nil, err
+	}
+	q.From = append(q.From, *source)
+
+	for {
+		// The sources are separated by commas or by CROSS JOIN
+		// keywords. Both produce the cartesian product of the
+		// sources. The [INNER] JOIN sources have join conditions
+		// and the NATURAL JOIN sources are joined on their common
+		// columns. The FULL [OUTER] JOIN sources keep their join
+		// conditions separate from the WHERE condition.
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		var full bool
+		switch t.Type {
+		case ',':
+
+		case TSymCross:
+			n, err := p.get()
+			if err != nil {
+				return nil, err
+			}
+			if n.Type == TSymApply {
+				source, err := p.parseApply()
+				if err != nil {
+					return nil, err
+				}
+				q.From = append(q.From, *source)
+				continue
+			}
+			if n.Type != TSymJoin {
+				return nil, p.errUnexpected(n)
+			}
+
+		case TSymInner, TSymNatural:
+			_, err = p.need(TSymJoin)
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymJoin:
+
+		case TIdentifier:
+			// FULL and OUTER are not reserved words so that they
+			// remain valid column names.
+			if strings.ToUpper(t.StrVal) != "FULL" {
+				p.lexer.unget(t)
+				return join, nil
+			}
+			n, err := p.get()
+			if err != nil {
+				return nil, err
+			}
+			if n.Type != TIdentifier || strings.ToUpper(n.StrVal) != "OUTER" {
+				p.lexer.unget(n)
+			}
+			_, err = p.need(TSymJoin)
+			if err != nil {
+				return nil, err
+			}
+			full = true
+
+		default:
+			p.lexer.unget(t)
+			return join, nil
+		}
+
+		source, err := p.parseSource(q)
+		if err != nil {
+			return nil, err
+		}
+		q.From = append(q.From, *source)
+
+		if t.Type == TSymNatural {
+			if len(q.From[len(q.From)-2].As) == 0 || len(source.As) == 0 {
+				return nil, p.errf(t.From, "NATURAL JOIN requires source aliases")
+			}
+			q.From[len(q.From)-1].Natural = true
+		}
+		if full {
+			_, err = p.need(TSymOn)
+			if err != nil {
+				return nil, err
+			}
+			cond, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			q.From[len(q.From)-1].Outer = true
+			q.From[len(q.From)-1].On = cond
+		}
+		if t.Type == TSymInner || t.Type == TSymJoin {
+			cond, err := p.parseJoinCondition(q)
+			if err != nil {
+				return nil, err
+			}
+			if join == nil {
+				join = cond
+			} else {
+				join = &And{
+					Left:  join,
+					Right: cond,
+				}
+			}
+		}
+	}
+}
+
+// parseJoinCondition parses the ON or USING join condition of the
+// last source of the query.
+func (p *Parser) parseJoinCondition(q *Query) (Expr, error) {
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	switch t.Type {
+	case TSymOn:
+		return p.parseExpr()
+
+	case TSymUsing:
+		left := &q.From[len(q.From)-2]
+		right := &q.From[len(q.From)-1]
+		if len(left.As) == 0 || len(right.As) == 0 {
+			return nil, p.errf(t.From, "USING requires source aliases")
+		}
+		_, err = p.need('(')
+		if err != nil {
+			return nil, err
+		}
+		var cond Expr
+		for {
+			t, err = p.need(TIdentifier)
+			if err != nil {
+				return nil, err
+			}
+			right.Using = append(right.Using, t.StrVal)
+
+			eq := &Binary{
+				Type: BinEq,
+				Left: &Reference{
+					Reference: types.Reference{
+						Source: left.As,
+						Column: t.StrVal,
+					},
+				},
+				Right: &Reference{
+					Reference: types.Reference{
+						Source: right.As,
+						Column: t.StrVal,
+					},
+				},
+			}
+			if cond == nil {
+				cond = eq
+			} else {
+				cond = &And{
+					Left:  cond,
+					Right: eq,
+				}
+			}
+
+			t, err = p.get()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == ')' {
+				return cond, nil
+			}
+			if t.Type != ',' {
+				return nil, p.errUnexpected(t)
+			}
+		}
+
+	default:
+		return nil, p.errUnexpected(t)
+	}
+}
+
+func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
+	var source types.Source
+	var as string
+
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == '(' {
+		source, err = p.Parse()
+		if err != nil {
+			return nil, err
+		}
+		as, err = p.parseKeyword(TSymAs)
 		if err != nil {
 			return nil, err
 		}
@@ -450,6 +980,21 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 
 		switch t.Type {
 		case TIdentifier:
+			tf := tableFunction(strings.ToUpper(t.StrVal))
+			if tf != nil {
+				n, err := p.get()
+				if err != nil {
+					return nil, err
+				}
+				if n.Type == '(' {
+					source, err = p.parseTableFunction(t, tf)
+					if err != nil {
+						return nil, err
+					}
+					break
+				}
+				p.lexer.unget(n)
+			}
 			b := q.Global.Get(t.StrVal)
 			if b == nil {
 				return nil, p.errf(t.From, "unknown identifier '%s'", t.StrVal)
@@ -517,17 +1062,121 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 	}, nil
 }
 
+// parseApply parses the table function of the CROSS APPLY source.
+func (p *Parser) parseApply() (*SourceSelector, error) {
+	t, err := p.need(TIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	tf := tableFunction(strings.ToUpper(t.StrVal))
+	if tf == nil {
+		return nil, p.errf(t.From, "unknown table function '%s'", t.StrVal)
+	}
+	_, err = p.need('(')
+	if err != nil {
+		return nil, err
+	}
+	var args []Expr
+	for {
+		n, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if n.Type == ')' {
+			break
+		}
+		if len(args) > 0 {
+			if n.Type != ',' {
+				return nil, p.errUnexpected(n)
+			}
+		} else {
+			p.lexer.unget(n)
+		}
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, expr)
+	}
+	apply, source, err := NewApply(tf, args)
+	if err != nil {
+		return nil, p.error(t.From, err)
+	}
+	as, err := p.parseKeyword(TSymAs)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceSelector{
+		Source: source,
+		As:     as,
+		Apply:  apply,
+	}, nil
+}
+
+// parseTableFunction parses the table function arguments and calls
+// the function to create its data source.
+func (p *Parser) parseTableFunction(name *Token, tf *TableFunction) (
+	types.Source, error) {
+
+	var args []types.Value
+
+	q := NewQuery(p.global)
+	for {
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == ')' {
+			break
+		}
+		if len(args) > 0 {
+			if t.Type != ',' {
+				return nil, p.errUnexpected(t)
+			}
+		} else {
+			p.lexer.unget(t)
+		}
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := expr.Bind(q); err != nil {
+			return nil, err
+		}
+		v, err := expr.Eval(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	if len(args) < tf.MinArgs || len(args) > tf.MaxArgs {
+		return nil, p.errf(name.From, "%s: invalid number of arguments: %d",
+			tf.Name, len(args))
+	}
+	source, err := tf.Impl(args)
+	if err != nil {
+		return nil, p.error(name.From, err)
+	}
+	return source, nil
+}
+
 func columnsFor(columns []ColumnSelector,
 	source string) []types.ColumnSelector {
 
 	var result []types.ColumnSelector
 
-	// Collect all referenced columns for the source.
+	// Collect all referenced columns for the source. References to
+	// the aliases of the preceding SELECT columns are not source
+	// columns.
 	seen := make(map[string]bool)
+	aliases := make(map[string]bool)
 	for _, col := range columns {
 		var filtered []types.Reference
 
 		for _, ref := range col.Expr.References() {
+			if len(ref.Source) == 0 && aliases[ref.Column] {
+				continue
+			}
 			if ref.Source == source {
 				if !seen[ref.Column] {
 					filtered = append(filtered, ref)
@@ -541,6 +1190,9 @@ func columnsFor(columns []ColumnSelector,
 				Name: ref,
 			})
 		}
+		if len(col.As) > 0 {
+			aliases[col.As] = true
+		}
 	}
 
 	return result
@@ -747,82 +1399,410 @@ func (p *Parser) parseCreateFunction() error {
 			if err != nil {
 				return err
 			}
-			_, err = p.optional(';')
+			_, err = p.optional(';')
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		p.lexer.unget(t)
+		_, err = p.parseStmt()
+		if err != nil {
+			return err
+		}
+	}
+	_, err = p.need(TSymEnd)
+	if err != nil {
+		return err
+	}
+	_, err = p.optional(';')
+	if err != nil {
+		return err
+	}
+
+	return createFunction(&Function{
+		Name:         name,
+		Args:         args,
+		RetType:      retType,
+		Ret:          ret,
+		MinArgs:      len(args),
+		MaxArgs:      len(args),
+		IsIdempotent: idempotentFalse,
+	})
+}
+
+func (p *Parser) parseDrop() error {
+	t, err := p.get()
+	if err != nil {
+		return err
+	}
+	switch t.Type {
+	case TSymFunction:
+		return p.parseDropFunction()
+
+	default:
+		return p.errUnexpected(t)
+	}
+}
+
+func (p *Parser) parseDropFunction() error {
+	var ifExists bool
+
+	t, err := p.get()
+	if err != nil {
+		return err
+	}
+	if t.Type == TSymIf {
+		_, err = p.need(TSymExists)
+		if err != nil {
+			return err
+		}
+		ifExists = true
+	} else {
+		p.lexer.unget(t)
+	}
+
+	t, err = p.need(TIdentifier)
+	if err != nil {
+		return err
+	}
+	name := strings.ToUpper(t.StrVal)
+
+	_, err = p.optional(';')
+	if err != nil {
+		return err
+	}
+
+	return dropFunction(name, ifExists)
+}
+
+// parseInsert parses the INSERT INTO statement. The statement
+// evaluates its SELECT query and appends the result rows into the
+// target table variable.
+func (p *Parser) parseInsert() error {
+	_, err := p.need(TSymInto)
+	if err != nil {
+		return err
+	}
+	name, target, err := p.parseMaterializedTable("INSERT INTO")
+	if err != nil {
+		return err
+	}
+
+	t, err := p.need(TSymSelect)
+	if err != nil {
+		return err
+	}
+	q, err := p.parseSelect()
+	if err != nil {
+		return err
+	}
+	if q.Outfile != nil {
+		return p.errf(t.From, "INSERT INTO SELECT with OUTFILE")
+	}
+
+	rows, err := target.Source.Get()
+	if err != nil {
+		return err
+	}
+	added, err := q.Get()
+	if err != nil {
+		return err
+	}
+	columns := target.Source.Columns()
+	if len(q.Columns()) != len(columns) {
+		return p.errf(t.From, "INSERT INTO %s: got %d columns, expected %d",
+			name, len(q.Columns()), len(columns))
+	}
+
+	// The column types are resolved from the column values so the
+	// columns without values do not have types yet.
+	typed := make([]bool, len(columns))
+	for _, row := range rows {
+		for i, col := range row {
+			if _, ok := col.(types.NullColumn); !ok {
+				typed[i] = true
+			}
+		}
+	}
+
+	result := make([]types.Row, 0, len(rows)+len(added))
+	result = append(result, rows...)
+	for _, row := range added {
+		converted := make(types.Row, len(row))
+		for i, col := range row {
+			if !typed[i] {
+				converted[i] = col
+				continue
+			}
+			converted[i], err = insertColumn(col, columns[i].Type)
+			if err != nil {
+				return p.errf(t.From, "INSERT INTO %s: column '%s': %s",
+					name, columns[i], err)
+			}
+		}
+		result = append(result, converted)
+	}
+
+	return p.global.Set(name, types.TableValue{
+		Source: data.NewRows(columns, result),
+	})
+}
+
+// insertColumn converts the inserted column col into the type t of
+// the target column. The integer columns accept only integral values.
+func insertColumn(col types.Column, t types.Type) (types.Column, error) {
+	val, err := columnValue(col, t)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value '%s'", t, col)
+	}
+	switch v := val.(type) {
+	case types.NullValue:
+		return types.NullColumn{}, nil
+	case types.IntValue:
+		f, err := col.Float()
+		if err == nil && f != types.FloatValue(v) {
+			return nil, fmt.Errorf("invalid %s value '%s'", t, col)
+		}
+	}
+	return types.NewValueColumn(val), nil
+}
+
+// parseDelete parses the DELETE FROM statement. The statement removes
+// the rows matching its WHERE condition from the target table
+// variable.
+func (p *Parser) parseDelete() error {
+	_, err := p.need(TSymFrom)
+	if err != nil {
+		return err
+	}
+	name, target, err := p.parseMaterializedTable("DELETE FROM")
+	if err != nil {
+		return err
+	}
+	where, err := p.parseOptionalWhere()
+	if err != nil {
+		return err
+	}
+
+	rows, err := target.Source.Get()
+	if err != nil {
+		return err
+	}
+	var kept []types.Row
+	if where != nil {
+		q, err := p.tableQuery(target.Source)
+		if err != nil {
+			return err
+		}
+		if err := where.Bind(q); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			match, err := evalCondition(where, row)
+			if err != nil {
+				return err
+			}
+			if !match {
+				kept = append(kept, row)
+			}
+		}
+	}
+
+	return p.global.Set(name, types.TableValue{
+		Source: data.NewRows(target.Source.Columns(), kept),
+	})
+}
+
+// parseUpdate parses the UPDATE statement. The statement assigns new
+// values to the columns of the target table variable's rows matching
+// its WHERE condition. The assignment expressions are evaluated
+// against the row values before the update.
+func (p *Parser) parseUpdate() error {
+	name, target, err := p.parseMaterializedTable("UPDATE")
+	if err != nil {
+		return err
+	}
+	_, err = p.need(TSymSet)
+	if err != nil {
+		return err
+	}
+
+	columns := target.Source.Columns()
+	var indices []int
+	var exprs []Expr
+	for {
+		t, err := p.need(TIdentifier)
+		if err != nil {
+			return err
+		}
+		index := -1
+		for idx, col := range columns {
+			if col.As == t.StrVal ||
+				(len(col.As) == 0 && col.Name.Column == t.StrVal) {
+				index = idx
+				break
+			}
+		}
+		if index < 0 {
+			return p.errf(t.From, "UPDATE %s: unknown column '%s'",
+				name, t.StrVal)
+		}
+		_, err = p.need('=')
+		if err != nil {
+			return err
+		}
+		expr, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		indices = append(indices, index)
+		exprs = append(exprs, expr)
+
+		t, err = p.get()
+		if err != nil {
+			return err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			break
+		}
+	}
+	where, err := p.parseOptionalWhere()
+	if err != nil {
+		return err
+	}
+
+	rows, err := target.Source.Get()
+	if err != nil {
+		return err
+	}
+	q, err := p.tableQuery(target.Source)
+	if err != nil {
+		return err
+	}
+	for _, expr := range exprs {
+		if err := expr.Bind(q); err != nil {
+			return err
+		}
+	}
+	if where != nil {
+		if err := where.Bind(q); err != nil {
+			return err
+		}
+	}
+
+	var result []types.Row
+	for _, row := range rows {
+		if where != nil {
+			match, err := evalCondition(where, row)
+			if err != nil {
+				return err
+			}
+			if !match {
+				result = append(result, row)
+				continue
+			}
+		}
+		updated := make(types.Row, len(row))
+		copy(updated, row)
+		for i, expr := range exprs {
+			val, err := expr.Eval(&Row{
+				Data: []types.Row{row},
+			}, nil)
 			if err != nil {
 				return err
 			}
-			break
-		}
-
-		p.lexer.unget(t)
-		_, err = p.parseStmt()
-		if err != nil {
-			return err
+			if _, ok := val.(types.NullValue); ok {
+				updated[indices[i]] = types.NullColumn{}
+			} else {
+				updated[indices[i]] = types.NewValueColumn(val)
+			}
 		}
-	}
-	_, err = p.need(TSymEnd)
-	if err != nil {
-		return err
-	}
-	_, err = p.optional(';')
-	if err != nil {
-		return err
+		result = append(result, updated)
 	}
 
-	return createFunction(&Function{
-		Name:         name,
-		Args:         args,
-		RetType:      retType,
-		Ret:          ret,
-		MinArgs:      len(args),
-		MaxArgs:      len(args),
-		IsIdempotent: idempotentFalse,
+	return p.global.Set(name, types.TableValue{
+		Source: data.NewRows(columns, result),
 	})
 }
 
-func (p *Parser) parseDrop() error {
-	t, err := p.get()
+// parseMaterializedTable parses the name of a table variable that is
+// modified by the statement stmt. The table must be a materialized
+// table: a query result or in-memory rows.
+func (p *Parser) parseMaterializedTable(stmt string) (
+	string, types.TableValue, error) {
+
+	t, err := p.need(TIdentifier)
 	if err != nil {
-		return err
+		return "", types.TableValue{}, err
 	}
-	switch t.Type {
-	case TSymFunction:
-		return p.parseDropFunction()
-
+	name := t.StrVal
+	binding := p.global.Get(name)
+	if binding == nil {
+		return "", types.TableValue{},
+			p.errf(t.From, "undefined table '%s'", name)
+	}
+	target, ok := binding.Value.(types.TableValue)
+	if !ok {
+		return "", types.TableValue{},
+			p.errf(t.From, "identifier '%s' is not a table", name)
+	}
+	switch target.Source.(type) {
+	case *Query, *data.Rows:
 	default:
-		return p.errUnexpected(t)
+		return "", types.TableValue{},
+			p.errf(t.From, "%s %s: table is not materialized", stmt, name)
 	}
+	return name, target, nil
 }
 
-func (p *Parser) parseDropFunction() error {
-	var ifExists bool
-
-	t, err := p.get()
+// parseOptionalWhere parses an optional WHERE condition and the
+// statement terminator.
+func (p *Parser) parseOptionalWhere() (Expr, error) {
+	var where Expr
+	t, err := p.optional(TSymWhere)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if t.Type == TSymIf {
-		_, err = p.need(TSymExists)
+	if t != nil {
+		where, err = p.parseExpr()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		ifExists = true
-	} else {
-		p.lexer.unget(t)
 	}
-
-	t, err = p.need(TIdentifier)
+	_, err = p.optional(';')
 	if err != nil {
-		return err
+		return nil, err
 	}
-	name := strings.ToUpper(t.StrVal)
+	return where, nil
+}
 
-	_, err = p.optional(';')
-	if err != nil {
-		return err
+// tableQuery creates a query for binding expressions against the
+// columns of the source.
+func (p *Parser) tableQuery(source types.Source) (*Query, error) {
+	q := NewQuery(p.global)
+	q.From = []SourceSelector{
+		{
+			Source: source,
+		},
+	}
+	if err := q.bind(); err != nil {
+		return nil, err
 	}
+	return q, nil
+}
 
-	return dropFunction(name, ifExists)
+// evalCondition evaluates the bound condition against the table row.
+func evalCondition(cond Expr, row types.Row) (bool, error) {
+	val, err := cond.Eval(&Row{
+		Data: []types.Row{row},
+	}, nil)
+	if err != nil {
+		return false, err
+	}
+	return val.Bool()
 }
 
 func (p *Parser) parseStmt() (int, error) {
@@ -834,7 +1814,28 @@ func (p *Parser) parseExpr() (Expr, error) {
 }
 
 func (p *Parser) parseExprLogicalOr() (Expr, error) {
-	return p.parseExprLogicalAnd()
+	left, err := p.parseExprLogicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != TOr {
+			p.lexer.unget(t)
+			return left, nil
+		}
+		right, err := p.parseExprLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{
+			Left:  left,
+			Right: right,
+		}
+	}
 }
 
 func (p *Parser) parseExprLogicalAnd() (Expr, error) {
@@ -896,15 +1897,46 @@ func (p *Parser) parseExprComparative() (Expr, error) {
 		bt = BinRegexpNEq
 
 	case TSymNot:
-		_, err = p.need(TSymIn)
+		t, err = p.get()
 		if err != nil {
 			return nil, err
 		}
-		return p.parseExprIn(true, left)
+		switch t.Type {
+		case TSymIn:
+			return p.parseExprIn(true, left)
+		case TSymBetween:
+			return p.parseExprBetween(true, left)
+		default:
+			return nil, p.errUnexpected(t)
+		}
 
 	case TSymIn:
 		return p.parseExprIn(false, left)
 
+	case TSymBetween:
+		return p.parseExprBetween(false, left)
+
+	case TSymIs:
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+		var not bool
+		if t.Type == TSymNot {
+			not = true
+			t, err = p.get()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if t.Type != TNull {
+			return nil, p.errUnexpected(t)
+		}
+		return &IsNull{
+			Not:  not,
+			Expr: left,
+		}, nil
+
 	default:
 		p.lexer.unget(t)
 		return left, nil
@@ -920,15 +1952,82 @@ func (p *Parser) parseExprComparative() (Expr, error) {
 	}, nil
 }
 
-func (p *Parser) parseExprIn(not bool, left Expr) (Expr, error) {
-	_, err := p.need('(')
+// parseExprBetween parses the BETWEEN lo AND hi predicate. The
+// predicate is desugared into the comparisons left >= lo AND left <=
+// hi, or into left < lo OR left > hi for NOT BETWEEN.
+func (p *Parser) parseExprBetween(not bool, left Expr) (Expr, error) {
+	lo, err := p.parseExprAdditive()
 	if err != nil {
 		return nil, err
 	}
+	_, err = p.need(TAnd)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := p.parseExprAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if not {
+		return &Or{
+			Left: &Binary{
+				Type:  BinLt,
+				Left:  left,
+				Right: lo,
+			},
+			Right: &Binary{
+				Type:  BinGt,
+				Left:  left,
+				Right: hi,
+			},
+		}, nil
+	}
+	return &And{
+		Left: &Binary{
+			Type:  BinGe,
+			Left:  left,
+			Right: lo,
+		},
+		Right: &Binary{
+			Type:  BinLe,
+			Left:  left,
+			Right: hi,
+		},
+	}, nil
+}
+
+func (p *Parser) parseExprIn(not bool, left Expr) (Expr, error) {
 	t, err := p.get()
 	if err != nil {
 		return nil, err
 	}
+	if t.Type == TIdentifier {
+		b := p.global.Get(t.StrVal)
+		if b != nil && b.Type == types.Table {
+			return &In{
+				Left:      left,
+				Not:       not,
+				Table:     b,
+				TableName: t.StrVal,
+			}, nil
+		}
+	}
+	if t.Type != '(' {
+		p.lexer.unget(t)
+		r, err := p.parseRange()
+		if err != nil {
+			return nil, err
+		}
+		return &In{
+			Left:  left,
+			Not:   not,
+			Range: r,
+		}, nil
+	}
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
 	if t.Type == TSymSelect {
 		q, err := p.parseSelect()
 		if err != nil {
@@ -974,6 +2073,39 @@ func (p *Parser) parseExprIn(not bool, left Expr) (Expr, error) {
 	}, nil
 }
 
+// parseRange parses the `from..to [STEP step]' range.
+func (p *Parser) parseRange() (*Range, error) {
+	from, err := p.parseExprAdditive()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.need(TDotDot)
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.parseExprAdditive()
+	if err != nil {
+		return nil, err
+	}
+	r := &Range{
+		From: from,
+		To:   to,
+	}
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "STEP" {
+		r.Step, err = p.parseExprAdditive()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.lexer.unget(t)
+	}
+	return r, nil
+}
+
 func (p *Parser) parseExprAdditive() (Expr, error) {
 	left, err := p.parseExprMultiplicative()
 	if err != nil {
@@ -1028,6 +2160,9 @@ func (p *Parser) parseExprMultiplicative() (Expr, error) {
 		case '/':
 			bt = BinDiv
 
+		case '%':
+			bt = BinMod
+
 		default:
 			p.lexer.unget(t)
 			return left, nil
@@ -1103,6 +2238,14 @@ func (p *Parser) parseExprPostfix() (Expr, error) {
 		}
 		if n.Type == '(' {
 			return p.parseFunc(t)
+		} else if n.Type == TString && strings.ToUpper(t.StrVal) == "DATE" {
+			d, err := types.ParseDate(n.StrVal)
+			if err != nil {
+				return nil, p.errf(n.From, "invalid date: %s", n.StrVal)
+			}
+			return &Constant{
+				Value: types.DateValue(d),
+			}, nil
 		} else if n.Type == '.' {
 			n, err := p.get()
 			if err != nil {
@@ -1160,6 +2303,9 @@ func (p *Parser) parseExprPostfix() (Expr, error) {
 	case TSymCase:
 		return p.parseCase()
 
+	case TSymInterval:
+		return p.parseInterval()
+
 	case TString:
 		val = types.StringValue(t.StrVal)
 	case TInt:
@@ -1182,6 +2328,9 @@ func (p *Parser) parseExprPostfix() (Expr, error) {
 
 func (p *Parser) parseFunc(name *Token) (Expr, error) {
 	var args []Expr
+	var nulls NullsMode
+	var nullsToken *Token
+	var distinct *Token
 
 	for {
 		t, err := p.get()
@@ -1191,6 +2340,21 @@ func (p *Parser) parseFunc(name *Token) (Expr, error) {
 		if t.Type == ')' {
 			break
 		}
+		if t.Type == TSymDistinct && len(args) == 0 && distinct == nil {
+			// DISTINCT modifier.
+			distinct = t
+			continue
+		}
+		if t.Type == '*' && len(args) == 0 && distinct == nil &&
+			strings.ToUpper(name.StrVal) == "COUNT" {
+			// COUNT(*) counts all rows.
+			args = append(args, &Wildcard{})
+			_, err = p.need(')')
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		p.lexer.unget(t)
 
 		expr, err := p.parseExpr()
@@ -1203,6 +2367,30 @@ func (p *Parser) parseFunc(name *Token) (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
+		if t.Type == TIdentifier {
+			// IGNORE NULLS or RESPECT NULLS modifier.
+			switch strings.ToUpper(t.StrVal) {
+			case "IGNORE":
+				nulls = NullsIgnore
+			case "RESPECT":
+				nulls = NullsRespect
+			default:
+				return nil, p.errUnexpected(t)
+			}
+			nullsToken = t
+			t, err = p.need(TIdentifier)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ToUpper(t.StrVal) != "NULLS" {
+				return nil, p.errUnexpected(t)
+			}
+			_, err = p.need(')')
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		if t.Type != ',' {
 			p.lexer.unget(t)
 		}
@@ -1210,6 +2398,8 @@ func (p *Parser) parseFunc(name *Token) (Expr, error) {
 	call := &Call{
 		Name:      strings.ToUpper(name.StrVal),
 		Arguments: args,
+		Nulls:     nulls,
+		Distinct:  distinct != nil,
 	}
 
 	// Resolve function.
@@ -1217,10 +2407,106 @@ func (p *Parser) parseFunc(name *Token) (Expr, error) {
 	if call.Function == nil {
 		return nil, fmt.Errorf("undefined function: %s", call.Name)
 	}
+	if nulls != NullsDefault && call.Function.Nulls == nil {
+		return nil, p.errf(nullsToken.From, "%s: %s not supported",
+			call.Name, nulls)
+	}
+	if distinct != nil {
+		if call.Function.Distinct == nil || nulls != NullsDefault ||
+			len(args) == 0 {
+			return nil, p.errf(distinct.From, "%s: DISTINCT not supported",
+				call.Name)
+		}
+	}
+
+	// OVER ([PARTITION BY expr, ...] [ORDER BY order, ...])
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "OVER" {
+		if !call.Function.Window {
+			return nil, p.errf(t.From, "%s: OVER requires a window function",
+				call.Name)
+		}
+		call.Over, err = p.parseOver()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.lexer.unget(t)
+	}
 
 	return call, nil
 }
 
+func (p *Parser) parseOver() (*Over, error) {
+	_, err := p.need('(')
+	if err != nil {
+		return nil, err
+	}
+	over := new(Over)
+
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TIdentifier && strings.ToUpper(t.StrVal) == "PARTITION" {
+		over.PartitionBy, err = p.parseGroupBy()
+		if err != nil {
+			return nil, err
+		}
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Type == TSymOrder {
+		over.OrderBy, err = p.parseOrderBy()
+		if err != nil {
+			return nil, err
+		}
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Type != ')' {
+		return nil, p.errUnexpected(t)
+	}
+	return over, nil
+}
+
+func (p *Parser) parseInterval() (Expr, error) {
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	switch t.Type {
+	case TInt:
+		count = t.IntVal
+	case TString:
+		count, err = strconv.ParseInt(strings.TrimSpace(t.StrVal), 10, 64)
+		if err != nil {
+			return nil, p.errf(t.From, "invalid interval: %s", t.StrVal)
+		}
+	default:
+		return nil, p.errUnexpected(t)
+	}
+	unit, err := p.need(TIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	val, err := types.NewInterval(count, unit.StrVal)
+	if err != nil {
+		return nil, p.error(unit.From, err)
+	}
+	return &Constant{
+		Value: val,
+	}, nil
+}
+
 func (p *Parser) parseCase() (Expr, error) {
 	caseExpr := new(Case)
 
@@ -1305,7 +2591,7 @@ func (p *Parser) error(loc Point, err error) error {
 	p.lexer.FlushEOL()
 
 	line, ok := p.lexer.history[loc.Line]
-	if ok {
+	if ok && p.diagnostics != nil {
 		var indicator []rune
 		for i := 0; i < loc.Col; i++ {
 			var r rune
@@ -1317,8 +2603,12 @@ func (p *Parser) error(loc Point, err error) error {
 			indicator = append(indicator, r)
 		}
 		indicator = append(indicator, '^')
-		log.Printf("%s: %s\n%s\n%s\n",
-			loc, err, string(line), string(indicator))
+		p.diagnostics(&Diagnostic{
+			Loc:       loc,
+			Err:       err,
+			Line:      string(line),
+			Indicator: string(indicator),
+		})
 	}
 	return fmt.Errorf("%s: %s", loc, err)
 }
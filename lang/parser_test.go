@@ -10,9 +10,15 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/markkurossi/iql/data"
 	"github.com/markkurossi/iql/types"
 	"github.com/markkurossi/tabulate"
 )
@@ -89,6 +95,34 @@ var parserTests = []struct {
 		v: [][]string{{"true"}},
 	},
 
+	// Text,Pattern
+	// foo bar,^foo
+	// 123,^\d+$
+	// abc,x$
+	{
+		q: `
+SELECT Text, Text ~ Pattern AS Match, Text !~ Pattern AS NoMatch
+FROM 'data:text/csv;base64,VGV4dCxQYXR0ZXJuCmZvbyBiYXIsXmZvbwoxMjMsXlxkKyQKYWJjLHgkCg==';`,
+		v: [][]string{
+			{"foo bar", "true", "false"},
+			{"123", "true", "false"},
+			{"abc", "false", "true"},
+		},
+	},
+
+	// Code,Pattern
+	// 123,^1
+	// 456,^1
+	{
+		q: `
+SELECT Code ~ Pattern
+FROM 'data:text/csv;base64,Q29kZSxQYXR0ZXJuCjEyMyxeMQo0NTYsXjEK';`,
+		v: [][]string{
+			{"true"},
+			{"false"},
+		},
+	},
+
 	// 2008,100
 	// 2009,101
 	// 2010,200
@@ -299,6 +333,113 @@ SELECT data.Year, data.Sum FROM data;`,
 			},
 		},
 	},
+	{
+		q: `
+SELECT Year, Value
+INTO data
+FROM (
+        SELECT "0" AS Year,
+               "1" AS Value
+        FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
+        FILTER 'noheaders'
+     )
+WHERE Year = 2008;
+
+INSERT INTO data
+SELECT Year + 10, Value * 2
+FROM (
+        SELECT "0" AS Year,
+               "1" AS Value
+        FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
+        FILTER 'noheaders'
+     )
+WHERE Year > 2008;
+
+INSERT INTO data SELECT 2030, 1;
+
+SELECT Year, Value FROM data ORDER BY Year DESC;`,
+		v: [][]string{
+			{"2008", "100"},
+		},
+		rest: [][][]string{
+			{
+				{"2030", "1"},
+				{"2020", "400"},
+				{"2019", "202"},
+				{"2008", "100"},
+			},
+		},
+	},
+	{
+		q: `
+SELECT Year, Value
+INTO data
+FROM (
+        SELECT "0" AS Year,
+               "1" AS Value
+        FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
+        FILTER 'noheaders'
+     );
+
+DELETE FROM data WHERE Value > 100 AND Year < 2010;
+SELECT Year, Value FROM data;
+
+DELETE FROM data WHERE Year = 2000;
+SELECT Year, Value FROM data;
+
+DELETE FROM data;
+SELECT Year, Value FROM data;`,
+		v: [][]string{
+			{"2008", "100"},
+			{"2009", "101"},
+			{"2010", "200"},
+		},
+		rest: [][][]string{
+			{
+				{"2008", "100"},
+				{"2010", "200"},
+			},
+			{
+				{"2008", "100"},
+				{"2010", "200"},
+			},
+			{},
+		},
+	},
+	{
+		q: `
+SELECT Year, Value
+INTO data
+FROM (
+        SELECT "0" AS Year,
+               "1" AS Value
+        FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
+        FILTER 'noheaders'
+     );
+
+UPDATE data SET Value = Value * 10, Year = Year + 1 WHERE Value > 100;
+SELECT Year, Value FROM data;
+
+UPDATE data SET Value = NULL WHERE Year = 2008;
+SELECT Year, Value FROM data;`,
+		v: [][]string{
+			{"2008", "100"},
+			{"2009", "101"},
+			{"2010", "200"},
+		},
+		rest: [][][]string{
+			{
+				{"2008", "100"},
+				{"2010", "1010"},
+				{"2011", "2000"},
+			},
+			{
+				{"2008", "NULL"},
+				{"2010", "1010"},
+				{"2011", "2000"},
+			},
+		},
+	},
 
 	// Region,Unit,Count
 	// a,1,200
@@ -324,6 +465,48 @@ FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1M
 			{"c", "1"},
 		},
 	},
+	{
+		q: `
+SELECT COUNT(DISTINCT Region), COUNT(Region), COUNT(DISTINCT Unit),
+       COUNT(DISTINCT Count)
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg==';`,
+		v: [][]string{
+			{"3", "8", "3", "5"},
+		},
+	},
+	{
+		q: `
+SELECT Region, COUNT(DISTINCT Unit)
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+GROUP BY Region;`,
+		v: [][]string{
+			{"a", "2"},
+			{"b", "3"},
+			{"c", "1"},
+		},
+	},
+	{
+		q: `
+SELECT DISTINCT Region
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg==';`,
+		v: [][]string{
+			{"a"},
+			{"b"},
+			{"c"},
+		},
+	},
+	{
+		q: `
+SELECT DISTINCT Region, Unit
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+ORDER BY Unit DESC, Region
+LIMIT 1, 3;`,
+		v: [][]string{
+			{"a", "2"},
+			{"b", "2"},
+			{"a", "1"},
+		},
+	},
 
 	// 1,4.1
 	// 2,4.2
@@ -533,6 +716,73 @@ SELECT Name, Unit, Count FROM (
 			{"c", "1", "7"},
 		},
 	},
+	{
+		q: `
+SELECT NULL IN (1, NULL), 1 IN (NULL), 1 IN (1, NULL),
+       2 NOT IN (1, NULL), 2 NOT IN (1, 3), NULL NOT IN (1);`,
+		v: [][]string{
+			{"NULL", "NULL", "true", "NULL", "true", "NULL"},
+		},
+	},
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	{
+		q: `
+SELECT Ints
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Ints IN (1, 7, NULL);`,
+		v: [][]string{
+			{"1"},
+			{"7"},
+		},
+	},
+	{
+		q: `
+SELECT Ints
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Ints NOT IN (1, 7, NULL);`,
+		v: nil,
+	},
+	{
+		q: `
+SELECT "0" AS Year, "1" AS Value
+FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK' FILTER 'noheaders'
+WHERE "0" IN 2008..2009;`,
+		v: [][]string{
+			{"2008", "100"},
+			{"2009", "101"},
+		},
+	},
+	{
+		q: `
+SELECT Ints
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Ints IN 0..10 STEP 2;`,
+		v: [][]string{
+			{"8"},
+		},
+	},
+	{
+		q: `
+SELECT 5 IN 1..10, 11 IN 1..10, 7 IN 1..10 STEP 3, 5 NOT IN 1..3,
+       1.5 IN 0.5..2.5, 0.75 IN 0..1 STEP 0.25, -3 IN -5..5, NULL IN 1..2;`,
+		v: [][]string{
+			{"true", "false", "true", "true", "true", "true", "true", "NULL"},
+		},
+	},
+	{
+		q: `
+SELECT 0.3 IN 0.1..1.0 STEP 0.1, 0.7 IN 0.1..1.0 STEP 0.1,
+       0.35 IN 0.1..1.0 STEP 0.1, 1.0 IN 0.1..1.0 STEP 0.3;`,
+		v: [][]string{
+			{"true", "true", "false", "true"},
+		},
+	},
 
 	// Ints,Floats,Strings
 	// 1,4.2,foo
@@ -543,6 +793,208 @@ SELECT Name, Unit, Count FROM (
 	// 12,1.234,
 	{
 		q: `
+SELECT COUNT(Ints) AS Count, SUM(Ints) AS Sum, MAX(Floats) AS Max
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+TRANSPOSE;`,
+		v: [][]string{
+			{"Count", "5"},
+			{"Sum", "40"},
+			{"Max", "42.7"},
+		},
+	},
+	{
+		q: `
+SELECT Transpose, Name AS transpose
+FROM ` + "```csv" + `
+Name,Transpose
+a,true
+b,false
+` + "```" + `
+WHERE Transpose
+transpose;`,
+		v: [][]string{
+			{"Transpose", "true"},
+			{"transpose", "a"},
+		},
+	},
+	{
+		q: `SELECT COUNT(*), COUNT(b.Value) FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a, ` + "```csv" + `
+Name,Value
+x,1
+y,2
+z,
+` + "```" + ` AS b;`,
+		v: [][]string{
+			{"9", "6"},
+		},
+	},
+	{
+		q: `SELECT COUNT(*) FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a, ` + "```csv" + `
+Name,Value
+x,1
+y,2
+z,
+` + "```" + ` AS b
+WHERE a.Count = b.Value;`,
+		v: [][]string{
+			{"2"},
+		},
+	},
+	{
+		q: "SELECT COUNT(Name) FROM ```csv" + `
+Name,Count
+a,1
+b,2
+a,3
+c,4
+` + "```" + `
+GROUP BY CASE WHEN Name = 'a' THEN 1 ELSE '1' END;`,
+		v: [][]string{
+			{"2"},
+			{"2"},
+		},
+	},
+	{
+		q: "SELECT Name, Count FROM ```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` WHERE Count > 1;`,
+		v: [][]string{
+			{"b", "2"},
+			{"c", "3"},
+		},
+	},
+	{
+		q: "SELECT SUM(Count) AS Sum FROM ```file:counts.csv" + `
+Name,Count
+a,1
+b,2
+` + "```;",
+		v: [][]string{
+			{"3"},
+		},
+	},
+	{
+		q: "SELECT Name, Price * 2 AS Double, Count + 1 AS Next FROM ```file:decimal.csv" + `
+Name;Price;Count
+a;3,25;1.000
+b;1.234,50;2
+` + "``` FILTER 'comma=; decimal=, grouping=.';",
+		v: [][]string{
+			{"a", "6.5", "1001"},
+			{"b", "2469", "3"},
+		},
+	},
+	{
+		q: `
+SELECT Value AS Line FROM LINES('a
+b
+c');`,
+		v: [][]string{
+			{"a"}, {"b"}, {"c"},
+		},
+	},
+	{
+		q: `
+SELECT Value FROM LINES(CONCAT('a', CHAR(13), CHAR(10), 'b', CHAR(10)));`,
+		v: [][]string{
+			{"a"}, {"b"},
+		},
+	},
+	{
+		q: `
+SELECT l.Value AS Segment, LEN(l.Value) AS Len
+FROM SPLIT_TO_ROWS('alpha;beta;;gamma', ';') AS l
+WHERE LEN(l.Value) > 0;`,
+		v: [][]string{
+			{"alpha", "5"}, {"beta", "4"}, {"gamma", "5"},
+		},
+	},
+	{
+		q: `SELECT value FROM STRING_SPLIT('a,b,c', ',');`,
+		v: [][]string{
+			{"a"}, {"b"}, {"c"},
+		},
+	},
+	{
+		q: `
+SELECT s.value, Count FROM STRING_SPLIT('a;;c', ';') AS s
+JOIN ` + "```csv" + `
+Name,Count
+a,1
+c,3
+` + "```" + ` AS t ON s.value = t.Name;`,
+		v: [][]string{
+			{"a", "1"}, {"c", "3"},
+		},
+	},
+	{
+		q: `
+SELECT t.Name, s.value AS Tag
+FROM ` + "```csv" + `
+Name,Tags
+a,x;y
+b,
+c,z
+` + "```" + ` AS t CROSS APPLY STRING_SPLIT(t.Tags, ';') AS s
+WHERE s.value <> '';`,
+		v: [][]string{
+			{"a", "x"}, {"a", "y"}, {"c", "z"},
+		},
+	},
+	{
+		q: `
+SELECT Name, COUNT(s.value) AS Tags
+FROM ` + "```csv" + `
+Name,Tags
+a,x;y;z
+b,w
+` + "```" + ` AS t CROSS APPLY STRING_SPLIT(Tags, ';') AS s
+GROUP BY Name;`,
+		v: [][]string{
+			{"a", "3"}, {"b", "1"},
+		},
+	},
+	{
+		q: `
+SELECT COUNT(Value) AS Days
+FROM SEQUENCE(DATE '2020-01-01', DATE '2020-01-07', INTERVAL '1' DAY);`,
+		v: [][]string{
+			{"7"},
+		},
+	},
+	{
+		q: `
+SELECT Value
+FROM SEQUENCE('2020-01-31', '2020-04-30', INTERVAL 1 MONTH);`,
+		v: [][]string{
+			{"2020-01-31 00:00:00"},
+			{"2020-02-29 00:00:00"},
+			{"2020-03-31 00:00:00"},
+			{"2020-04-30 00:00:00"},
+		},
+	},
+	{
+		q: `
+SELECT Value FROM SEQUENCE(10, 1, -3);`,
+		v: [][]string{
+			{"10"}, {"7"}, {"4"}, {"1"},
+		},
+	},
+	{
+		q: `
 SELECT Ints, Floats, Strings
 FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
 ORDER BY Ints;`,
@@ -615,8 +1067,7 @@ GROUP BY Name;`,
 	{
 		q: `
 SELECT Name,
-       Unit,
-       AVG(Count) AS Avg
+       SUM(Count) AS Total
 FROM (
 	  SELECT "0" AS Name,
 	         "1" AS Unit,
@@ -624,7 +1075,26 @@ FROM (
 	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
       FILTER 'noheaders'
      )
-GROUP BY Name, Unit;`,
+GROUP BY Name
+HAVING COUNT(Unit) > 2 AND SUM(Count) > 200
+ORDER BY Name;`,
+		v: [][]string{
+			{"a", "350"},
+		},
+	},
+	{
+		q: `
+SELECT Name,
+       Unit,
+       AVG(Count) AS Avg
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+GROUP BY Name, Unit;`,
 		v: [][]string{
 			{"a", "1", "200"},
 			{"a", "2", "75"},
@@ -634,6 +1104,60 @@ GROUP BY Name, Unit;`,
 			{"c", "1", "8"},
 		},
 	},
+	{
+		q: `
+SELECT Unit,
+       COUNT(Name) AS Count
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+GROUP BY Unit;`,
+		v: [][]string{
+			{"1", "4"},
+			{"2", "3"},
+			{"3", "1"},
+		},
+	},
+	{
+		q: `
+SELECT Name,
+       SUM(CASE WHEN Unit = 1 THEN Count END) AS U1,
+       SUM(CASE WHEN Unit = 2 THEN Count END) AS U2,
+       SUM(CASE Unit WHEN 3 THEN Count ELSE 0 END) AS U3,
+       SUM(Count) AS Total
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+GROUP BY Name;`,
+		v: [][]string{
+			{"a", "200", "150", "0", "350"},
+			{"b", "50", "50", "100", "200"},
+			{"c", "17", "0", "0", "17"},
+		},
+	},
+	{
+		q: `
+SELECT SUM(CASE WHEN Name = 'a' THEN Count END) AS A,
+       SUM(CASE WHEN Name <> 'a' THEN Count * 2 END) AS Other
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     );`,
+		v: [][]string{
+			{"350", "434"},
+		},
+	},
 
 	// Ints,Floats,Strings
 	// 1,42.0,foo
@@ -695,6 +1219,562 @@ LIMIT 4, 100;`,
 		},
 	},
 
+	{
+		q: `SELECT 10 % 3, 10.5 % 3, -7 % 3, 7 % -3, 1 + 10 % 4 * 2;`,
+		v: [][]string{{"1", "1.5", "-1", "1", "5"}},
+	},
+
+	{
+		q: `SELECT 1 = 1 OR 1 / 0 = 1, 1 = 2 OR 2 = 2, 1 = 2 OR 2 = 3;`,
+		v: [][]string{{"true", "true", "false"}},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+d,4
+` + "```" + `
+WHERE Count = 1 OR Count = 3 AND Name = 'x';`,
+		v: [][]string{
+			{"a"},
+		},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+d,4
+` + "```" + `
+WHERE Name = 'b' AND Count = 2 OR Name = 'd' OR Count > 10;`,
+		v: [][]string{
+			{"b"},
+			{"d"},
+		},
+	},
+
+	{
+		q: `SELECT Name, Count IS NULL, Count IS NOT NULL FROM ` + "```csv" + `
+Name,Count
+a,1
+b,
+c,3
+` + "```" + ` WHERE Count IS NULL OR Name = 'a';`,
+		v: [][]string{
+			{"a", "false", "true"},
+			{"b", "true", "false"},
+		},
+	},
+	{
+		q: `SELECT Name, Comment, Name AS Comment COMMENT 'name'
+FROM ` + "```csv" + `
+Name,Comment
+a,first
+b,second
+` + "```" + `
+WHERE Comment <> 'first';`,
+		v: [][]string{
+			{"b", "second", "b"},
+		},
+	},
+	{
+		q: `SELECT NULL IS NULL, 1 IS NULL, NULL IS NOT NULL, 1 IS NOT NULL;`,
+		v: [][]string{
+			{"true", "false", "false", "true"},
+		},
+	},
+	{
+		q: `SELECT CAST(Start AS DATETIME) AS Day FORMAT '2006-01-02',
+       CAST(Start AS DATETIME) AS Time FORMAT '15:04'
+FROM ` + "```csv" + `
+Start
+2024-03-01 09:30:00
+2024-12-24 18:05:00
+` + "```" + `;`,
+		v: [][]string{
+			{"2024-03-01", "09:30"},
+			{"2024-12-24", "18:05"},
+		},
+	},
+	{
+		q: `SELECT ROWID(), ROW_NUMBER(), Name FROM ` + "```csv" + `
+Name,Count
+a,3
+b,1
+c,2
+` + "```" + ` WHERE Name <> 'b' ORDER BY Count;`,
+		v: [][]string{
+			{"3", "1", "c"},
+			{"1", "2", "a"},
+		},
+	},
+	{
+		q: `SELECT 5 BETWEEN 1 AND 10, 1 BETWEEN 1 AND 10, 10 BETWEEN 1 AND 10,
+       11 BETWEEN 1 AND 10, 0 NOT BETWEEN 1 AND 10, 5 NOT BETWEEN 1 AND 10;`,
+		v: [][]string{{"true", "true", "true", "false", "true", "false"}},
+	},
+	{
+		q: `SELECT 2.5 BETWEEN 2 AND 3, 3.0 BETWEEN 2.5 AND 3, 1.5 BETWEEN 2 AND 3,
+       'b' BETWEEN 'a' AND 'c', 1 + 1 BETWEEN 2 AND 1 + 2;`,
+		v: [][]string{{"true", "true", "false", "true", "true"}},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,10
+b,50
+c,75
+d,100
+e,120
+` + "```" + `
+WHERE Count BETWEEN 50 AND 100 AND Name <> 'c';`,
+		v: [][]string{
+			{"b"},
+			{"d"},
+		},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,10
+b,50
+c,75
+d,100
+e,120
+` + "```" + `
+WHERE Count NOT BETWEEN 50 AND 100;`,
+		v: [][]string{
+			{"a"},
+			{"e"},
+		},
+	},
+
+	// Column alias tests.
+	{
+		q: `SELECT 1 + 2 AS s, s * 2 AS d, d + s AS e;`,
+		v: [][]string{{"3", "6", "9"}},
+	},
+	{
+		q: `
+DECLARE rate INTEGER;
+SET rate = 3;
+SELECT rate * 2 AS total, 1 AS rate;`,
+		v: [][]string{{"6", "1"}},
+	},
+	{
+		q: `
+SELECT "0" AS Year, "1" AS Value, Value * 2 AS Double
+FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
+FILTER 'noheaders';`,
+		v: [][]string{
+			{"2008", "100", "200"},
+			{"2009", "101", "202"},
+			{"2010", "200", "400"},
+		},
+	},
+
+	// Interval tests.
+	{
+		q: `
+DECLARE d DATETIME;
+SET d = CAST('2020-01-31 10:00:00' AS DATETIME);
+SELECT d + INTERVAL '1' DAY,
+       d - INTERVAL 2 HOUR,
+       INTERVAL '1' YEAR + d,
+       d + INTERVAL '-30' MINUTE;`,
+		v: [][]string{
+			{
+				"2020-02-01 10:00:00",
+				"2020-01-31 08:00:00",
+				"2021-01-31 10:00:00",
+				"2020-01-31 09:30:00",
+			},
+		},
+	},
+	{
+		q: `
+SELECT '2020-02-28' + INTERVAL 1 DAY,
+       '2020-01-15' - INTERVAL 1 MONTH,
+       CAST('2020-03-01 06:00:00' AS DATETIME)
+           - CAST('2020-02-28' AS DATETIME),
+       INTERVAL 1 DAY - INTERVAL 1 HOUR,
+       INTERVAL 25 HOUR > INTERVAL 1 DAY;`,
+		v: [][]string{
+			{
+				"2020-02-29 00:00:00",
+				"2019-12-15 00:00:00",
+				"2 days 06:00:00",
+				"1 day -01:00:00",
+				"true",
+			},
+		},
+	},
+	{
+		q: `
+SELECT DATE '2020-01-31' + INTERVAL 1 MONTH,
+       DATE '2020-03-31' - INTERVAL 1 MONTH,
+       DATE '2020-02-29' + INTERVAL 1 YEAR,
+       Value
+FROM SEQUENCE(DATE '2020-01-31', DATE '2020-02-29', INTERVAL 1 MONTH)
+WHERE Value = DATE '2020-01-31' + INTERVAL 1 MONTH;`,
+		v: [][]string{
+			{
+				"2020-02-29 00:00:00",
+				"2020-02-29 00:00:00",
+				"2021-02-28 00:00:00",
+				"2020-02-29 00:00:00",
+			},
+		},
+	},
+
+	// Window functions.
+	{
+		q: `
+SELECT Count, CUME_DIST() AS CumeDist, PERCENT_RANK() AS PctRank
+FROM (
+	  SELECT "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+ORDER BY Count;`,
+		v: [][]string{
+			{"7", "0.125", "0"},
+			{"10", "0.25", "0.14285714285714285"},
+			{"50", "0.625", "0.2857142857142857"},
+			{"50", "0.625", "0.2857142857142857"},
+			{"50", "0.625", "0.2857142857142857"},
+			{"100", "0.875", "0.7142857142857143"},
+			{"100", "0.875", "0.7142857142857143"},
+			{"200", "1", "1"},
+		},
+	},
+	{
+		q: `SELECT a.Name, Count, Price FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a INNER JOIN ` + "```csv" + `
+Name,Price
+a,10
+c,30
+d,40
+` + "```" + ` AS b ON a.Name = b.Name WHERE Price > 10;`,
+		v: [][]string{
+			{"c", "3", "30"},
+		},
+	},
+	{
+		q: `SELECT a.Name, Count, b.Name, Price FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a FULL OUTER JOIN ` + "```csv" + `
+Name,Price
+a,10
+c,30
+d,40
+` + "```" + ` AS b ON a.Name = b.Name;`,
+		v: [][]string{
+			{"a", "1", "a", "10"},
+			{"b", "2", "NULL", "NULL"},
+			{"c", "3", "c", "30"},
+			{"NULL", "NULL", "d", "40"},
+		},
+	},
+	{
+		q: `SELECT b.Name, Price FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+` + "```" + ` AS a FULL JOIN ` + "```csv" + `
+Name,Price
+a,10
+d,40
+` + "```" + ` AS b ON a.Name = b.Name WHERE Price > 10;`,
+		v: [][]string{
+			{"d", "40"},
+		},
+	},
+	{
+		q: `SELECT a.Full, b.Outer FROM ` + "```csv" + `
+Name,Full
+a,yes
+b,no
+` + "```" + ` AS a full outer join ` + "```csv" + `
+Name,Outer
+a,in
+c,out
+` + "```" + ` AS b ON a.Name = b.Name WHERE Full = 'yes' OR Outer = 'out';`,
+		v: [][]string{
+			{"yes", "in"},
+			{"NULL", "out"},
+		},
+	},
+	{
+		q: `SELECT a.* EXCEPT (a.Secret), b.Price FROM ` + "```csv" + `
+Name,Secret,Count
+a,x,1
+c,y,3
+` + "```" + ` AS a JOIN ` + "```csv" + `
+Name,Price
+a,10
+c,30
+` + "```" + ` AS b ON a.Name = b.Name;`,
+		v: [][]string{
+			{"a", "1", "10"},
+			{"c", "3", "30"},
+		},
+	},
+	{
+		q: `SELECT *, b.* EXCEPT (Name) FROM ` + "```csv" + `
+Name,Count
+a,1
+` + "```" + ` AS a, ` + "```csv" + `
+Name,Price
+a,10
+` + "```" + ` AS b;`,
+		v: [][]string{
+			{"a", "1", "a", "10", "10"},
+		},
+	},
+	{
+		q: `SELECT * FROM ` + "```csv" + `
+A
+1
+` + "```" + ` AS a, ` + "```csv" + `
+B
+2
+` + "```" + ` AS b, ` + "```csv" + `
+C
+3
+` + "```" + ` AS c, ` + "```csv" + `
+D
+4
+5
+` + "```" + ` AS d;`,
+		v: [][]string{
+			{"1", "2", "3", "4"},
+			{"1", "2", "3", "5"},
+		},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + `
+WHERE Count NOT IN (SELECT Value FROM ` + "```csv" + `
+Name,Value
+x,1
+z,3
+` + "```" + `);`,
+		v: [][]string{
+			{"b"},
+		},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + `
+WHERE Count NOT IN (SELECT Value FROM ` + "```csv" + `
+Name,Value
+x,1
+y,
+z,3
+` + "```" + `);`,
+		v: [][]string{},
+	},
+	{
+		q: `SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + `
+WHERE Count IN (SELECT Value FROM ` + "```csv" + `
+Name,Value
+x,1
+y,
+z,3
+` + "```" + `);`,
+		v: [][]string{
+			{"a"},
+			{"c"},
+		},
+	},
+	{
+		q: `SELECT Value INTO lookup FROM ` + "```csv" + `
+Name,Value
+x,1
+z,3
+` + "```" + `;
+SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + `
+WHERE Count IN lookup;
+SELECT Name FROM ` + "```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + `
+WHERE Count NOT IN lookup;`,
+		v: [][]string{
+			{"1"},
+			{"3"},
+		},
+		rest: [][][]string{
+			{
+				{"a"},
+				{"c"},
+			},
+			{
+				{"b"},
+			},
+		},
+	},
+	{
+		q: `SELECT Region, Name,
+       ROW_NUMBER() OVER (PARTITION BY Region ORDER BY Count) AS N,
+       ROW_NUMBER() OVER (PARTITION BY Region ORDER BY Count DESC) AS R,
+       ROW_NUMBER() AS Row
+FROM ` + "```csv" + `
+Region,Name,Count
+east,a,10
+west,b,5
+east,c,30
+west,d,25
+east,e,20
+` + "```" + `
+ORDER BY Region, Name;`,
+		v: [][]string{
+			{"east", "a", "1", "3", "1"},
+			{"east", "c", "3", "1", "2"},
+			{"east", "e", "2", "2", "3"},
+			{"west", "b", "1", "2", "4"},
+			{"west", "d", "2", "1", "5"},
+		},
+	},
+	{
+		q: `SELECT Name, NTILE(2) OVER (ORDER BY Count) AS Tile,
+       PERCENT_RANK() OVER (PARTITION BY Region ORDER BY Count) AS Rank
+FROM ` + "```csv" + `
+Region,Name,Count
+east,a,10
+west,b,5
+east,c,30
+west,d,25
+east,e,20
+` + "```" + `
+ORDER BY Name;`,
+		v: [][]string{
+			{"a", "1", "0"},
+			{"b", "1", "0"},
+			{"c", "2", "1"},
+			{"d", "2", "1"},
+			{"e", "1", "0.5"},
+		},
+	},
+	{
+		q: `SELECT FIRST(Count), FIRST(Count IGNORE NULLS),
+       FIRST(Count RESPECT NULLS), LAST(Count), LAST(Count RESPECT NULLS),
+       COUNT(Count), COUNT(Count RESPECT NULLS)
+FROM ` + "```csv" + `
+Name,Count
+a,
+b,2
+c,3
+d,
+` + "```;",
+		v: [][]string{
+			{"2", "2", "NULL", "3", "NULL", "2", "4"},
+		},
+	},
+	{
+		q: "SELECT Day, Value, FILL_FORWARD(Value) AS Filled FROM ```csv" + `
+Day,Value
+1,
+2,
+3,10
+4,
+5,
+6,20
+7,
+` + "```" + `
+ORDER BY Day;`,
+		v: [][]string{
+			{"1", "NULL", "NULL"},
+			{"2", "NULL", "NULL"},
+			{"3", "10", "10"},
+			{"4", "NULL", "10"},
+			{"5", "NULL", "10"},
+			{"6", "20", "20"},
+			{"7", "NULL", "20"},
+		},
+	},
+	{
+		q: "SELECT Day, Up, RUNLENGTH(Up), RUNLENGTH(Value > 2) FROM ```csv" + `
+Day,Up,Value
+1,true,3
+2,true,1
+3,false,5
+4,true,4
+5,true,
+6,true,6
+7,false,7
+` + "```" + `
+ORDER BY Day;`,
+		v: [][]string{
+			{"1", "true", "1", "1"},
+			{"2", "true", "2", "0"},
+			{"3", "false", "0", "1"},
+			{"4", "true", "1", "2"},
+			{"5", "true", "2", "0"},
+			{"6", "true", "3", "1"},
+			{"7", "false", "0", "2"},
+		},
+	},
+	{
+		q: `SELECT CUME_DIST(), PERCENT_RANK() ORDER BY 1;`,
+		v: [][]string{
+			{"1", "0"},
+		},
+	},
+	{
+		q: `
+SELECT Name, Count, NTILE(4) AS Bucket
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+ORDER BY Count DESC;`,
+		v: [][]string{
+			{"a", "200", "1"},
+			{"a", "100", "1"},
+			{"b", "100", "2"},
+			{"a", "50", "2"},
+			{"b", "50", "3"},
+			{"b", "50", "3"},
+			{"c", "10", "4"},
+			{"c", "7", "4"},
+		},
+	},
+
 	// Functions.
 	{
 		q: `
@@ -766,6 +1846,620 @@ func TestParser(t *testing.T) {
 	}
 }
 
+func TestAliasForwardReference(t *testing.T) {
+	inputs := []string{
+		`SELECT d * 2 AS s, 1 AS d;`,
+		`SELECT s + 1 AS s;`,
+	}
+	for _, input := range inputs {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"forward", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+		}
+		_, err = q.Get()
+		if err == nil {
+			t.Errorf("forward reference not detected:\n%s\n", input)
+		}
+	}
+}
+
+func TestRegexpError(t *testing.T) {
+	// Text,Pattern
+	// abc,^a
+	// abc,(b
+	input := `
+SELECT Text ~ Pattern
+FROM 'data:text/csv;base64,VGV4dCxQYXR0ZXJuCmFiYyxeYQphYmMsKGIK';`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"regexp", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+	}
+	_, err = q.Get()
+	if err == nil {
+		t.Fatalf("invalid regular expression not detected")
+	}
+	if !strings.Contains(err.Error(), "'(b'") {
+		t.Errorf("error does not identify the pattern: %s", err)
+	}
+}
+
+func TestCrossJoin(t *testing.T) {
+	sources := "```csv" + `
+Name,Count
+a,1
+b,2
+` + "```" + ` AS a %s ` + "```csv" + `
+Id,Value
+x,10
+y,20
+z,30
+` + "```" + ` AS b
+WHERE a.Count * 10 <> b.Value
+ORDER BY b.Id, a.Name;`
+
+	var results [][]string
+	for _, sep := range []string{",", "CROSS JOIN", "cross join"} {
+		input := "SELECT * FROM " + fmt.Sprintf(sources, sep)
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"cross", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+		}
+		rows, err := q.Get()
+		if err != nil {
+			t.Fatalf("q.Get failed: %v\nInput:\n%s\n", err, input)
+		}
+		var result []string
+		for _, row := range rows {
+			var cols []string
+			for _, col := range row {
+				cols = append(cols, col.String())
+			}
+			result = append(result, strings.Join(cols, ","))
+		}
+		if len(result) != 4 {
+			t.Errorf("%s: got %d rows, expected 4", sep, len(result))
+		}
+		results = append(results, result)
+	}
+	for i := 1; i < len(results); i++ {
+		if strings.Join(results[i], "\n") != strings.Join(results[0], "\n") {
+			t.Errorf("CROSS JOIN result differs from comma join:\n%v\n%v",
+				results[i], results[0])
+		}
+	}
+}
+
+func TestJoinUsing(t *testing.T) {
+	input := "SELECT * FROM ```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a JOIN ` + "```csv" + `
+Name,Price
+a,10
+c,30
+d,40
+` + "```" + ` AS b USING (Name)
+ORDER BY Name;`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"using", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+	}
+	rows, err := q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v\nInput:\n%s\n", err, input)
+	}
+	var names []string
+	for _, col := range q.Columns() {
+		names = append(names, col.As)
+	}
+	if strings.Join(names, ",") != "Name,a.Count,b.Price" {
+		t.Errorf("unexpected columns: %v", names)
+	}
+	expected := [][]string{
+		{"a", "1", "10"},
+		{"c", "3", "30"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("row %d column %d: got %s, expected %s",
+					i, j, col, expected[i][j])
+			}
+		}
+	}
+}
+
+func TestNaturalJoin(t *testing.T) {
+	input := "SELECT * FROM ```csv" + `
+Name,Count
+a,1
+b,2
+c,3
+` + "```" + ` AS a NATURAL JOIN ` + "```csv" + `
+Price,Name
+10,a
+30,c
+40,d
+` + "```" + ` AS b
+ORDER BY Name;`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"natural", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+	}
+	rows, err := q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v\nInput:\n%s\n", err, input)
+	}
+	var names []string
+	for _, col := range q.Columns() {
+		names = append(names, col.As)
+	}
+	if strings.Join(names, ",") != "Name,a.Count,b.Price" {
+		t.Errorf("unexpected columns: %v", names)
+	}
+	var result []string
+	for _, row := range rows {
+		var cols []string
+		for _, col := range row {
+			cols = append(cols, col.String())
+		}
+		result = append(result, strings.Join(cols, ","))
+	}
+	if strings.Join(result, ";") != "a,1,10;c,3,30" {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	input = "SELECT * FROM ```csv" + `
+Name,Count
+a,1
+` + "```" + ` AS a NATURAL JOIN ` + "```csv" + `
+Id,Price
+a,10
+` + "```" + ` AS b;`
+	parser = NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"natural", os.Stdout)
+	q, err = parser.Parse()
+	if err == nil {
+		_, err = q.Get()
+	}
+	if err == nil {
+		t.Errorf("NATURAL JOIN without common columns succeeded")
+	}
+}
+
+func TestWildcardError(t *testing.T) {
+	for _, q := range []string{
+		"SELECT c.* FROM ```csv\nA,B\n1,2\n``` AS a;",
+		"SELECT a.* EXCEPT (C) FROM ```csv\nA,B\n1,2\n``` AS a;",
+		"SELECT a.* EXCEPT (1) FROM ```csv\nA,B\n1,2\n``` AS a;",
+	} {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(q)),
+			"test", os.Stdout)
+		query, err := parser.Parse()
+		if err == nil {
+			_, err = query.Get()
+		}
+		if err == nil {
+			t.Errorf("invalid wildcard succeeded: %s", q)
+		}
+	}
+}
+
+func TestCrossApplyError(t *testing.T) {
+	for _, q := range []string{
+		"SELECT s.Value FROM ```csv\nA\n1\n``` AS a " +
+			"CROSS APPLY SEQUENCE(1, a.A, 1) AS s;",
+		"SELECT s.value FROM ```csv\nA\n1\n``` AS a " +
+			"CROSS APPLY STRING_SPLIT(s.value, ',') AS s;",
+		"SELECT s.value FROM ```csv\nA\n1\n``` AS a " +
+			"CROSS APPLY STRING_SPLIT(a.A) AS s;",
+		"SELECT s.value FROM ```csv\nA\n1\n``` AS a " +
+			"CROSS APPLY UNKNOWN(a.A) AS s;",
+	} {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(q)),
+			"apply", os.Stdout)
+		parser.SetDiagnosticHandler(nil)
+		query, err := parser.Parse()
+		if err == nil {
+			_, err = query.Get()
+		}
+		if err == nil {
+			t.Errorf("invalid CROSS APPLY succeeded: %s", q)
+		}
+	}
+}
+
+func TestHavingNotGrouped(t *testing.T) {
+	q := "SELECT Name, COUNT(Unit) FROM ```csv\nName,Unit\na,1\n``` " +
+		"GROUP BY Name HAVING Unit > 1;"
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(q)),
+		"having", os.Stdout)
+	parser.SetDiagnosticHandler(nil)
+	query, err := parser.Parse()
+	if err == nil {
+		_, err = query.Get()
+	}
+	if err == nil {
+		t.Errorf("HAVING with non-grouped column succeeded")
+	}
+}
+
+func TestCallModifierError(t *testing.T) {
+	inputs := []string{
+		`SELECT SUM(1) OVER (ORDER BY 1);`,
+		`SELECT ROW_NUMBER() OVER (ORDER BY 1;`,
+		`SELECT SUM(1 RESPECT NULLS);`,
+		`SELECT FIRST(1 IGNORE);`,
+		`SELECT FIRST(1 SKIP NULLS);`,
+		`SELECT SUM(DISTINCT 1);`,
+		`SELECT COUNT(DISTINCT *);`,
+		`SELECT COUNT(DISTINCT 1 RESPECT NULLS);`,
+	}
+	for _, input := range inputs {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"nulls", os.Stdout)
+		parser.SetDiagnosticHandler(nil)
+		_, err := parser.Parse()
+		if err == nil {
+			t.Errorf("invalid call modifier not detected: %s", input)
+		}
+	}
+}
+
+func TestInTableColumns(t *testing.T) {
+	input := `SELECT Name, Value INTO pairs FROM ` + "```csv" + `
+Name,Value
+x,1
+` + "```" + `;
+SELECT 1 WHERE 1 IN pairs;`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"in", os.Stdout)
+	parser.SetDiagnosticHandler(nil)
+	for {
+		q, err := parser.Parse()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return
+		}
+		_, err = q.Get()
+		if err != nil {
+			return
+		}
+	}
+	t.Errorf("IN table with two columns succeeded")
+}
+
+func TestNotMaterialized(t *testing.T) {
+	for _, input := range []string{
+		`DELETE FROM data WHERE a = 1;`,
+		`UPDATE data SET a = 2;`,
+		`INSERT INTO data SELECT 2;`,
+	} {
+		// a
+		// 1
+		source, err := data.New([]string{"data:text/csv;base64,YQoxCg=="},
+			"", nil)
+		if err != nil {
+			t.Fatalf("data.New failed: %v", err)
+		}
+		global := NewScope(nil)
+		global.Declare("data", types.Table, nil)
+		global.Set("data", types.TableValue{
+			Source: source,
+		})
+		parser := NewParser(global, bytes.NewReader([]byte(input)),
+			"materialized", os.Stdout)
+		_, err = parser.Parse()
+		if err == nil || !strings.Contains(err.Error(), "not materialized") {
+			t.Errorf("%s: got error %v, expected not materialized error",
+				input, err)
+		}
+	}
+}
+
+func TestInsertTypes(t *testing.T) {
+	setup := `
+SELECT Name, Count
+INTO data
+FROM ` + "```csv" + `
+Name,Count
+a,1
+` + "```;\n"
+
+	for _, input := range []string{
+		`INSERT INTO data SELECT 'b', 'xyz';`,
+		`INSERT INTO data SELECT 'b', 1.5;`,
+	} {
+		parser := NewParser(NewScope(nil),
+			bytes.NewReader([]byte(setup+input)), "insert", os.Stdout)
+		_, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		_, err = parser.Parse()
+		if err == nil || !strings.Contains(err.Error(), "column 'Count'") {
+			t.Errorf("%s: got error %v, expected column type error",
+				input, err)
+		}
+	}
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(setup+`
+INSERT INTO data SELECT 'b', '2';
+SELECT Name, Count + 1 FROM data;`)), "insert", os.Stdout)
+	var q *Query
+	var err error
+	for i := 0; i < 2; i++ {
+		q, err = parser.Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+	}
+	rows, err := q.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(rows) != 2 || rows[1][1].String() != "3" {
+		t.Errorf("unexpected result: %v", rows)
+	}
+}
+
+func TestOutfileRoundTrip(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	fixture := `'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='`
+	outfile := filepath.Join(t.TempDir(), "out.csv")
+
+	parse := func(input string) *Query {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"outfile", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil && err != io.EOF {
+			t.Fatalf("Parse failed: %v\nInput:\n%s\n", err, input)
+		}
+		return q
+	}
+
+	parse(fmt.Sprintf(`SELECT * INTO OUTFILE '%s' FILTER 'null=NULL' FROM %s;`,
+		outfile, fixture))
+
+	orig := parse(fmt.Sprintf(`SELECT * FROM %s;`, fixture))
+	origRows, err := orig.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v", err)
+	}
+	read := parse(fmt.Sprintf(`SELECT * FROM '%s' FILTER 'null=NULL';`,
+		outfile))
+	readRows, err := read.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v", err)
+	}
+
+	origColumns := orig.Columns()
+	readColumns := read.Columns()
+	if len(origColumns) != len(readColumns) {
+		t.Fatalf("got %d columns, expected %d",
+			len(readColumns), len(origColumns))
+	}
+	for i, col := range origColumns {
+		if col.As != readColumns[i].As || col.Type != readColumns[i].Type {
+			t.Errorf("column %d: got %s %s, expected %s %s", i,
+				readColumns[i].As, readColumns[i].Type, col.As, col.Type)
+		}
+	}
+	if len(origRows) != len(readRows) {
+		t.Fatalf("got %d rows, expected %d", len(readRows), len(origRows))
+	}
+	for i, row := range origRows {
+		for j, col := range row {
+			_, origNull := col.(types.NullColumn)
+			_, readNull := readRows[i][j].(types.NullColumn)
+			if origNull != readNull ||
+				col.String() != readRows[i][j].String() {
+				t.Errorf("row %d column %d: got %s, expected %s",
+					i, j, readRows[i][j], col)
+			}
+		}
+	}
+}
+
+func TestOutfilePartition(t *testing.T) {
+	dir := t.TempDir()
+	// Region,Unit,Count
+	// a,1,200
+	// a,2,100
+	// a,2,50
+	// b,1,50
+	// b,2,50
+	// b,3,100
+	// c,1,10
+	// c,1,7
+	input := fmt.Sprintf(`
+SELECT Region, Unit, Count
+INTO OUTFILE '%s' PARTITION BY Region
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+WHERE Region <> 'c';`,
+		filepath.Join(dir, "out_{Region}.csv"))
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"partition", os.Stdout)
+	_, err := parser.Parse()
+	if err != io.EOF {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"out_a.csv": "Region,Unit,Count\na,1,200\na,2,100\na,2,50\n",
+		"out_b.csv": "Region,Unit,Count\nb,1,50\nb,2,50\nb,3,100\n",
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(expected) {
+		t.Errorf("got %d files, expected %d", len(files), len(expected))
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %s", err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: got %q, expected %q", name, data, content)
+		}
+	}
+
+	// NULL values are written into the NullPartition file.
+	dir = t.TempDir()
+	input = fmt.Sprintf(`
+SELECT NULLIF(Region, 'b') AS Region, Unit
+INTO OUTFILE '%s' PARTITION BY Region
+FROM 'data:text/csv;base64,UmVnaW9uLFVuaXQsQ291bnQKYSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+WHERE Region <> 'c';`,
+		filepath.Join(dir, "out_{Region}.csv"))
+	parser = NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"partition", os.Stdout)
+	_, err = parser.Parse()
+	if err != io.EOF {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected = map[string]string{
+		"out_a.csv":                     "Region,Unit\na,1\na,2\na,2\n",
+		"out_" + NullPartition + ".csv": "Region,Unit\n,1\n,2\n,3\n",
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %s", err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: got %q, expected %q", name, data, content)
+		}
+	}
+
+	// The partition values must not escape the OUTFILE directory.
+	for _, val := range []string{"../x", "a/b", "..", `a\b`} {
+		dir = t.TempDir()
+		input = fmt.Sprintf(`
+SELECT '%s' AS Region INTO OUTFILE '%s' PARTITION BY Region;`,
+			val, filepath.Join(dir, "out", "{Region}.csv"))
+		parser = NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"partition", os.Stdout)
+		parser.SetDiagnosticHandler(nil)
+		_, err = parser.Parse()
+		if err == nil || err == io.EOF {
+			t.Errorf("partition value '%s' succeeded", val)
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 0 {
+			t.Errorf("partition value '%s' created %d files", val,
+				len(files))
+		}
+	}
+
+	// The partition column must have a placeholder in the name.
+	parser = NewParser(NewScope(nil), bytes.NewReader([]byte(
+		`SELECT 1 AS Region INTO OUTFILE 'out.csv' PARTITION BY Region;`)),
+		"partition", os.Stdout)
+	parser.SetDiagnosticHandler(nil)
+	_, err = parser.Parse()
+	if err == nil || err == io.EOF {
+		t.Errorf("PARTITION BY without placeholder succeeded")
+	}
+}
+
+func TestOutfileHTTP(t *testing.T) {
+	var contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed",
+					http.StatusMethodNotAllowed)
+				return
+			}
+			contentType = r.Header.Get("Content-Type")
+			body, _ = ioutil.ReadAll(r.Body)
+		}))
+	defer server.Close()
+
+	tests := []struct {
+		q           string
+		contentType string
+		body        string
+	}{
+		{
+			q: `SELECT 'a' AS Name, 1 AS Count, 2.5 AS Ratio, NULL AS Empty
+INTO '%s/ingest' FORMAT json;`,
+			contentType: "application/json",
+			body: `[{"Name":"a","Count":1,"Ratio":2.5,"Empty":null}]
+`,
+		},
+		{
+			q: `SELECT 'a' AS Name, 1 AS Count
+INTO OUTFILE '%s/ingest.csv' FILTER 'comma=;';`,
+			contentType: "text/csv",
+			body: `Name;Count
+a;1
+`,
+		},
+	}
+	for idx, test := range tests {
+		input := fmt.Sprintf(test.q, server.URL)
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"outfile", os.Stdout)
+		_, err := parser.Parse()
+		if err != nil && err != io.EOF {
+			t.Fatalf("test %d: Parse failed: %v\nInput:\n%s\n", idx, err,
+				input)
+		}
+		if contentType != test.contentType {
+			t.Errorf("test %d: got Content-Type %q, expected %q", idx,
+				contentType, test.contentType)
+		}
+		if string(body) != test.body {
+			t.Errorf("test %d: got body %q, expected %q", idx, body,
+				test.body)
+		}
+	}
+
+	input := fmt.Sprintf(`SELECT 1 AS Count INTO '%s/ingest';`, server.URL)
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"outfile", os.Stdout)
+	_, err := parser.Parse()
+	if err == nil || err == io.EOF {
+		t.Errorf("POST without output format succeeded")
+	}
+}
+
 func verifyResult(t *testing.T, name, source string, q types.Source,
 	v [][]string) {
 	rows, err := q.Get()
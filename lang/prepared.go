@@ -0,0 +1,63 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	_ types.Source = &PreparedQuery{}
+)
+
+// PreparedQuery implements a query that is parsed and bound once and
+// that can be executed multiple times with different parameter
+// values. The parameters are global variables which the query
+// references.
+type PreparedQuery struct {
+	query *Query
+}
+
+// Prepare parses the next query from the parser's input and binds it
+// for repeated execution.
+func (p *Parser) Prepare() (*PreparedQuery, error) {
+	q, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.bind(); err != nil {
+		return nil, err
+	}
+	return &PreparedQuery{
+		query: q,
+	}, nil
+}
+
+// Execute assigns the parameter values to their global variables and
+// evaluates the query. The parameters must be declared variables.
+func (pq *PreparedQuery) Execute(params map[string]types.Value) (
+	[]types.Row, error) {
+
+	for name, value := range params {
+		if err := pq.query.Global.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	pq.query.reset()
+	return pq.query.Get()
+}
+
+// Columns implements the Source.Columns().
+func (pq *PreparedQuery) Columns() []types.ColumnSelector {
+	return pq.query.Columns()
+}
+
+// Get implements the Source.Get(). It returns the result of the
+// latest execution.
+func (pq *PreparedQuery) Get() ([]types.Row, error) {
+	return pq.query.Get()
+}
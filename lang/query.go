@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"math"
 	"os"
-	"sort"
+	"strings"
+	"time"
 
+	"github.com/markkurossi/iql/data"
 	"github.com/markkurossi/iql/types"
 	"github.com/markkurossi/tabulate"
 )
@@ -27,16 +29,44 @@ type Query struct {
 	Select        []ColumnSelector
 	From          []SourceSelector
 	Into          *Binding
+	Outfile       *Outfile
 	Where         Expr
 	GroupBy       []Expr
+	Having        Expr
 	OrderBy       []Order
 	LimitFrom     uint32
 	Limit         uint32
+	Distinct      bool
+	Transpose     bool
 	Global        *Scope
-	fromColumns   map[string]ColumnIndex
+	fromColumns   map[string]*ColumnIndex
+	identNoCase   bool
+	aliases       map[string]Expr
+	bound         bool
+	idempotent    bool
 	evaluated     bool
+	maxInput      int64
+	numInput      int64
 	resultColumns []types.ColumnSelector
 	result        []types.Row
+	warnings      []Warning
+	bindWarnings  int
+	transposed    []types.ColumnSelector
+	intoName      string
+	now           time.Time
+}
+
+// Outfile specifies the output file for the query result. The
+// format FormatUnknown resolves the output format from the file
+// name. If PartitionBy is set, the result is written into one file
+// per distinct values of the partition columns and the Name is a
+// template where the {column} placeholders are replaced with the
+// column values.
+type Outfile struct {
+	Name        string
+	Format      data.Format
+	Filter      string
+	PartitionBy []string
 }
 
 // Order specifies column sorting order.
@@ -50,15 +80,18 @@ func NewQuery(global *Scope) *Query {
 	return &Query{
 		Limit:       math.MaxUint32,
 		Global:      global,
-		fromColumns: make(map[string]ColumnIndex),
+		fromColumns: make(map[string]*ColumnIndex),
 	}
 }
 
-// ColumnSelector defines selected query output columns.
+// ColumnSelector defines selected query output columns. The Format
+// specifies the Go time layout of the column's datetime values.
 type ColumnSelector struct {
-	Expr Expr
-	As   string
-	Type types.Type
+	Expr    Expr
+	As      string
+	Type    types.Type
+	Comment string
+	Format  string
 }
 
 // IsPublic reports if the column is public and should be included in
@@ -82,10 +115,43 @@ func (col ColumnSelector) String() string {
 type SourceSelector struct {
 	Source types.Source
 	As     string
+
+	// Using lists the USING join columns of the source. The join
+	// columns are merged into the columns of the preceding source.
+	Using []string
+
+	// Natural specifies if the source is joined with the preceding
+	// source on their common columns. The common columns are
+	// resolved into Using when the query is bound.
+	Natural bool
+
+	// Outer specifies if the source is full outer joined with the
+	// preceding sources with the join condition On.
+	Outer   bool
+	On      Expr
+	matched []bool
+
+	// Apply specifies the table function of a CROSS APPLY source.
+	// The function is evaluated for each row of the preceding
+	// sources and the Source defines its result columns.
+	Apply *Apply
+}
+
+// IsUsing tests if the column is a USING join column of the source.
+func (s *SourceSelector) IsUsing(column string) bool {
+	for _, u := range s.Using {
+		if u == column {
+			return true
+		}
+	}
+	return false
 }
 
 // Columns implements the Source.Columns().
 func (iql *Query) Columns() []types.ColumnSelector {
+	if iql.Transpose {
+		return iql.transposed
+	}
 	return iql.resultColumns
 }
 
@@ -94,12 +160,237 @@ func (iql *Query) Get() ([]types.Row, error) {
 	if iql.evaluated {
 		return iql.result, nil
 	}
+	if !iql.bound {
+		if err := iql.bind(); err != nil {
+			return nil, err
+		}
+	}
+	// All GETDATE calls of the query execution return the same time.
+	iql.now = time.Now()
+	if err := iql.execute(); err != nil {
+		return nil, err
+	}
+	if iql.Transpose {
+		t := data.NewTranspose(data.NewRows(iql.resultColumns, iql.result))
+		rows, err := t.Get()
+		if err != nil {
+			return nil, err
+		}
+		iql.result = rows
+		iql.transposed = t.Columns()
+	}
+	iql.evaluated = true
+
+	return iql.result, nil
+}
+
+// reset clears the query result and the warnings of the evaluation
+// so that the next Get evaluates the query again. The nested queries
+// of the FROM sources and the IN SELECT subqueries of the expressions
+// are reset too.
+func (iql *Query) reset() {
+	for _, from := range iql.From {
+		nested, ok := from.Source.(*Query)
+		if ok {
+			nested.reset()
+		}
+		resetExpr(from.On)
+		if from.Apply != nil {
+			for _, arg := range from.Apply.Arguments {
+				resetExpr(arg)
+			}
+		}
+	}
+	for _, sel := range iql.Select {
+		resetExpr(sel.Expr)
+	}
+	resetExpr(iql.Where)
+	for _, group := range iql.GroupBy {
+		resetExpr(group)
+	}
+	resetExpr(iql.Having)
+	for _, order := range iql.OrderBy {
+		resetExpr(order.Expr)
+	}
+	iql.evaluated = false
+	iql.result = nil
+	iql.transposed = nil
+	iql.warnings = iql.warnings[:iql.bindWarnings]
+	for i := range iql.resultColumns {
+		iql.resultColumns[i].Type = types.Bool
+	}
+}
+
+// resetExpr resets the IN SELECT subqueries of the expression and its
+// subexpressions.
+func resetExpr(expr Expr) {
+	var subs []Expr
+	switch e := expr.(type) {
+	case *Call:
+		subs = e.Arguments
+		if e.Over != nil {
+			subs = append(subs, e.Over.PartitionBy...)
+			for _, order := range e.Over.OrderBy {
+				subs = append(subs, order.Expr)
+			}
+		}
+	case *Binary:
+		subs = []Expr{e.Left, e.Right}
+	case *In:
+		e.reset()
+	case *Unary:
+		subs = []Expr{e.Expr}
+	case *And:
+		subs = []Expr{e.Left, e.Right}
+	case *Or:
+		subs = []Expr{e.Left, e.Right}
+	case *IsNull:
+		subs = []Expr{e.Expr}
+	case *Cast:
+		subs = []Expr{e.Expr}
+	case *Case:
+		subs = append(subs, e.Input)
+		for _, b := range e.Branches {
+			subs = append(subs, b.When, b.Then)
+		}
+		subs = append(subs, e.Else)
+	}
+	for _, sub := range subs {
+		resetExpr(sub)
+	}
+}
+
+// bindNatural resolves the common columns of the NATURAL JOIN
+// sources and adds their equality conditions into the WHERE
+// condition.
+func (iql *Query) bindNatural() error {
+	for idx := range iql.From {
+		right := &iql.From[idx]
+		if !right.Natural {
+			continue
+		}
+		left := &iql.From[idx-1]
+
+		leftColumns := make(map[string]bool)
+		for _, col := range left.Source.Columns() {
+			leftColumns[col.Name.Column] = true
+			if len(col.As) > 0 {
+				leftColumns[col.As] = true
+			}
+		}
+		right.Using = nil
+		for _, col := range right.Source.Columns() {
+			name := col.Name.Column
+			if len(col.As) > 0 {
+				name = col.As
+			}
+			if !leftColumns[name] {
+				continue
+			}
+			right.Using = append(right.Using, name)
+
+			var eq Expr = &Binary{
+				Type: BinEq,
+				Left: &Reference{
+					Reference: types.Reference{
+						Source: left.As,
+						Column: name,
+					},
+				},
+				Right: &Reference{
+					Reference: types.Reference{
+						Source: right.As,
+						Column: name,
+					},
+				},
+			}
+			if iql.Where != nil {
+				eq = &And{
+					Left:  eq,
+					Right: iql.Where,
+				}
+			}
+			iql.Where = eq
+		}
+		if len(right.Using) == 0 {
+			return fmt.Errorf("NATURAL JOIN: no common columns in %s and %s",
+				left.As, right.As)
+		}
+	}
+	return nil
+}
+
+// expandWildcard returns the source columns selected by the SELECT
+// wildcard. The columns are selected in the FROM order of the sources
+// and in the declaration order of each source's columns. The
+// source-qualified wildcard selects only the columns of its source.
+// The USING join columns are included once, named by their column
+// names.
+func (iql *Query) expandWildcard(w *Wildcard) ([]ColumnSelector, error) {
+	var result []ColumnSelector
+	var found bool
+	excepted := make([]bool, len(w.Except))
+
+	for idx, f := range iql.From {
+		if len(w.Source) > 0 && f.As != w.Source {
+			continue
+		}
+		found = true
+		columns := f.Source.Columns()
+	outer:
+		for _, col := range columns {
+			ref := col.Name
+			if len(f.As) != 0 {
+				ref.Source = f.As
+			}
+			if len(col.As) != 0 {
+				ref.Column = col.As
+			}
+			if f.IsUsing(ref.Column) {
+				continue
+			}
+			for i, except := range w.Except {
+				if except.Column == ref.Column &&
+					(len(except.Source) == 0 || except.Source == ref.Source) {
+					excepted[i] = true
+					continue outer
+				}
+			}
+			var as string
+			if idx+1 < len(iql.From) &&
+				iql.From[idx+1].IsUsing(ref.Column) {
+				as = ref.Column
+			}
+
+			result = append(result, ColumnSelector{
+				Expr: &Reference{
+					Reference: ref,
+				},
+				As: as,
+			})
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: unknown source '%s'", w, w.Source)
+	}
+	for i, ok := range excepted {
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown EXCEPT column '%s'",
+				w, w.Except[i])
+		}
+	}
+	return result, nil
+}
+
+// bind resolves the query's column names and binds its expressions.
+func (iql *Query) bind() error {
+	iql.identNoCase = IdentNoCase(iql.Global)
 
 	// Eval all sources.
 	for sourceIdx, from := range iql.From {
 		_, err := from.Source.Get()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if false {
 			fmt.Printf("Source %d", sourceIdx)
@@ -125,6 +416,15 @@ func (iql *Query) Get() ([]types.Row, error) {
 
 		// Collect column names.
 		for columnIdx, col := range from.Source.Columns() {
+			if len(col.Widened) > 0 {
+				if Strict(iql.Global) {
+					return fmt.Errorf(
+						"column '%s': value '%s' widens type %s to %s",
+						col, col.Widened, col.WidenedFrom, col.Type)
+				}
+				iql.warnf(col.String(), 0, "value '%s' widens type %s to %s",
+					col.Widened, col.WidenedFrom, col.Type)
+			}
 			var columnName string
 			if len(col.As) > 0 {
 				columnName = col.As
@@ -138,7 +438,13 @@ func (iql *Query) Get() ([]types.Row, error) {
 			} else {
 				key = columnName
 			}
-			iql.fromColumns[key] = ColumnIndex{
+			key = iql.columnKey(key)
+			if _, ok := iql.fromColumns[key]; ok && iql.identNoCase {
+				// The names differ only by case.
+				iql.fromColumns[key] = nil
+				continue
+			}
+			iql.fromColumns[key] = &ColumnIndex{
 				Source: sourceIdx,
 				Column: columnIdx,
 				Type:   col.Type,
@@ -146,82 +452,286 @@ func (iql *Query) Get() ([]types.Row, error) {
 		}
 	}
 
-	if len(iql.Select) == 0 {
-		// SELECT *, populate iql.Select from source columns.
-		for _, f := range iql.From {
-			columns := f.Source.Columns()
-			for _, col := range columns {
-				ref := col.Name
-				if len(f.As) != 0 {
-					ref.Source = f.As
-				}
-				if len(col.As) != 0 {
-					ref.Column = col.As
-				}
-
-				iql.Select = append(iql.Select, ColumnSelector{
-					Expr: &Reference{
-						Reference: ref,
-					},
-				})
+	if err := iql.bindNatural(); err != nil {
+		return err
+	}
+	for idx, from := range iql.From {
+		if from.Apply != nil {
+			if err := from.Apply.Bind(iql, idx); err != nil {
+				return err
 			}
 		}
 	}
 
-	// Create column info.
+	// Expand the SELECT wildcards into the source columns.
+	if len(iql.Select) == 0 {
+		iql.Select = []ColumnSelector{{
+			Expr: &Wildcard{},
+		}}
+	}
+	var selects []ColumnSelector
+	for _, sel := range iql.Select {
+		w, ok := sel.Expr.(*Wildcard)
+		if !ok {
+			selects = append(selects, sel)
+			continue
+		}
+		expanded, err := iql.expandWildcard(w)
+		if err != nil {
+			return err
+		}
+		selects = append(selects, expanded...)
+	}
+	iql.Select = selects
+
+	// Create column info. The columns without explicit aliases are
+	// named by their column references, function names, or as
+	// "exprN" for other expressions. The generated names are made
+	// unique by suffixing duplicates with their sequence numbers.
+	names := make(map[string]bool)
+	for _, col := range iql.Select {
+		if !col.IsPublic() {
+			continue
+		}
+		if len(col.As) > 0 {
+			names[col.As] = true
+		} else if _, ok := col.Expr.(*Reference); ok {
+			names[col.Expr.String()] = true
+		}
+	}
+	var numExprs int
 	for _, col := range iql.Select {
 		if !col.IsPublic() {
 			continue
 		}
-		// Promote expressions to aliases unless explicit aliases are
-		// defined.
 		var as string
 		if len(col.As) > 0 {
 			as = col.As
 		} else {
-			as = col.Expr.String()
+			switch expr := col.Expr.(type) {
+			case *Reference:
+				as = expr.String()
+			case *Call:
+				as = uniqueName(names, expr.Name)
+			default:
+				numExprs++
+				as = uniqueName(names, fmt.Sprintf("expr%d", numExprs))
+			}
 		}
 		iql.resultColumns = append(iql.resultColumns, types.ColumnSelector{
 			Name: types.Reference{
 				Column: col.Expr.String(),
 			},
-			As: as,
+			As:      as,
+			Comment: col.Comment,
 		})
 	}
 
-	// Bind SELECT expressions.
-	var idempotent = true
+	// Bind SELECT expressions from left to right. Each column alias
+	// is visible to the expressions following it in the SELECT list.
+	iql.idempotent = true
+	iql.aliases = make(map[string]Expr)
 	for _, sel := range iql.Select {
 		if err := sel.Expr.Bind(iql); err != nil {
-			return nil, err
+			return err
 		}
 		if !sel.Expr.IsIdempotent() {
-			idempotent = false
+			iql.idempotent = false
+		}
+		if len(sel.As) > 0 {
+			iql.aliases[sel.As] = sel.Expr
 		}
 	}
+	iql.aliases = nil
+
+	// The column references without explicit comments inherit the
+	// comments of their source columns.
+	var resultIdx int
+	for _, sel := range iql.Select {
+		if !sel.IsPublic() {
+			continue
+		}
+		ref, ok := sel.Expr.(*Reference)
+		if ok && ref.index != nil &&
+			len(iql.resultColumns[resultIdx].Comment) == 0 {
+			columns := iql.From[ref.index.Source].Source.Columns()
+			iql.resultColumns[resultIdx].Comment =
+				columns[ref.index.Column].Comment
+		}
+		resultIdx++
+	}
+
+	// Bind outer join conditions. The conditions can refer only to
+	// the joined source and to the sources preceding it.
+	for idx, from := range iql.From {
+		if from.On == nil {
+			continue
+		}
+		if err := from.On.Bind(iql); err != nil {
+			return err
+		}
+		for _, ref := range from.On.References() {
+			r, err := iql.resolveName(ref)
+			if err != nil {
+				return err
+			}
+			if r.index != nil && r.index.Source > idx {
+				return fmt.Errorf("invalid reference '%s' in join condition",
+					ref)
+			}
+		}
+	}
+
 	// Bind WHERE expressions.
 	if iql.Where != nil {
 		if err := iql.Where.Bind(iql); err != nil {
-			return nil, err
+			return err
 		}
 	}
 	// Bind GROUP BY expressions.
 	for _, group := range iql.GroupBy {
 		if err := group.Bind(iql); err != nil {
-			return nil, err
+			return err
+		}
+	}
+	// Bind HAVING expression.
+	if iql.Having != nil {
+		if err := iql.Having.Bind(iql); err != nil {
+			return err
 		}
 	}
 	// Bind ORDER BY expressions.
 	for _, order := range iql.OrderBy {
 		if err := order.Expr.Bind(iql); err != nil {
-			return nil, err
+			return err
+		}
+	}
+
+	// Verify that GROUP BY queries select and filter only grouped
+	// columns and aggregates.
+	if len(iql.GroupBy) > 0 && OnlyFullGroupBy(iql.Global) {
+		for _, sel := range iql.Select {
+			if err := iql.checkGrouped(sel.Expr); err != nil {
+				return err
+			}
+		}
+		if iql.Having != nil {
+			if err := iql.checkGrouped(iql.Having); err != nil {
+				return err
+			}
+		}
+	}
+
+	iql.bound = true
+	iql.bindWarnings = len(iql.warnings)
+
+	return nil
+}
+
+// uniqueName returns a name, based on the argument name, that is not
+// in the names set, and adds the returned name into the set.
+func uniqueName(names map[string]bool, name string) string {
+	result := name
+	for i := 2; names[result]; i++ {
+		result = fmt.Sprintf("%s_%d", name, i)
+	}
+	names[result] = true
+	return result
+}
+
+// checkGrouped verifies that the expression is functionally
+// dependent on the GROUP BY expressions. The expression is dependent
+// if it is idempotent (constants, variables, and aggregates), one of
+// the GROUP BY expressions, or if all its subexpressions are
+// dependent.
+func (iql *Query) checkGrouped(expr Expr) error {
+	if expr.IsIdempotent() {
+		return nil
+	}
+	str := expr.String()
+	ref, isRef := expr.(*Reference)
+	for _, group := range iql.GroupBy {
+		if group.String() == str {
+			return nil
+		}
+		gref, ok := group.(*Reference)
+		if isRef && ok && ref.index != nil && gref.index != nil &&
+			ref.index.Source == gref.index.Source &&
+			ref.index.Column == gref.index.Column {
+			return nil
 		}
 	}
 
+	var subs []Expr
+	switch e := expr.(type) {
+	case *Reference:
+		if e.alias != nil {
+			return iql.checkGrouped(e.alias)
+		}
+		return fmt.Errorf("column '%s' must appear in the GROUP BY clause "+
+			"or be used in an aggregate function", e.Reference)
+	case *Call:
+		subs = e.Arguments[e.Function.FirstBound:]
+	case *Binary:
+		subs = []Expr{e.Left, e.Right}
+	case *In:
+		subs = append([]Expr{e.Left}, e.Exprs...)
+	case *Unary:
+		subs = []Expr{e.Expr}
+	case *And:
+		subs = []Expr{e.Left, e.Right}
+	case *Or:
+		subs = []Expr{e.Left, e.Right}
+	case *IsNull:
+		subs = []Expr{e.Expr}
+	case *Cast:
+		subs = []Expr{e.Expr}
+	case *Case:
+		if e.Input != nil {
+			subs = append(subs, e.Input)
+		}
+		for _, b := range e.Branches {
+			subs = append(subs, b.When, b.Then)
+		}
+		if e.Else != nil {
+			subs = append(subs, e.Else)
+		}
+	}
+	for _, sub := range subs {
+		if err := iql.checkGrouped(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execute evaluates the bound query and stores the result rows.
+func (iql *Query) execute() error {
+	// Refresh source column types. The column types of the nested
+	// queries are resolved when the queries are evaluated.
+	for _, from := range iql.From {
+		if _, err := from.Source.Get(); err != nil {
+			return err
+		}
+		if sub, ok := from.Source.(*Query); ok {
+			iql.warnings = append(iql.warnings, sub.Warnings()...)
+		}
+	}
+	for _, index := range iql.fromColumns {
+		if index == nil {
+			continue
+		}
+		columns := iql.From[index.Source].Source.Columns()
+		index.Type = columns[index.Column].Type
+	}
+
+	iql.maxInput = MaxInput(iql.Global)
+	iql.numInput = 0
+
 	var matches []*Row
-	err := iql.eval(0, nil, &matches)
+	err := iql.evalSources(&matches)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Group by.
@@ -231,102 +741,404 @@ func (iql *Query) Get() ([]types.Row, error) {
 		for _, group := range iql.GroupBy {
 			val, err := group.Eval(match, nil)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			key = append(key, val)
 		}
 		grouping.Add(key, match)
 	}
 
-	// Select result columns.
-	matches = nil
-	format := Format(iql.Global)
+	// Collect result rows. Idempotent and GROUP BY queries return
+	// one result per group. The HAVING condition filters the results
+	// and its aggregates are evaluated over the result's group.
+	var results []result
+	single := iql.idempotent || len(iql.GroupBy) > 0
 	for _, group := range grouping.Get() {
 		for _, match := range group {
-			var row types.Row
-			var i int
-			for _, sel := range iql.Select {
-				if !sel.IsPublic() {
-					continue
+			if iql.Having != nil {
+				val, err := iql.Having.Eval(match, group)
+				if err != nil {
+					return err
 				}
-				val, err := sel.Expr.Eval(match, group)
+				ok, err := val.Bool()
 				if err != nil {
-					return nil, err
+					return err
 				}
-				if val == types.Null {
-					row = append(row, types.NullColumn{})
-				} else {
-					if format != nil {
-						val = types.NewFormattedValue(val, format)
+				if !ok {
+					if single {
+						break
 					}
-					row = append(row, types.NewValueColumn(val))
-					iql.resultColumns[i].ResolveValue(val)
+					continue
 				}
-				i++
 			}
-			matches = append(matches, &Row{
-				Data:  []types.Row{row},
-				Order: match.Order,
+			results = append(results, result{
+				match: match,
+				group: group,
 			})
-			// Idempotent and GROUP BY return one result per group.
-			if idempotent || len(iql.GroupBy) > 0 {
+			if single {
 				break
 			}
 		}
 	}
 
 	// Order results.
-	var sortErr error
-	sort.Slice(matches, func(i, j int) bool {
-		o1 := matches[i].Order
-		o2 := matches[j].Order
-		l := len(o1)
-		if len(o2) < l {
-			l = len(o2)
-		}
-		for idx := 0; idx < l; idx++ {
-			var desc bool
-			if idx < len(iql.OrderBy) {
-				desc = iql.OrderBy[idx].Desc
-			}
-			cmp, err := types.Compare(o1[idx], o2[idx])
+	results, err = iql.sortResults(results)
+	if err != nil {
+		return err
+	}
+
+	// Define the result window for window functions.
+	window := &Window{
+		OrderBy: iql.OrderBy,
+		query:   iql,
+	}
+	for idx, r := range results {
+		window.Rows = append(window.Rows, r.match)
+		window.Groups = append(window.Groups, r.group)
+		r.match.Window = window
+		r.match.Index = idx
+	}
+
+	// Select result columns.
+	format := Format(iql.Global)
+	formats := make([]*types.Format, len(iql.Select))
+	for idx, sel := range iql.Select {
+		if len(sel.Format) == 0 {
+			formats[idx] = format
+			continue
+		}
+		f := new(types.Format)
+		if format != nil {
+			*f = *format
+		}
+		f.Date = sel.Format
+		formats[idx] = f
+	}
+	maxRows := MaxRows(iql.Global)
+	var seen map[string]bool
+	if iql.Distinct {
+		seen = make(map[string]bool)
+	}
+	var key []byte
+	var count uint32
+	for _, r := range results {
+		if !iql.Distinct && (count < iql.LimitFrom ||
+			count >= iql.LimitFrom+iql.Limit) {
+			count++
+			continue
+		}
+		var vals []types.Value
+		key = key[:0]
+		for _, sel := range iql.Select {
+			if !sel.IsPublic() {
+				vals = append(vals, nil)
+				continue
+			}
+			val, err := sel.Expr.Eval(r.match, r.group)
 			if err != nil {
-				sortErr = err
-				return true
+				return err
 			}
-			if cmp == 0 {
+			vals = append(vals, val)
+			if iql.Distinct {
+				key = appendGroupKey(key, val)
+			}
+		}
+		if iql.Distinct {
+			// The DISTINCT rows are counted for the LIMIT after
+			// the duplicates are removed.
+			if seen[string(key)] {
 				continue
 			}
-			if cmp < 0 {
-				return !desc
+			seen[string(key)] = true
+			if count < iql.LimitFrom || count >= iql.LimitFrom+iql.Limit {
+				count++
+				continue
 			}
-			return desc
 		}
-		return len(o1) < len(o2)
-	})
-	if sortErr != nil {
-		return nil, sortErr
+		count++
+		if maxRows > 0 && int64(len(iql.result)) >= maxRows {
+			return fmt.Errorf("query result exceeds %s limit %d",
+				SysMaxRows, maxRows)
+		}
+		var row types.Row
+		var i int
+		for idx, sel := range iql.Select {
+			if !sel.IsPublic() {
+				continue
+			}
+			val := vals[idx]
+			if val == types.Null {
+				row = append(row, types.NullColumn{})
+			} else {
+				if formats[idx] != nil {
+					val = types.NewFormattedValue(val, formats[idx])
+				}
+				row = append(row, types.NewValueColumn(val))
+				iql.resultColumns[i].ResolveValue(val)
+			}
+			i++
+		}
+		iql.result = append(iql.result, row)
 	}
 
-	for idx, match := range matches {
-		if uint32(idx) < iql.LimitFrom ||
-			uint32(idx) >= iql.LimitFrom+iql.Limit {
-			continue
+	return nil
+}
+
+// writeOutfile writes the query result into its outfile.
+func (iql *Query) writeOutfile() error {
+	out := iql.Outfile
+	if len(out.PartitionBy) == 0 {
+		return data.Write(out.Name, out.Format, iql, out.Filter)
+	}
+	rows, err := iql.Get()
+	if err != nil {
+		return err
+	}
+	columns := iql.Columns()
+
+	var indices []int
+	for _, name := range out.PartitionBy {
+		idx := -1
+		for i, col := range columns {
+			if col.String() == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("OUTFILE: unknown partition column '%s'", name)
 		}
-		iql.result = append(iql.result, match.Data[0])
+		indices = append(indices, idx)
 	}
 
-	iql.evaluated = true
+	// Bucket the rows by their output files in the first appearance
+	// order of the files.
+	var names []string
+	buckets := make(map[string][]types.Row)
+	for _, row := range rows {
+		name := out.Name
+		for i, idx := range indices {
+			val, err := partitionValue(row[idx])
+			if err != nil {
+				return fmt.Errorf("OUTFILE: partition column '%s': %s",
+					out.PartitionBy[i], err)
+			}
+			name = strings.ReplaceAll(name, "{"+out.PartitionBy[i]+"}", val)
+		}
+		if _, ok := buckets[name]; !ok {
+			names = append(names, name)
+		}
+		buckets[name] = append(buckets[name], row)
+	}
+	for _, name := range names {
+		err = data.Write(name, out.Format, data.NewRows(columns, buckets[name]),
+			out.Filter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return iql.result, nil
+// NullPartition is the OUTFILE partition value of NULL columns.
+const NullPartition = "__NULL__"
+
+// partitionValue returns the file name value of the OUTFILE
+// partition column. The values must not contain path separators or
+// refer to the current or parent directories so that the partitions
+// stay in the OUTFILE directory.
+func partitionValue(col types.Column) (string, error) {
+	if _, ok := col.(types.NullColumn); ok {
+		return NullPartition, nil
+	}
+	val := col.String()
+	if val == "." || val == ".." || strings.ContainsAny(val, "/\\\x00") {
+		return "", fmt.Errorf("invalid partition value '%s'", val)
+	}
+	return val, nil
+}
+
+// GetTyped evaluates the query and returns the result rows as native
+// Go values. The values are converted according to the result column
+// types: BOOLEAN values are bool, INTEGER values are int64, REAL
+// values are float64, DATETIME values are time.Time, and all other
+// values are string. NULL values are nil.
+func (iql *Query) GetTyped() ([][]interface{}, error) {
+	rows, err := iql.Get()
+	if err != nil {
+		return nil, err
+	}
+	columns := iql.Columns()
+
+	var result [][]interface{}
+	for _, row := range rows {
+		var typed []interface{}
+		for idx, col := range row {
+			v, err := nativeValue(col, columns[idx].Type)
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %s", columns[idx], err)
+			}
+			typed = append(typed, v)
+		}
+		result = append(result, typed)
+	}
+	return result, nil
+}
+
+// scalar evaluates the query as a scalar query. The query must
+// return one column and at most one row. The function returns NULL
+// if the query does not return any rows.
+func (iql *Query) scalar() (types.Value, error) {
+	rows, err := iql.Get()
+	if err != nil {
+		return nil, err
+	}
+	columns := iql.Columns()
+	if len(columns) != 1 {
+		return nil, fmt.Errorf("scalar query returned %d columns",
+			len(columns))
+	}
+	switch len(rows) {
+	case 0:
+		return types.Null, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("scalar query returned %d rows", len(rows))
+	}
+	return columnValue(rows[0][0], columns[0].Type)
+}
+
+// columnValue returns the value of the column col as the type t.
+func columnValue(col types.Column, t types.Type) (types.Value, error) {
+	if _, ok := col.(types.NullColumn); ok {
+		return types.Null, nil
+	}
+	switch t {
+	case types.Bool:
+		return col.Bool()
+	case types.Int:
+		return col.Int()
+	case types.Float:
+		return col.Float()
+	case types.Date:
+		d, err := types.ParseDate(col.String())
+		if err != nil {
+			return nil, err
+		}
+		return types.DateValue(d), nil
+	default:
+		return types.StringValue(col.String()), nil
+	}
+}
+
+func nativeValue(col types.Column, t types.Type) (interface{}, error) {
+	if _, ok := col.(types.NullColumn); ok {
+		return nil, nil
+	}
+
+	var val types.Value
+	var err error
+
+	switch t {
+	case types.Bool:
+		val, err = col.Bool()
+	case types.Int:
+		val, err = col.Int()
+	case types.Float:
+		val, err = col.Float()
+	case types.Date:
+		if vc, ok := col.(*types.ValueColumn); ok {
+			return vc.Value().Date()
+		}
+		return types.ParseDate(col.String())
+	default:
+		return col.String(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := val.(type) {
+	case types.BoolValue:
+		return bool(v), nil
+	case types.IntValue:
+		return int64(v), nil
+	case types.FloatValue:
+		return float64(v), nil
+	case types.NullValue:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s value: %s", t, val)
+	}
+}
+
+// evalSources evaluates the query sources and collects the rows
+// matching the WHERE condition into result. The unmatched rows of the
+// outer joined sources are added after the joined rows with NULL
+// values for the preceding sources.
+func (iql *Query) evalSources(result *[]*Row) error {
+	for idx := range iql.From {
+		iql.From[idx].matched = nil
+		if iql.From[idx].Outer {
+			rows, err := iql.From[idx].Source.Get()
+			if err != nil {
+				return err
+			}
+			iql.From[idx].matched = make([]bool, len(rows))
+		}
+	}
+	err := iql.eval(0, nil, result)
+	if err != nil {
+		return err
+	}
+	for idx, from := range iql.From {
+		if !from.Outer {
+			continue
+		}
+		rows, err := from.Source.Get()
+		if err != nil {
+			return err
+		}
+		var data []types.Row
+		for i := 0; i < idx; i++ {
+			data = append(data, nullRow(iql.From[i].Source))
+		}
+		data = data[:len(data):len(data)]
+		for i, row := range rows {
+			if from.matched[i] {
+				continue
+			}
+			err = iql.eval(idx+1, append(data, row), result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nullRow creates a row of NULL values for the source columns.
+func nullRow(source types.Source) types.Row {
+	row := make(types.Row, len(source.Columns()))
+	for i := range row {
+		row[i] = types.NullColumn{}
+	}
+	return row
 }
 
 func (iql *Query) eval(idx int, data []types.Row, result *[]*Row) error {
 
 	if idx >= len(iql.From) {
+		iql.numInput++
+		if iql.maxInput > 0 && iql.numInput > iql.maxInput {
+			return fmt.Errorf("query input exceeds %s limit %d",
+				SysMaxInput, iql.maxInput)
+		}
 		match := true
 		row := &Row{
-			Data: data,
+			Data:    data,
+			Ordinal: iql.numInput,
+			Now:     iql.now,
 		}
 		if iql.Where != nil {
 			val, err := iql.Where.Eval(row, nil)
@@ -353,11 +1165,27 @@ func (iql *Query) eval(idx int, data []types.Row, result *[]*Row) error {
 		return nil
 	}
 
-	rows, err := iql.From[idx].Source.Get()
+	from := &iql.From[idx]
+	var rows []types.Row
+	var err error
+	if from.Apply != nil {
+		rows, err = from.Apply.Get(&Row{
+			Data: data,
+			Now:  iql.now,
+		})
+	} else {
+		rows, err = from.Source.Get()
+	}
 	if err != nil {
 		return err
 	}
+	if from.Outer {
+		return iql.evalOuter(idx, data, rows, result)
+	}
 
+	// Limit the capacity of data so that the rows do not share the
+	// backing array of their source rows.
+	data = data[:len(data):len(data)]
 	for _, row := range rows {
 		err := iql.eval(idx+1, append(data, row), result)
 		if err != nil {
@@ -367,13 +1195,71 @@ func (iql *Query) eval(idx int, data []types.Row, result *[]*Row) error {
 	return nil
 }
 
+// evalOuter joins the rows of the outer joined source idx with the
+// rows of the preceding sources. If none of the rows match the join
+// condition, the preceding rows are joined with NULL values.
+func (iql *Query) evalOuter(idx int, data []types.Row, rows []types.Row,
+	result *[]*Row) error {
+
+	from := &iql.From[idx]
+	data = data[:len(data):len(data)]
+	var found bool
+	for i, row := range rows {
+		joined := append(data, row)
+		val, err := from.On.Eval(&Row{
+			Data: joined,
+			Now:  iql.now,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		match, err := val.Bool()
+		if err != nil {
+			return err
+		}
+		if !match {
+			continue
+		}
+		found = true
+		from.matched[i] = true
+		err = iql.eval(idx+1, joined, result)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return iql.eval(idx+1, append(data, nullRow(from.Source)), result)
+	}
+	return nil
+}
+
+// columnKey returns the fromColumns key for the column name key.
+func (iql *Query) columnKey(key string) string {
+	if iql.identNoCase {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+// columnName returns the source column name of the column index.
+func (iql *Query) columnName(index *ColumnIndex) string {
+	col := iql.From[index.Source].Source.Columns()[index.Column]
+	if len(col.As) > 0 {
+		return col.As
+	}
+	return col.Name.Column
+}
+
 func (iql *Query) resolveName(name types.Reference) (*Reference, error) {
 
 	if name.IsAbsolute() {
-		index, ok := iql.fromColumns[name.String()]
+		index, ok := iql.fromColumns[iql.columnKey(name.String())]
 		if !ok {
 			return nil, fmt.Errorf("undefined column '%s'", name)
 		}
+		if index == nil {
+			return nil, fmt.Errorf("ambiguous column name '%s'", name)
+		}
 		return &Reference{
 			Reference: name,
 			index:     index,
@@ -387,8 +1273,16 @@ func (iql *Query) resolveName(name types.Reference) (*Reference, error) {
 			Source: from.As,
 			Column: name.Column,
 		}
-		index, ok := iql.fromColumns[key.String()]
+		index, ok := iql.fromColumns[iql.columnKey(key.String())]
 		if ok {
+			if index == nil {
+				return nil, fmt.Errorf("ambiguous column name '%s'", name)
+			}
+			if match != nil && from.IsUsing(iql.columnName(index)) {
+				// The USING join column equals the column of the
+				// preceding source.
+				continue
+			}
 			if match != nil {
 				return nil, fmt.Errorf("ambiguous column name '%s'", name)
 			}
@@ -402,6 +1296,17 @@ func (iql *Query) resolveName(name types.Reference) (*Reference, error) {
 		return match, nil
 	}
 
+	// Check SELECT column aliases.
+	if iql.aliases != nil {
+		expr, ok := iql.aliases[name.Column]
+		if ok {
+			return &Reference{
+				Reference: name,
+				alias:     expr,
+			}, nil
+		}
+	}
+
 	// Check variables.
 	b := iql.Global.Get(name.Column)
 	if b != nil {
@@ -413,5 +1318,15 @@ func (iql *Query) resolveName(name types.Reference) (*Reference, error) {
 		}, nil
 	}
 
+	// The aliases of the following SELECT columns are not visible.
+	if iql.aliases != nil {
+		for _, sel := range iql.Select {
+			if sel.As == name.Column {
+				return nil, fmt.Errorf("forward reference to column '%s'",
+					name)
+			}
+		}
+	}
+
 	return nil, fmt.Errorf("undefined identifier '%s'", name)
 }
@@ -0,0 +1,348 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+func TestGetTyped(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	input := `
+SELECT Ints, Floats, Strings, Ints > 5, CAST('2021-03-15' AS DATETIME)
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+ORDER BY Ints
+LIMIT 2;`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"typed", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	rows, err := q.GetTyped()
+	if err != nil {
+		t.Fatalf("GetTyped failed: %v", err)
+	}
+	date := time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)
+	expected := [][]interface{}{
+		{nil, 2.75, "x", nil, date},
+		{int64(1), 4.2, "foo", false, date},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		for j, v := range row {
+			e := expected[i][j]
+			if et, ok := e.(time.Time); ok {
+				vt, ok := v.(time.Time)
+				if !ok || !vt.Equal(et) {
+					t.Errorf("row %d column %d: got %v{%T}, expected %v",
+						i, j, v, v, e)
+				}
+				continue
+			}
+			if v != e {
+				t.Errorf("row %d column %d: got %v{%T}, expected %v{%T}",
+					i, j, v, v, e, e)
+			}
+		}
+	}
+}
+
+func TestGetDateConsistent(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	input := `
+SELECT GETDATE() AS A, GETDATE() AS B, NOW() AS C
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo=';`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"getdate", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	rows, err := q.GetTyped()
+	if err != nil {
+		t.Fatalf("GetTyped failed: %v", err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("got %d rows, expected 6", len(rows))
+	}
+	now, ok := rows[0][0].(time.Time)
+	if !ok {
+		t.Fatalf("got %v{%T}, expected time.Time", rows[0][0], rows[0][0])
+	}
+	for i, row := range rows {
+		for j, v := range row {
+			vt, ok := v.(time.Time)
+			if !ok || !vt.Equal(now) {
+				t.Errorf("row %d column %d: got %v, expected %v",
+					i, j, v, now)
+			}
+		}
+	}
+}
+
+func TestCaseTypeUnification(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	input := `
+SELECT CASE WHEN Ints > 7 THEN Ints ELSE 0.5 END AS Value
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Ints <> NULL;`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"case", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	rows, err := q.GetTyped()
+	if err != nil {
+		t.Fatalf("GetTyped failed: %v", err)
+	}
+	if q.Columns()[0].Type != types.Float {
+		t.Errorf("got column type %s, expected %s", q.Columns()[0].Type,
+			types.Float)
+	}
+	expected := []float64{0.5, 12, 0.5, 8, 12}
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		if row[0] != expected[i] {
+			t.Errorf("row %d: got %v{%T}, expected %v", i, row[0], row[0],
+				expected[i])
+		}
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	input := `
+SELECT Ints, Ints * 2, Floats + 1, UPPER(Strings), UPPER(Strings),
+       Strings AS expr2
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo=';`
+
+	parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+		"names", os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := q.Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	expected := []string{
+		"Ints", "expr1", "expr2_2", "UPPER", "UPPER_2", "expr2",
+	}
+	columns := q.Columns()
+	if len(columns) != len(expected) {
+		t.Fatalf("got %d columns, expected %d", len(columns), len(expected))
+	}
+	for i, col := range columns {
+		if col.As != expected[i] {
+			t.Errorf("column %d: got %s, expected %s", i, col.As, expected[i])
+		}
+	}
+}
+
+func TestSelectStarOrder(t *testing.T) {
+	// Ints,Floats,Strings
+	// 1,4.2,foo
+	// 12,42.7,bar
+	// 7,3.1415,zappa
+	// ,2.75,x
+	// 8,,y
+	// 12,1.234,
+	input := `
+SELECT *
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+WHERE Strings <> 'x' AND Ints > 1;`
+
+	expected := []string{"Ints", "Floats", "Strings"}
+	for i := 0; i < 10; i++ {
+		parser := NewParser(NewScope(nil), bytes.NewReader([]byte(input)),
+			"star", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if _, err := q.Get(); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		columns := q.Columns()
+		if len(columns) != len(expected) {
+			t.Fatalf("got %d columns, expected %d", len(columns),
+				len(expected))
+		}
+		for j, col := range columns {
+			if col.As != expected[j] {
+				t.Errorf("run %d: column %d: got %s, expected %s",
+					i, j, col.As, expected[j])
+			}
+		}
+	}
+}
+
+var preparedQuery = `
+SELECT Name, Count
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count > min;`
+
+var preparedNestedQuery = `
+SELECT Name, Count
+FROM (
+      SELECT Name, Count
+      FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+      WHERE Count > min
+     );`
+
+var preparedInQuery = `
+SELECT Name, Count
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count IN (
+      SELECT Count
+      FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+      WHERE Count > min
+     );`
+
+func TestPreparedWarnings(t *testing.T) {
+	global := NewScope(nil)
+	InitSystemVariables(global)
+	global.Declare("min", types.Int, nil)
+
+	input := `
+SELECT 9223372036854775807 + Count
+FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMsMwpkLDQK'
+WHERE Count > min;`
+
+	parser := NewParser(global, bytes.NewReader([]byte(input)),
+		"prepared", os.Stdout)
+	pq, err := parser.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for min := 0; min <= 4; min++ {
+		_, err := pq.Execute(map[string]types.Value{
+			"min": types.IntValue(min),
+		})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		warnings := pq.query.Warnings()
+		if len(warnings) != 4-min {
+			t.Errorf("min=%d: got %d warnings, expected %d: %v",
+				min, len(warnings), 4-min, warnings)
+		}
+	}
+}
+
+func TestPrepared(t *testing.T) {
+	for _, input := range []string{
+		preparedQuery, preparedNestedQuery, preparedInQuery,
+	} {
+		testPrepared(t, input)
+	}
+}
+
+func testPrepared(t *testing.T, input string) {
+	global := NewScope(nil)
+	global.Declare("min", types.Int, nil)
+
+	parser := NewParser(global, bytes.NewReader([]byte(input)),
+		"prepared", os.Stdout)
+	pq, err := parser.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for min := 0; min <= 4; min++ {
+		rows, err := pq.Execute(map[string]types.Value{
+			"min": types.IntValue(min),
+		})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if len(rows) != 4-min {
+			t.Errorf("min=%d: got %d rows, expected %d", min, len(rows),
+				4-min)
+		}
+		if len(pq.Columns()) != 2 {
+			t.Errorf("min=%d: got %d columns, expected 2", min,
+				len(pq.Columns()))
+		}
+	}
+}
+
+func BenchmarkParseGet(b *testing.B) {
+	global := NewScope(nil)
+	global.Declare("min", types.Int, nil)
+
+	for i := 0; i < b.N; i++ {
+		global.Set("min", types.IntValue(i%4))
+		parser := NewParser(global, bytes.NewReader([]byte(preparedQuery)),
+			"prepared", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			b.Fatalf("Parse failed: %v", err)
+		}
+		_, err = q.Get()
+		if err != nil {
+			b.Fatalf("Get failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkPrepareExecute(b *testing.B) {
+	global := NewScope(nil)
+	global.Declare("min", types.Int, nil)
+
+	parser := NewParser(global, bytes.NewReader([]byte(preparedQuery)),
+		"prepared", os.Stdout)
+	pq, err := parser.Prepare()
+	if err != nil {
+		b.Fatalf("Prepare failed: %v", err)
+	}
+	for i := 0; i < b.N; i++ {
+		_, err = pq.Execute(map[string]types.Value{
+			"min": types.IntValue(i % 4),
+		})
+		if err != nil {
+			b.Fatalf("Execute failed: %v", err)
+		}
+	}
+}
@@ -62,22 +62,27 @@ func (scope *Scope) Set(name string, v types.Value) error {
 		b, ok := s.Symbols[name]
 		if ok {
 			// Set new binding for this scope.
-			if !b.Type.CanAssign(v) {
-				return fmt.Errorf("can't assign '%s' to '%s' variable",
-					v, b.Type)
-			}
-			if b.Verify != nil {
-				if err := b.Verify(name, b.Type, v); err != nil {
-					return err
-				}
-			}
-			b.Value = v
-			return nil
+			return b.Set(name, v)
 		}
 	}
 	return fmt.Errorf("unknown identifier '%s'", name)
 }
 
+// Set sets the value of the binding. The name specifies the binding
+// name for the value verification.
+func (b *Binding) Set(name string, v types.Value) error {
+	if !b.Type.CanAssign(v) {
+		return fmt.Errorf("can't assign '%s' to '%s' variable", v, b.Type)
+	}
+	if b.Verify != nil {
+		if err := b.Verify(name, b.Type, v); err != nil {
+			return err
+		}
+	}
+	b.Value = v
+	return nil
+}
+
 // Get gets the name binding from the scope.
 func (scope *Scope) Get(name string) *Binding {
 	name = strings.ToUpper(name)
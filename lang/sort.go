@@ -0,0 +1,518 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// result defines a query result row and the group it represents.
+type result struct {
+	match *Row
+	group []*Row
+}
+
+// sortResults sorts the query results by their ORDER BY values. If
+// the number of results exceeds the SORTBUFFER limit, the results are
+// sorted with an external merge sort which spills the sorted runs
+// into temporary files.
+func (iql *Query) sortResults(results []result) ([]result, error) {
+	buffer := SortBuffer(iql.Global)
+	if buffer > 0 && int64(len(results)) > buffer {
+		return iql.externalSort(results, int(buffer))
+	}
+
+	var sortErr error
+	sort.Slice(results, func(i, j int) bool {
+		cmp, err := iql.compareOrder(results[i].match.Order,
+			results[j].match.Order)
+		if err != nil {
+			sortErr = err
+			return true
+		}
+		return cmp < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return results, nil
+}
+
+// compareOrder compares the ORDER BY values of two result rows.
+func (iql *Query) compareOrder(o1, o2 []types.Value) (int, error) {
+	return iql.compareOrderBy(iql.OrderBy, o1, o2)
+}
+
+// compareOrderBy compares the order values of two rows with the
+// sorting directions of orderBy.
+func (iql *Query) compareOrderBy(orderBy []Order, o1, o2 []types.Value) (
+	int, error) {
+
+	collation := Collation(iql.Global)
+
+	l := len(o1)
+	if len(o2) < l {
+		l = len(o2)
+	}
+	for idx := 0; idx < l; idx++ {
+		cmp, err := types.CompareCollation(o1[idx], o2[idx], collation)
+		if err != nil {
+			return 0, err
+		}
+		if cmp == 0 {
+			continue
+		}
+		if idx < len(orderBy) && orderBy[idx].Desc {
+			return -cmp, nil
+		}
+		return cmp, nil
+	}
+	return len(o1) - len(o2), nil
+}
+
+// sortValue defines the serialized form of a value. The Format
+// holds the formatting options of formatted values.
+type sortValue struct {
+	Type     types.Type
+	Bool     bool
+	Int      int64
+	Float    float64
+	Date     time.Time
+	String   string
+	Interval types.IntervalValue
+	Format   *types.Format
+}
+
+func newSortValue(v types.Value) (sv sortValue, err error) {
+	if fv, ok := v.(*types.FormattedValue); ok {
+		sv, err = newSortValue(fv.Value())
+		sv.Format = fv.Format()
+		return
+	}
+	sv.Type = v.Type()
+	switch sv.Type {
+	case types.Bool:
+		sv.Bool, err = v.Bool()
+	case types.Int:
+		sv.Int, err = v.Int()
+	case types.Float:
+		sv.Float, err = v.Float()
+	case types.Date:
+		sv.Date, err = v.Date()
+	case types.String:
+		sv.String = v.String()
+	case types.Interval:
+		iv, ok := v.(types.IntervalValue)
+		if !ok {
+			err = fmt.Errorf("unsupported sort value: %v{%T}", v, v)
+		}
+		sv.Interval = iv
+	case types.Any:
+		if _, ok := v.(types.NullValue); !ok {
+			err = fmt.Errorf("unsupported sort value: %v{%T}", v, v)
+		}
+	default:
+		err = fmt.Errorf("unsupported sort value type: %s", sv.Type)
+	}
+	return
+}
+
+// Value returns the sort value as types.Value.
+func (sv sortValue) Value() types.Value {
+	var v types.Value
+	switch sv.Type {
+	case types.Bool:
+		v = types.BoolValue(sv.Bool)
+	case types.Int:
+		v = types.IntValue(sv.Int)
+	case types.Float:
+		v = types.FloatValue(sv.Float)
+	case types.Date:
+		v = types.DateValue(sv.Date)
+	case types.String:
+		v = types.StringValue(sv.String)
+	case types.Interval:
+		v = sv.Interval
+	default:
+		return types.Null
+	}
+	if sv.Format != nil {
+		return types.NewFormattedValue(v, sv.Format)
+	}
+	return v
+}
+
+// Serialized column kinds.
+const (
+	sortColumnNull = iota
+	sortColumnValue
+	sortColumnString
+	sortColumnStrings
+)
+
+// sortColumn defines the serialized form of a source column.
+type sortColumn struct {
+	Kind    int
+	Value   sortValue
+	String  string
+	Strings []string
+}
+
+func newSortColumn(col types.Column) (sc sortColumn, err error) {
+	switch c := col.(type) {
+	case types.NullColumn:
+		sc.Kind = sortColumnNull
+	case *types.ValueColumn:
+		sc.Kind = sortColumnValue
+		sc.Value, err = newSortValue(c.Value())
+	case types.StringColumn:
+		sc.Kind = sortColumnString
+		sc.String = string(c)
+	case types.StringsColumn:
+		sc.Kind = sortColumnStrings
+		sc.Strings = c
+	default:
+		err = fmt.Errorf("unsupported sort column: %v{%T}", col, col)
+	}
+	return
+}
+
+// Column returns the serialized column as types.Column.
+func (sc sortColumn) Column() types.Column {
+	switch sc.Kind {
+	case sortColumnValue:
+		return types.NewValueColumn(sc.Value.Value())
+	case sortColumnString:
+		return types.StringColumn(sc.String)
+	case sortColumnStrings:
+		return types.StringsColumn(sc.Strings)
+	default:
+		return types.NullColumn{}
+	}
+}
+
+// sortRow defines the serialized form of a query row.
+type sortRow struct {
+	Data    [][]sortColumn
+	Order   []sortValue
+	Ordinal int64
+	Now     time.Time
+}
+
+func newSortRow(row *Row) (sr sortRow, err error) {
+	sr.Ordinal = row.Ordinal
+	sr.Now = row.Now
+	for _, data := range row.Data {
+		var cols []sortColumn
+		for _, col := range data {
+			sc, err := newSortColumn(col)
+			if err != nil {
+				return sr, err
+			}
+			cols = append(cols, sc)
+		}
+		sr.Data = append(sr.Data, cols)
+	}
+	for _, v := range row.Order {
+		sv, err := newSortValue(v)
+		if err != nil {
+			return sr, err
+		}
+		sr.Order = append(sr.Order, sv)
+	}
+	return
+}
+
+// Row returns the serialized row as a query row.
+func (sr sortRow) Row() *Row {
+	row := &Row{
+		Ordinal: sr.Ordinal,
+		Now:     sr.Now,
+	}
+	for _, cols := range sr.Data {
+		data := make(types.Row, len(cols))
+		for i, sc := range cols {
+			data[i] = sc.Column()
+		}
+		row.Data = append(row.Data, data)
+	}
+	for _, sv := range sr.Order {
+		row.Order = append(row.Order, sv.Value())
+	}
+	return row
+}
+
+// sortResult defines the serialized form of a result. The results
+// can share their groups so the groups are spilled into a separate
+// file and the results reference them by their group IDs.
+type sortResult struct {
+	Match sortRow
+	Group int
+}
+
+// externalSort sorts the results in runs of buffer rows. Each sorted
+// run is spilled into a temporary file and released from memory. The
+// runs are read back from the files and k-way merged into the final
+// result order.
+func (iql *Query) externalSort(results []result, buffer int) (
+	[]result, error) {
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	f, err := os.CreateTemp("", "iql-sort-*")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, f)
+	groups := &sortGroups{
+		file: f,
+		enc:  gob.NewEncoder(f),
+		ids:  make(map[*Row]int),
+	}
+
+	var runs []*sortRun
+	count := len(results)
+	for start := 0; start < count; start += buffer {
+		end := start + buffer
+		if end > count {
+			end = count
+		}
+		run, err := iql.spillRun(results[start:end], groups)
+		if run != nil {
+			files = append(files, run.file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	results = nil
+
+	merge := &sortMerge{
+		query: iql,
+	}
+	merge.groups, err = groups.read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge runs.
+	for _, run := range runs {
+		ok, err := run.next(merge.groups)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			merge.runs = append(merge.runs, run)
+		}
+	}
+	heap.Init(merge)
+
+	sorted := make([]result, 0, count)
+	for merge.Len() > 0 {
+		if merge.err != nil {
+			return nil, merge.err
+		}
+		run := merge.runs[0]
+		sorted = append(sorted, run.result)
+
+		ok, err := run.next(merge.groups)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(merge, 0)
+		} else {
+			heap.Pop(merge)
+		}
+	}
+	if merge.err != nil {
+		return nil, merge.err
+	}
+	return sorted, nil
+}
+
+// spillRun sorts the results and writes them into a temporary
+// file. The result groups are spilled into the groups file. The
+// spilled results are cleared from the results slice.
+func (iql *Query) spillRun(results []result, groups *sortGroups) (
+	*sortRun, error) {
+
+	var sortErr error
+	sort.Slice(results, func(i, j int) bool {
+		cmp, err := iql.compareOrder(results[i].match.Order,
+			results[j].match.Order)
+		if err != nil {
+			sortErr = err
+			return true
+		}
+		return cmp < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	f, err := os.CreateTemp("", "iql-sort-*")
+	if err != nil {
+		return nil, err
+	}
+	r := &sortRun{
+		file: f,
+	}
+	enc := gob.NewEncoder(f)
+	for idx, res := range results {
+		var sr sortResult
+		sr.Match, err = newSortRow(res.match)
+		if err != nil {
+			return r, err
+		}
+		sr.Group, err = groups.spill(res.group)
+		if err != nil {
+			return r, err
+		}
+		if err := enc.Encode(&sr); err != nil {
+			return r, err
+		}
+		results[idx] = result{}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return r, err
+	}
+	r.dec = gob.NewDecoder(f)
+
+	return r, nil
+}
+
+// sortGroups implements the spill file of the result groups. The
+// groups are identified by their first rows and each group is
+// spilled once. The group IDs start from 1 and the ID 0 marks an
+// empty group.
+type sortGroups struct {
+	file *os.File
+	enc  *gob.Encoder
+	ids  map[*Row]int
+}
+
+func (g *sortGroups) spill(group []*Row) (int, error) {
+	if len(group) == 0 {
+		return 0, nil
+	}
+	id, ok := g.ids[group[0]]
+	if ok {
+		return id, nil
+	}
+	id = len(g.ids) + 1
+	g.ids[group[0]] = id
+
+	var rows []sortRow
+	for _, row := range group {
+		sr, err := newSortRow(row)
+		if err != nil {
+			return 0, err
+		}
+		rows = append(rows, sr)
+	}
+	if err := g.enc.Encode(rows); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// read reads the spilled groups. The group with ID id is returned at
+// index id.
+func (g *sortGroups) read() ([][]*Row, error) {
+	if _, err := g.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	dec := gob.NewDecoder(g.file)
+	groups := make([][]*Row, len(g.ids)+1)
+	for id := 1; id < len(groups); id++ {
+		var rows []sortRow
+		if err := dec.Decode(&rows); err != nil {
+			return nil, err
+		}
+		for _, sr := range rows {
+			groups[id] = append(groups[id], sr.Row())
+		}
+	}
+	return groups, nil
+}
+
+// sortRun implements a spilled run of sorted results.
+type sortRun struct {
+	file   *os.File
+	dec    *gob.Decoder
+	result result
+}
+
+func (r *sortRun) next(groups [][]*Row) (bool, error) {
+	var sr sortResult
+	err := r.dec.Decode(&sr)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.result = result{
+		match: sr.Match.Row(),
+		group: groups[sr.Group],
+	}
+	return true, nil
+}
+
+// sortMerge implements heap.Interface for merging sorted runs. The
+// groups hold the result groups by their group IDs.
+type sortMerge struct {
+	query  *Query
+	runs   []*sortRun
+	groups [][]*Row
+	err    error
+}
+
+func (m *sortMerge) Len() int {
+	return len(m.runs)
+}
+
+func (m *sortMerge) Less(i, j int) bool {
+	cmp, err := m.query.compareOrder(m.runs[i].result.match.Order,
+		m.runs[j].result.match.Order)
+	if err != nil {
+		m.err = err
+		return true
+	}
+	return cmp < 0
+}
+
+func (m *sortMerge) Swap(i, j int) {
+	m.runs[i], m.runs[j] = m.runs[j], m.runs[i]
+}
+
+func (m *sortMerge) Push(x interface{}) {
+	m.runs = append(m.runs, x.(*sortRun))
+}
+
+func (m *sortMerge) Pop() interface{} {
+	n := len(m.runs)
+	run := m.runs[n-1]
+	m.runs = m.runs[:n-1]
+	return run
+}
@@ -0,0 +1,100 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+func TestExternalSort(t *testing.T) {
+	global := NewScope(nil)
+	InitSystemVariables(global)
+	q := NewQuery(global)
+	q.OrderBy = []Order{
+		{
+			Desc: true,
+		},
+	}
+
+	format := &types.Format{
+		Float: "%.2f",
+	}
+
+	var results []result
+	var group []*Row
+	orig := make(map[*Row]bool)
+	for i := 0; i < 100; i++ {
+		val := i * 37 % 100
+		row := &Row{
+			Data: []types.Row{
+				{
+					types.NewValueColumn(types.IntValue(val)),
+					types.StringColumn(fmt.Sprintf("s%d", val)),
+					types.NewValueColumn(types.NewFormattedValue(
+						types.FloatValue(float64(val)), format)),
+					types.NullColumn{},
+				},
+			},
+			Order:   []types.Value{types.IntValue(val)},
+			Ordinal: int64(i),
+		}
+		orig[row] = true
+		group = append(group, row)
+	}
+	for _, row := range group {
+		results = append(results, result{
+			match: row,
+			group: group,
+		})
+	}
+
+	sorted, err := q.externalSort(results, 7)
+	if err != nil {
+		t.Fatalf("externalSort failed: %v", err)
+	}
+	if len(sorted) != len(results) {
+		t.Fatalf("got %d results, expected %d", len(sorted), len(results))
+	}
+	for i, r := range results {
+		if r.match != nil || r.group != nil {
+			t.Errorf("result %d not released after spilling", i)
+		}
+	}
+	for i, r := range sorted {
+		if orig[r.match] {
+			t.Fatalf("result %d not read back from the spill file", i)
+		}
+		val := 99 - i
+		row := r.match.Data[0]
+		expected := []string{
+			fmt.Sprintf("%d", val),
+			fmt.Sprintf("s%d", val),
+			fmt.Sprintf("%d.00", val),
+			"NULL",
+		}
+		for j, col := range row {
+			if col.String() != expected[j] {
+				t.Errorf("result %d column %d: got %s, expected %s",
+					i, j, col, expected[j])
+			}
+		}
+		if r.match.Ordinal != int64(val*73%100) {
+			t.Errorf("result %d: got ordinal %d, expected %d",
+				i, r.match.Ordinal, val*73%100)
+		}
+		if len(r.group) != len(group) {
+			t.Fatalf("result %d: got group of %d rows, expected %d",
+				i, len(r.group), len(group))
+		}
+		if &r.group[0] != &sorted[0].group[0] {
+			t.Errorf("result %d: group not shared", i)
+		}
+	}
+}
@@ -15,10 +15,20 @@ import (
 
 // System variables.
 const (
-	SysARGS     = "ARGS"
-	SysRealFmt  = "REALFMT"
-	SysTableFmt = "TABLEFMT"
-	SysTermOut  = "TERMOUT"
+	SysARGS        = "ARGS"
+	SysCollate     = "COLLATE"
+	SysIdentNoCase = "IDENT_NOCASE"
+	SysMaxInput    = "MAXINPUT"
+	SysMaxRows     = "MAXROWS"
+	SysNaNNull     = "NANNULL"
+	SysOnlyFullGB  = "ONLY_FULL_GROUP_BY"
+	SysRealFmt     = "REALFMT"
+	SysSortBuffer  = "SORTBUFFER"
+	SysStrict      = "STRICT"
+	SysTableFmt    = "TABLEFMT"
+	SysTermOut     = "TERMOUT"
+	SysThousands   = "THOUSANDS"
+	SysTiming      = "TIMING"
 )
 
 var sysvars = []struct {
@@ -34,10 +44,54 @@ var sysvars = []struct {
 			ElemType: types.String,
 		},
 	},
+	{
+		name: SysCollate,
+		typ:  types.String,
+		def:  types.StringValue(types.CollateBinary.String()),
+		ver: func(name string, t types.Type, v types.Value) error {
+			_, err := types.ParseCollation(v.String())
+			return err
+		},
+	},
+	{
+		name: SysIdentNoCase,
+		typ:  types.Bool,
+		def:  types.BoolValue(false),
+	},
+	{
+		name: SysMaxInput,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+	},
+	{
+		name: SysMaxRows,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+	},
+	{
+		name: SysNaNNull,
+		typ:  types.Bool,
+		def:  types.BoolValue(false),
+	},
+	{
+		name: SysOnlyFullGB,
+		typ:  types.Bool,
+		def:  types.BoolValue(true),
+	},
 	{
 		name: SysRealFmt,
 		typ:  types.String,
-		def:  types.StringValue("%g"),
+		def:  types.StringValue(types.DefaultFloatFormat),
+	},
+	{
+		name: SysSortBuffer,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+	},
+	{
+		name: SysStrict,
+		typ:  types.Bool,
+		def:  types.BoolValue(false),
 	},
 	{
 		name: SysTableFmt,
@@ -56,6 +110,16 @@ var sysvars = []struct {
 		typ:  types.Bool,
 		def:  types.BoolValue(true),
 	},
+	{
+		name: SysThousands,
+		typ:  types.Bool,
+		def:  types.BoolValue(false),
+	},
+	{
+		name: SysTiming,
+		typ:  types.Bool,
+		def:  types.BoolValue(false),
+	},
 }
 
 // InitSystemVariables initializes the global system variables for the
@@ -69,15 +133,105 @@ func InitSystemVariables(scope *Scope) {
 
 // Format gets the value formatting options from the scope.
 func Format(scope *Scope) *types.Format {
+	format := &types.Format{
+		Thousands: flag(scope, SysThousands, false),
+	}
 	real := scope.Get(SysRealFmt)
-	if real == nil {
-		return nil
+	if real != nil {
+		_, ok := real.Value.(types.NullValue)
+		if !ok {
+			format.Float = real.Value.String()
+		}
 	}
-	_, ok := real.Value.(types.NullValue)
-	if ok {
+	if len(format.Float) == 0 && !format.Thousands {
 		return nil
 	}
-	return &types.Format{
-		Float: real.Value.String(),
+	return format
+}
+
+// Strict reports if the strict type inference is enabled in the
+// scope.
+func Strict(scope *Scope) bool {
+	return flag(scope, SysStrict, false)
+}
+
+// OnlyFullGroupBy reports if the GROUP BY queries can select only
+// grouped columns and aggregates. If disabled, the non-grouped
+// columns take their values from the first row of each group.
+func OnlyFullGroupBy(scope *Scope) bool {
+	return flag(scope, SysOnlyFullGB, true)
+}
+
+// IdentNoCase reports if the column and source names are resolved
+// case-insensitively.
+func IdentNoCase(scope *Scope) bool {
+	return flag(scope, SysIdentNoCase, false)
+}
+
+// NaNNull reports if the NaN and infinite float results are converted
+// into NULL values.
+func NaNNull(scope *Scope) bool {
+	return flag(scope, SysNaNNull, false)
+}
+
+// Timing reports if the query statistics footer is enabled in the
+// scope.
+func Timing(scope *Scope) bool {
+	return flag(scope, SysTiming, false)
+}
+
+func flag(scope *Scope, name string, def bool) bool {
+	b := scope.Get(name)
+	if b == nil {
+		return def
+	}
+	v, err := b.Value.Bool()
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// MaxInput returns the maximum number of input rows a query can
+// examine. The value 0 means unlimited.
+func MaxInput(scope *Scope) int64 {
+	return limit(scope, SysMaxInput)
+}
+
+// MaxRows returns the maximum number of result rows a query can
+// return. The value 0 means unlimited.
+func MaxRows(scope *Scope) int64 {
+	return limit(scope, SysMaxRows)
+}
+
+// SortBuffer returns the maximum number of result rows sorted in
+// memory. Larger results are sorted with an external merge sort. The
+// value 0 means unlimited.
+func SortBuffer(scope *Scope) int64 {
+	return limit(scope, SysSortBuffer)
+}
+
+func limit(scope *Scope, name string) int64 {
+	b := scope.Get(name)
+	if b == nil {
+		return 0
+	}
+	v, err := b.Value.Int()
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// Collation gets the string collation from the scope.
+func Collation(scope *Scope) types.Collation {
+	b := scope.Get(SysCollate)
+	if b == nil {
+		return types.CollateBinary
+	}
+	c, err := types.ParseCollation(b.Value.String())
+	if err != nil {
+		return types.CollateBinary
 	}
+	return c
 }
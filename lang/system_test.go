@@ -12,7 +12,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/markkurossi/iql/data"
+	"github.com/markkurossi/iql/types"
 )
 
 var systemTests = []struct {
@@ -20,6 +24,32 @@ var systemTests = []struct {
 	v    [][]string
 	rest [][][]string
 }{
+	{
+		q: `SELECT 1.0/0.0, -1.0/0.0, LOG(-1);`,
+		v: [][]string{
+			{"+Inf", "-Inf", "NULL"},
+		},
+	},
+	{
+		q: `
+SET NANNULL = true;
+SELECT 1.0/0.0, 0.0/0.0, SQRT(-1), LOG(-1), 1.0/2.0;`,
+		v: [][]string{
+			{"NULL", "NULL", "NULL", "NULL", "0.5"},
+		},
+	},
+	{
+		q: `
+SET NANNULL = true;
+SELECT SUM(1.0 / V) FROM ` + "```csv" + `
+V
+0
+2
+` + "```" + `;`,
+		v: [][]string{
+			{"0.5"},
+		},
+	},
 	{
 		q: `
 SET REALFMT = '%.2f';
@@ -30,6 +60,91 @@ SELECT 3.1415;`,
 	},
 	{
 		q: `
+SET REALFMT = '%.2f';
+SELECT CASE WHEN x > 1 THEN 1.5 ELSE 2 END AS c,
+       CASE WHEN x = 1 THEN ROUND(x) ELSE x / 2 END AS d
+FROM ` + "```csv" + `
+x
+1
+3
+` + "```" + `;`,
+		v: [][]string{
+			{"2.00", "1.00"},
+			{"1.50", "1.00"},
+		},
+	},
+	{
+		q: `
+SET REALFMT = '%.2f';
+SELECT NULLIF(CASE WHEN x = 1 THEN ABS(x) ELSE SQRT(x) END, 99) AS c
+FROM ` + "```csv" + `
+x
+1
+4
+` + "```" + `;`,
+		v: [][]string{
+			{"1.00"},
+			{"2.00"},
+		},
+	},
+	{
+		q: `
+SET REALFMT = '%.2f';
+SELECT DISTINCT CASE WHEN x = 1 THEN ABS(x) * 2 ELSE SQRT(x) END AS c
+FROM ` + "```csv" + `
+x
+1
+4
+` + "```" + `;`,
+		v: [][]string{
+			{"2.00"},
+		},
+	},
+	{
+		q: `SELECT 'A' = 'a', 'a' < 'B', 'b' IN ('A', 'B');`,
+		v: [][]string{
+			{"false", "false", "false"},
+		},
+	},
+	{
+		q: `
+SET COLLATE = 'nocase';
+SELECT 'A' = 'a', 'a' < 'B', 'b' IN ('A', 'B');`,
+		v: [][]string{
+			{"true", "true", "true"},
+		},
+	},
+	{
+		q: `
+SELECT Name FROM 'data:text/csv;base64,TmFtZQphCkIKYwo=' ORDER BY Name;`,
+		v: [][]string{
+			{"B"},
+			{"a"},
+			{"c"},
+		},
+	},
+	{
+		q: `
+SET COLLATE = 'nocase';
+SELECT Name FROM 'data:text/csv;base64,TmFtZQphCkIKYwo=' ORDER BY Name;`,
+		v: [][]string{
+			{"a"},
+			{"B"},
+			{"c"},
+		},
+	},
+	{
+		q: `
+SELECT Count FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMseApkLDQK';`,
+		v: [][]string{
+			{"1"},
+			{"2"},
+			{"x"},
+			{"4"},
+		},
+	},
+	{
+		q: `
 SET TERMOUT OFF
 SELECT 'Hello, world!';`,
 		v: [][]string{
@@ -63,3 +178,328 @@ func TestSystem(t *testing.T) {
 		}
 	}
 }
+
+func TestStrict(t *testing.T) {
+	// Name,Count
+	// a,1
+	// b,2
+	// c,x
+	// d,4
+	input := `
+SET STRICT = true;
+SELECT Count FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMseApkLDQK';`
+
+	global := NewScope(nil)
+	InitSystemVariables(global)
+	parser := NewParser(global, bytes.NewReader([]byte(input)), "strict",
+		os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	_, err = q.Get()
+	if err == nil {
+		t.Fatalf("strict mode did not detect type widening")
+	}
+	if !strings.Contains(err.Error(), "'Count'") ||
+		!strings.Contains(err.Error(), "'x'") {
+		t.Errorf("error does not identify column and value: %s", err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	// Name,Count
+	// a,1
+	// b,2
+	// c,x
+	// d,4
+	input := `
+SELECT Count FROM 'data:text/csv;base64,TmFtZSxDb3VudAphLDEKYiwyCmMseApkLDQK';
+SELECT 9223372036854775807 + Count FROM ` + "```csv\nCount\n1\n0\n```;"
+
+	global := NewScope(nil)
+	InitSystemVariables(global)
+	parser := NewParser(global, bytes.NewReader([]byte(input)), "warnings",
+		os.Stdout)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	_, err = q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v", err)
+	}
+	warnings := q.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, expected 1: %v", len(warnings), warnings)
+	}
+	w := warnings[0]
+	if w.Column != "Count" || w.Row != 0 ||
+		!strings.Contains(w.Message, "'x'") {
+		t.Errorf("unexpected widening warning: %s", w)
+	}
+
+	q, err = parser.Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	_, err = q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %v", err)
+	}
+	warnings = q.Warnings()
+	if len(warnings) != 1 || warnings[0].Row != 1 ||
+		!strings.Contains(warnings[0].Message, "overflow") {
+		t.Errorf("unexpected overflow warnings: %v", warnings)
+	}
+}
+
+func TestOnlyFullGroupBy(t *testing.T) {
+	// Name,Unit,Count
+	// a,kg,1
+	// a,g,2
+	// b,kg,3
+	from := `
+FROM 'data:text/csv;base64,TmFtZSxVbml0LENvdW50CmEsa2csMQphLGcsMgpiLGtnLDMK'
+GROUP BY Name;`
+
+	tests := []struct {
+		q   string
+		err string
+		v   [][]string
+	}{
+		{
+			q: `SELECT Name, SUM(Count), UPPER(Name) AS Upper` + from,
+			v: [][]string{
+				{"a", "3", "A"},
+				{"b", "3", "B"},
+			},
+		},
+		{
+			q: `SELECT CONCAT(Name, '-', COUNT(Unit)), 42` + from,
+			v: [][]string{
+				{"a-2", "42"},
+				{"b-1", "42"},
+			},
+		},
+		{
+			q:   `SELECT Name, Unit, SUM(Count)` + from,
+			err: "'Unit'",
+		},
+		{
+			q:   `SELECT Name, Count + 1` + from,
+			err: "'Count'",
+		},
+		{
+			q: `SET ONLY_FULL_GROUP_BY = false;
+SELECT Name, Unit, SUM(Count)` + from,
+			v: [][]string{
+				{"a", "kg", "3"},
+				{"b", "kg", "3"},
+			},
+		},
+	}
+	for idx, test := range tests {
+		global := NewScope(nil)
+		InitSystemVariables(global)
+		parser := NewParser(global, bytes.NewReader([]byte(test.q)),
+			"groupby", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("test %d: parse failed: %v", idx, err)
+		}
+		if len(test.err) > 0 {
+			_, err = q.Get()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("test %d: got error %v, expected %s error", idx, err,
+					test.err)
+			}
+			continue
+		}
+		verifyResult(t, fmt.Sprintf("test %d", idx), test.q, q, test.v)
+	}
+}
+
+func TestIdentNoCase(t *testing.T) {
+	from := " FROM ```csv\nYear,Value,id,ID\n1970,100,1,2\n``` AS d;"
+
+	tests := []struct {
+		q   string
+		err string
+		v   [][]string
+	}{
+		{
+			q: `SELECT Year, d.Value` + from,
+			v: [][]string{
+				{"1970", "100"},
+			},
+		},
+		{
+			q:   `SELECT year` + from,
+			err: "'year'",
+		},
+		{
+			q: `SET IDENT_NOCASE = true;
+SELECT year, D.VALUE, Year` + from,
+			v: [][]string{
+				{"1970", "100", "1970"},
+			},
+		},
+		{
+			q: `SET IDENT_NOCASE = true;
+SELECT Id` + from,
+			err: "ambiguous",
+		},
+	}
+	for idx, test := range tests {
+		global := NewScope(nil)
+		InitSystemVariables(global)
+		parser := NewParser(global, bytes.NewReader([]byte(test.q)),
+			"nocase", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("test %d: parse failed: %v", idx, err)
+		}
+		if len(test.err) > 0 {
+			_, err = q.Get()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("test %d: got error %v, expected %s error", idx, err,
+					test.err)
+			}
+			continue
+		}
+		verifyResult(t, fmt.Sprintf("test %d", idx), test.q, q, test.v)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var rows []types.Row
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, types.Row{
+			types.NewValueColumn(types.IntValue(i)),
+		})
+	}
+	big := data.NewRows([]types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Value",
+			},
+		},
+	}, rows)
+
+	tests := []struct {
+		q   string
+		err string
+	}{
+		{
+			q: `SET MAXROWS = 100; SELECT Value FROM big LIMIT 100;`,
+		},
+		{
+			q:   `SET MAXROWS = 100; SELECT Value FROM big;`,
+			err: SysMaxRows,
+		},
+		{
+			q: `SET MAXINPUT = 1000; SELECT COUNT(Value) FROM big;`,
+		},
+		{
+			q:   `SET MAXINPUT = 999; SELECT COUNT(Value) FROM big;`,
+			err: SysMaxInput,
+		},
+		{
+			q: `SET MAXINPUT = 10000;
+SELECT COUNT(a.Value) FROM big AS a, big AS b WHERE a.Value = b.Value;`,
+			err: SysMaxInput,
+		},
+	}
+	for idx, test := range tests {
+		global := NewScope(nil)
+		InitSystemVariables(global)
+		global.Declare("big", types.Table, nil)
+		global.Set("big", types.TableValue{
+			Source: big,
+		})
+		parser := NewParser(global, bytes.NewReader([]byte(test.q)),
+			"limits", os.Stdout)
+		q, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("test %d: parse failed: %v", idx, err)
+		}
+		_, err = q.Get()
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("test %d: unexpected error: %v", idx, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: got error %v, expected %s error", idx, err,
+				test.err)
+		}
+	}
+}
+
+func TestSortBuffer(t *testing.T) {
+	var rows []types.Row
+	for i := 0; i < 1000; i++ {
+		var name types.Column = types.StringColumn(fmt.Sprintf("n%d", i%37))
+		if i%11 == 0 {
+			name = types.NullColumn{}
+		}
+		rows = append(rows, types.Row{
+			types.NewValueColumn(types.IntValue(i * 7919 % 1000)),
+			name,
+		})
+	}
+	big := data.NewRows([]types.ColumnSelector{
+		{
+			Name: types.Reference{
+				Column: "Value",
+			},
+		},
+		{
+			Name: types.Reference{
+				Column: "Name",
+			},
+		},
+	}, rows)
+
+	run := func(buffer int) []types.Row {
+		global := NewScope(nil)
+		InitSystemVariables(global)
+		global.Declare("big", types.Table, nil)
+		global.Set("big", types.TableValue{
+			Source: big,
+		})
+		q := fmt.Sprintf(`SET SORTBUFFER = %d;
+SELECT Name, Value FROM big ORDER BY Name DESC, Value;`, buffer)
+		parser := NewParser(global, bytes.NewReader([]byte(q)),
+			"sortbuffer", os.Stdout)
+		query, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("SORTBUFFER=%d: parse failed: %v", buffer, err)
+		}
+		result, err := query.Get()
+		if err != nil {
+			t.Fatalf("SORTBUFFER=%d: get failed: %v", buffer, err)
+		}
+		return result
+	}
+
+	expected := run(0)
+	if len(expected) != len(rows) {
+		t.Fatalf("got %d rows, expected %d", len(expected), len(rows))
+	}
+	for _, buffer := range []int{1, 64, 999, 1000} {
+		result := run(buffer)
+		if len(result) != len(expected) {
+			t.Fatalf("SORTBUFFER=%d: got %d rows, expected %d", buffer,
+				len(result), len(expected))
+		}
+		for i, row := range result {
+			for j, col := range row {
+				if col.String() != expected[i][j].String() {
+					t.Errorf("SORTBUFFER=%d: row %d column %d: got %s, "+
+						"expected %s", buffer, i, j, col, expected[i][j])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/iql/data"
+	"github.com/markkurossi/iql/types"
+)
+
+// TableFunction defines table-valued functions that can be used as
+// data sources in the FROM clause.
+type TableFunction struct {
+	Name    string
+	Impl    TableFunctionImpl
+	MinArgs int
+	MaxArgs int
+}
+
+// TableFunctionImpl implements the table-valued functions. The
+// function arguments are evaluated before the function is called.
+type TableFunctionImpl func(args []types.Value) (types.Source, error)
+
+var tableFunctions = []TableFunction{
+	{
+		Name:    "LINES",
+		Impl:    tableLines,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "SEQUENCE",
+		Impl:    tableSequence,
+		MinArgs: 3,
+		MaxArgs: 3,
+	},
+	{
+		Name:    "STRING_SPLIT",
+		Impl:    tableStringSplit,
+		MinArgs: 2,
+		MaxArgs: 2,
+	},
+	{
+		Name:    "SPLIT_TO_ROWS",
+		Impl:    tableSplitToRows,
+		MinArgs: 2,
+		MaxArgs: 2,
+	},
+}
+
+func tableLines(args []types.Value) (types.Source, error) {
+	if args[0] == types.Null {
+		return data.NewSplit(nil), nil
+	}
+	text := strings.TrimSuffix(args[0].String(), "\n")
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		lines[idx] = strings.TrimSuffix(line, "\r")
+	}
+	return data.NewSplit(lines), nil
+}
+
+func tableSequence(args []types.Value) (types.Source, error) {
+	return data.NewSequence(args[0], args[1], args[2])
+}
+
+func tableSplitToRows(args []types.Value) (types.Source, error) {
+	if args[0] == types.Null || args[1] == types.Null {
+		return data.NewSplit(nil), nil
+	}
+	return data.NewSplit(strings.Split(args[0].String(), args[1].String())),
+		nil
+}
+
+// tableStringSplit implements the T-SQL compatible STRING_SPLIT. The
+// separator must be a single character and the result column is
+// named value.
+func tableStringSplit(args []types.Value) (types.Source, error) {
+	if args[0] == types.Null {
+		return data.NewSplitColumn("value", nil), nil
+	}
+	if args[1] == types.Null || len([]rune(args[1].String())) != 1 {
+		return nil, fmt.Errorf("STRING_SPLIT: separator must be a single "+
+			"character: %s", args[1])
+	}
+	return data.NewSplitColumn("value",
+		strings.Split(args[0].String(), args[1].String())), nil
+}
+
+// Apply implements the table function of a CROSS APPLY source. The
+// function arguments are evaluated for each row of the preceding
+// sources and the function returns the rows joined with the row.
+type Apply struct {
+	Function  *TableFunction
+	Arguments []Expr
+}
+
+// NewApply creates a CROSS APPLY of the table function. The function
+// returns the apply and a source that defines its result
+// columns. The columns are resolved by calling the function with
+// NULL arguments so the functions that do not accept NULL arguments
+// can't be applied.
+func NewApply(tf *TableFunction, args []Expr) (*Apply, types.Source,
+	error) {
+
+	if len(args) < tf.MinArgs || len(args) > tf.MaxArgs {
+		return nil, nil, fmt.Errorf("%s: invalid number of arguments: %d",
+			tf.Name, len(args))
+	}
+	nulls := make([]types.Value, len(args))
+	for idx := range nulls {
+		nulls[idx] = types.Null
+	}
+	source, err := tf.Impl(nulls)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: CROSS APPLY not supported",
+			tf.Name)
+	}
+	return &Apply{
+		Function:  tf,
+		Arguments: args,
+	}, source, nil
+}
+
+// Bind binds the function arguments. The arguments can reference the
+// columns of the sources preceding the source idx.
+func (apply *Apply) Bind(iql *Query, idx int) error {
+	for _, arg := range apply.Arguments {
+		if err := arg.Bind(iql); err != nil {
+			return err
+		}
+		for _, ref := range arg.References() {
+			r, err := iql.resolveName(ref)
+			if err != nil {
+				return err
+			}
+			if r.index != nil && r.index.Source >= idx {
+				return fmt.Errorf("%s: CROSS APPLY argument references "+
+					"non-preceding column '%s'", apply.Function.Name, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// Get evaluates the function arguments with the row of the preceding
+// sources and returns the rows of the function result.
+func (apply *Apply) Get(row *Row) ([]types.Row, error) {
+	var args []types.Value
+	for _, arg := range apply.Arguments {
+		v, err := arg.Eval(row, nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	source, err := apply.Function.Impl(args)
+	if err != nil {
+		return nil, err
+	}
+	return source.Get()
+}
+
+func tableFunction(name string) *TableFunction {
+	for idx, tf := range tableFunctions {
+		if tf.Name == name {
+			return &tableFunctions[idx]
+		}
+	}
+	return nil
+}
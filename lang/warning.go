@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+)
+
+// maxWarnings limits the number of warnings recorded for a query.
+const maxWarnings = 1000
+
+// Warning describes a non-fatal data quality issue that was detected
+// when the query was bound or evaluated, for example, a column type
+// widening or an integer overflow. The Column names the affected
+// column or expression. The Row is the 1-based position of the
+// affected row in the source scan, like ROWID returns, or 0 if the
+// warning does not concern a single row.
+type Warning struct {
+	Column  string
+	Row     int64
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Row > 0 {
+		return fmt.Sprintf("row %d: %s: %s", w.Row, w.Column, w.Message)
+	}
+	return fmt.Sprintf("%s: %s", w.Column, w.Message)
+}
+
+// WarningHandler handles the query warnings.
+type WarningHandler func(w Warning)
+
+// Warnings returns the warnings of the query. The warnings are
+// available after the query is evaluated with Get(). The warnings of
+// the nested queries are included in the warnings of their parent
+// query.
+func (iql *Query) Warnings() []Warning {
+	return iql.warnings
+}
+
+func (iql *Query) warnf(column string, row int64, format string,
+	a ...interface{}) {
+
+	if len(iql.warnings) >= maxWarnings {
+		return
+	}
+	iql.warnings = append(iql.warnings, Warning{
+		Column:  column,
+		Row:     row,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
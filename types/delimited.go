@@ -0,0 +1,87 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package types
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// DelimitedOptions define the output format of WriteDelimited.
+type DelimitedOptions struct {
+	// FieldSep separates the fields of a row. The default separator
+	// is comma.
+	FieldSep string
+
+	// RowSep terminates the rows. The default separator is newline.
+	RowSep string
+
+	// Quote quotes the fields that contain separators or quote
+	// characters. The quote characters inside fields are
+	// doubled. The fields are not quoted if Quote is 0.
+	Quote rune
+
+	// Null is the output of NULL values.
+	Null string
+
+	// NoHeaders omits the header row.
+	NoHeaders bool
+}
+
+// WriteDelimited writes the source rows into the writer w. The fields
+// and rows are separated by the separators of opts.
+func WriteDelimited(source Source, w io.Writer, opts DelimitedOptions) error {
+	if len(opts.FieldSep) == 0 {
+		opts.FieldSep = ","
+	}
+	if len(opts.RowSep) == 0 {
+		opts.RowSep = "\n"
+	}
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+
+	if !opts.NoHeaders {
+		for idx, col := range source.Columns() {
+			if idx > 0 {
+				out.WriteString(opts.FieldSep)
+			}
+			out.WriteString(opts.quote(col.String()))
+		}
+		out.WriteString(opts.RowSep)
+	}
+	for _, row := range rows {
+		for idx, col := range row {
+			if idx > 0 {
+				out.WriteString(opts.FieldSep)
+			}
+			if _, ok := col.(NullColumn); ok {
+				out.WriteString(opts.quote(opts.Null))
+			} else {
+				out.WriteString(opts.quote(col.String()))
+			}
+		}
+		out.WriteString(opts.RowSep)
+	}
+	return out.Flush()
+}
+
+func (opts DelimitedOptions) quote(field string) string {
+	if opts.Quote == 0 {
+		return field
+	}
+	q := string(opts.Quote)
+	if !strings.Contains(field, opts.FieldSep) &&
+		!strings.Contains(field, opts.RowSep) &&
+		!strings.Contains(field, q) {
+		return field
+	}
+	return q + strings.ReplaceAll(field, q, q+q) + q
+}
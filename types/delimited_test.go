@@ -0,0 +1,81 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package types
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testSource struct {
+	columns []ColumnSelector
+	rows    []Row
+}
+
+func (s *testSource) Columns() []ColumnSelector {
+	return s.columns
+}
+
+func (s *testSource) Get() ([]Row, error) {
+	return s.rows, nil
+}
+
+func TestWriteDelimited(t *testing.T) {
+	source := &testSource{
+		columns: []ColumnSelector{
+			{
+				Name: Reference{
+					Column: "Name",
+				},
+			},
+			{
+				Name: Reference{
+					Column: "Count",
+				},
+				As: "Total Count",
+			},
+		},
+		rows: []Row{
+			{StringColumn("a"), NewValueColumn(IntValue(1))},
+			{StringColumn("b|c"), NullColumn{}},
+			{StringColumn(`say "hi"`), NewValueColumn(IntValue(3))},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WriteDelimited(source, &buf, DelimitedOptions{
+		FieldSep: "|",
+		Quote:    '"',
+		Null:     "-",
+	})
+	if err != nil {
+		t.Fatalf("WriteDelimited failed: %s", err)
+	}
+	expected := `Name|Total Count
+a|1
+"b|c"|-
+"say ""hi"""|3
+`
+	if buf.String() != expected {
+		t.Errorf("WriteDelimited: got\n%s\nexpected\n%s", buf.String(),
+			expected)
+	}
+
+	buf.Reset()
+	err = WriteDelimited(source, &buf, DelimitedOptions{
+		FieldSep:  "|",
+		RowSep:    ";",
+		NoHeaders: true,
+	})
+	if err != nil {
+		t.Fatalf("WriteDelimited failed: %s", err)
+	}
+	expected = `a|1;b|c|;say "hi"|3;`
+	if buf.String() != expected {
+		t.Errorf("WriteDelimited: got %q, expected %q", buf.String(), expected)
+	}
+}
@@ -37,6 +37,17 @@ type ColumnSelector struct {
 	Name Reference
 	As   string
 	Type Type
+
+	// Comment describes the column, for example, its unit. The
+	// comment is rendered as a secondary header line in tables.
+	Comment string
+
+	// Widened is the first value that widened the column type into
+	// String after the column had values of a more specific type. The
+	// WidenedFrom specifies the column type before the widening.
+	Widened     string
+	WidenedFrom Type
+	resolved    bool
 }
 
 // IsPublic reports if the column is public and should be included in
@@ -74,6 +85,14 @@ func (col *ColumnSelector) ResolveString(val string) {
 	if len(val) == 0 {
 		return
 	}
+	from := col.Type
+	defer func() {
+		if col.resolved && from != String && col.Type == String {
+			col.Widened = val
+			col.WidenedFrom = from
+		}
+		col.resolved = true
+	}()
 	for {
 		switch col.Type {
 		case Bool:
@@ -193,6 +212,11 @@ func NewValueColumn(v Value) *ValueColumn {
 	}
 }
 
+// Value returns the column value.
+func (c ValueColumn) Value() Value {
+	return c.v
+}
+
 // Bool implements the Column.Bool().
 func (c ValueColumn) Bool() (Value, error) {
 	val, err := c.v.Bool()
@@ -313,18 +337,87 @@ func Tabulate(source Source, style tabulate.Style) (*tabulate.Tabulate, error) {
 		return w
 	}
 	for _, col := range source.Columns() {
-		tab.Header(col.String()).SetAlign(col.Type.Align())
+		label := col.String()
+		if len(col.Comment) > 0 && style != tabulate.CSV &&
+			style != tabulate.JSON {
+			label += "\n" + col.Comment
+		}
+		tab.Header(label).SetAlign(col.Type.Align())
 	}
-	for _, columns := range rows {
-		row := tab.Row()
+	cells := make([][]string, len(rows))
+	for i, columns := range rows {
 		for _, col := range columns {
 			_, ok := col.(NullColumn)
 			if ok {
-				row.Column("")
+				cells[i] = append(cells[i], "")
 			} else {
-				row.Column(col.String())
+				cells[i] = append(cells[i], display(col, style))
+			}
+		}
+	}
+	if style != tabulate.CSV && style != tabulate.JSON {
+		for idx, col := range source.Columns() {
+			if col.Type == Float {
+				alignDecimals(cells, idx)
 			}
 		}
 	}
+	for _, columns := range cells {
+		row := tab.Row()
+		for _, col := range columns {
+			row.Column(col)
+		}
+	}
 	return tab, nil
 }
+
+// alignDecimals pads the values of the column idx with trailing
+// spaces so that their decimal points line up when the values are
+// aligned right.
+func alignDecimals(cells [][]string, idx int) {
+	var max int
+	for _, row := range cells {
+		if idx < len(row) {
+			if l := fractionLen(row[idx]); l > max {
+				max = l
+			}
+		}
+	}
+	if max == 0 {
+		return
+	}
+	for _, row := range cells {
+		if idx >= len(row) || len(row[idx]) == 0 {
+			continue
+		}
+		row[idx] += strings.Repeat(" ", max-fractionLen(row[idx]))
+	}
+}
+
+// fractionLen returns the length of the fractional part of the
+// numeric string, including the decimal point.
+func fractionLen(val string) int {
+	idx := strings.IndexByte(val, '.')
+	if idx < 0 {
+		return 0
+	}
+	return len(val) - idx
+}
+
+// display returns the column value in its display format. The CSV
+// and JSON styles use the column string values so that the output
+// remains machine readable.
+func display(col Column, style tabulate.Style) string {
+	if style == tabulate.CSV || style == tabulate.JSON {
+		return col.String()
+	}
+	vc, ok := col.(*ValueColumn)
+	if !ok {
+		return col.String()
+	}
+	fv, ok := vc.Value().(*FormattedValue)
+	if !ok {
+		return col.String()
+	}
+	return fv.Display()
+}
@@ -27,6 +27,7 @@ const (
 	String
 	Table
 	Array
+	Interval
 	Any
 )
 
@@ -78,13 +79,15 @@ func ParseBoolean(val string) (bool, bool) {
 }
 
 var types = map[Type]string{
-	Bool:   "boolean",
-	Int:    "integer",
-	Float:  "real",
-	Date:   "datetime",
-	String: "varchar",
-	Table:  "table",
-	Array:  "array",
+	Bool:     "boolean",
+	Int:      "integer",
+	Float:    "real",
+	Date:     "datetime",
+	String:   "varchar",
+	Table:    "table",
+	Array:    "array",
+	Interval: "interval",
+	Any:      "any",
 }
 
 func (t Type) String() string {
@@ -121,6 +124,8 @@ func (t Type) CanAssign(v Value) bool {
 		return t == Table
 	case ArrayValue:
 		return t == Array
+	case IntervalValue:
+		return t == Interval
 	case NullValue:
 		return true
 	default:
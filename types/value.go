@@ -9,6 +9,7 @@ package types
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -22,15 +23,17 @@ var (
 	_ Value = StringValue("")
 	_ Value = TableValue{}
 	_ Value = ArrayValue{}
+	_ Value = IntervalValue{}
 	_ Value = &FormattedValue{}
 
 	// Null value specifies a non-existing value.
 	Null Value = NullValue{}
 )
 
-const (
-	defaultFloatFormat = "%g"
-)
+// DefaultFloatFormat specifies the default formatting for float
+// values. It is used when values are not formatted with an explicit
+// Format.
+const DefaultFloatFormat = "%g"
 
 // Value implements expression values.
 type Value interface {
@@ -76,6 +79,13 @@ func Equal(value1, value2 Value) (bool, error) {
 	case StringValue:
 		return v1 == StringValue(value2.String()), nil
 
+	case IntervalValue:
+		v2, ok := value2.(IntervalValue)
+		if !ok {
+			return false, nil
+		}
+		return v1 == v2, nil
+
 	default:
 		return false, fmt.Errorf("types.Equal: invalid type: %T", value1)
 	}
@@ -84,6 +94,17 @@ func Equal(value1, value2 Value) (bool, error) {
 // Compare compares two values. It returns -1, 0, 1 if the value 1 is
 // smaller, equal, or greater than the value 2 respectively.
 func Compare(value1, value2 Value) (int, error) {
+	return CompareCollation(value1, value2, CollateBinary)
+}
+
+// CompareCollation compares two values. The string values are
+// compared with the collation. The float NaN values are equal to each
+// other and greater than all other float values. It returns -1, 0, 1
+// if the value 1 is smaller, equal, or greater than the value 2
+// respectively.
+func CompareCollation(value1, value2 Value, collation Collation) (
+	int, error) {
+
 	_, n1 := value1.(NullValue)
 	_, n2 := value2.(NullValue)
 	if n1 && n2 {
@@ -126,6 +147,16 @@ func Compare(value1, value2 Value) (int, error) {
 		if !ok {
 			return -1, nil
 		}
+		nan1 := math.IsNaN(float64(v1))
+		nan2 := math.IsNaN(float64(v2))
+		if nan1 || nan2 {
+			if nan1 && nan2 {
+				return 0, nil
+			} else if nan1 {
+				return 1, nil
+			}
+			return -1, nil
+		}
 		if v1 < v2 {
 			return -1, nil
 		}
@@ -152,13 +183,70 @@ func Compare(value1, value2 Value) (int, error) {
 		if !ok {
 			return -1, nil
 		}
-		return strings.Compare(v1.String(), v2.String()), nil
+		return collation.Compare(v1.String(), v2.String()), nil
+
+	case IntervalValue:
+		v2, ok := value2.(IntervalValue)
+		if !ok {
+			return -1, nil
+		}
+		d1 := v1.approx()
+		d2 := v2.approx()
+		if d1 < d2 {
+			return -1, nil
+		}
+		if d1 > d2 {
+			return 1, nil
+		}
+		return 0, nil
 
 	default:
 		return -1, fmt.Errorf("types.Compare: invalid type: %T", value1)
 	}
 }
 
+// Collation specifies the string comparison rules.
+type Collation int
+
+// String collations.
+const (
+	CollateBinary Collation = iota
+	CollateNoCase
+)
+
+var collations = map[Collation]string{
+	CollateBinary: "binary",
+	CollateNoCase: "nocase",
+}
+
+func (c Collation) String() string {
+	name, ok := collations[c]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Collation %d}", c)
+}
+
+// ParseCollation parses the collation name.
+func ParseCollation(name string) (Collation, error) {
+	for c, n := range collations {
+		if n == strings.ToLower(name) {
+			return c, nil
+		}
+	}
+	return CollateBinary, fmt.Errorf("unknown collation: %s", name)
+}
+
+// Compare compares two strings according to the collation. It returns
+// -1, 0, 1 if the string a is smaller, equal, or greater than the
+// string b respectively.
+func (c Collation) Compare(a, b string) int {
+	if c == CollateNoCase {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+	return strings.Compare(a, b)
+}
+
 // BoolValue implements boolean values.
 type BoolValue bool
 
@@ -252,7 +340,7 @@ func (v FloatValue) Float() (float64, error) {
 }
 
 func (v FloatValue) String() string {
-	return fmt.Sprintf(defaultFloatFormat, float64(v))
+	return fmt.Sprintf(DefaultFloatFormat, float64(v))
 }
 
 // DateValue implements datetime values.
@@ -411,6 +499,152 @@ func (v ArrayValue) String() string {
 	return fmt.Sprintf("%v", v.Data)
 }
 
+// IntervalValue implements time intervals. The months and days are
+// kept separate from the duration because their lengths vary.
+type IntervalValue struct {
+	Months   int64
+	Days     int64
+	Duration time.Duration
+}
+
+// NewInterval creates an interval of count units. The supported units
+// are YEAR, MONTH, DAY, HOUR, MINUTE, and SECOND.
+func NewInterval(count int64, unit string) (IntervalValue, error) {
+	switch strings.ToUpper(unit) {
+	case "YEAR":
+		return IntervalValue{Months: count * 12}, nil
+	case "MONTH":
+		return IntervalValue{Months: count}, nil
+	case "DAY":
+		return IntervalValue{Days: count}, nil
+	case "HOUR":
+		return IntervalValue{Duration: time.Duration(count) * time.Hour}, nil
+	case "MINUTE":
+		return IntervalValue{
+			Duration: time.Duration(count) * time.Minute,
+		}, nil
+	case "SECOND":
+		return IntervalValue{
+			Duration: time.Duration(count) * time.Second,
+		}, nil
+	default:
+		return IntervalValue{}, fmt.Errorf("unknown interval unit: %s", unit)
+	}
+}
+
+// Since returns the interval from the time t2 to the time t1.
+func Since(t1, t2 time.Time) IntervalValue {
+	d := t1.Sub(t2)
+	days := d / (24 * time.Hour)
+	return IntervalValue{
+		Days:     int64(days),
+		Duration: d - days*24*time.Hour,
+	}
+}
+
+// AddTo adds the interval to the time t. The months and days follow
+// the calendar. The months are added first and the day is clamped to
+// the last day of the target month so January 31 plus one month is
+// February 29 (or 28).
+func (v IntervalValue) AddTo(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	first := time.Date(year, month+time.Month(v.Months), 1, hour, min, sec,
+		t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1+int(v.Days)).Add(v.Duration)
+}
+
+// Add returns the sum of the intervals v and o.
+func (v IntervalValue) Add(o IntervalValue) IntervalValue {
+	return IntervalValue{
+		Months:   v.Months + o.Months,
+		Days:     v.Days + o.Days,
+		Duration: v.Duration + o.Duration,
+	}
+}
+
+// Neg returns the negated interval.
+func (v IntervalValue) Neg() IntervalValue {
+	return IntervalValue{
+		Months:   -v.Months,
+		Days:     -v.Days,
+		Duration: -v.Duration,
+	}
+}
+
+// approx returns the approximate length of the interval. It counts
+// months as 30 days.
+func (v IntervalValue) approx() time.Duration {
+	return time.Duration(v.Months*30+v.Days)*24*time.Hour + v.Duration
+}
+
+// Type implements the Value.Type().
+func (v IntervalValue) Type() Type {
+	return Interval
+}
+
+// Date implements the Value.Date().
+func (v IntervalValue) Date() (time.Time, error) {
+	return time.Time{}, fmt.Errorf("interval used as date")
+}
+
+// Bool implements the Value.Bool().
+func (v IntervalValue) Bool() (bool, error) {
+	return false, fmt.Errorf("interval used as bool")
+}
+
+// Int implements the Value.Int().
+func (v IntervalValue) Int() (int64, error) {
+	return 0, fmt.Errorf("interval used as int")
+}
+
+// Float implements the Value.Float().
+func (v IntervalValue) Float() (float64, error) {
+	return 0, fmt.Errorf("interval used as float")
+}
+
+func (v IntervalValue) String() string {
+	var parts []string
+
+	add := func(n int64, unit string) {
+		if n == 0 {
+			return
+		}
+		if n != 1 && n != -1 {
+			unit += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, unit))
+	}
+	add(v.Months/12, "year")
+	add(v.Months%12, "month")
+	add(v.Days, "day")
+
+	if v.Duration != 0 || len(parts) == 0 {
+		var sign string
+		d := v.Duration
+		if d < 0 {
+			sign = "-"
+			d = -d
+		}
+		h := d / time.Hour
+		d -= h * time.Hour
+		m := d / time.Minute
+		d -= m * time.Minute
+		sec := d / time.Second
+		d -= sec * time.Second
+
+		str := fmt.Sprintf("%s%02d:%02d:%02d", sign, h, m, sec)
+		if d != 0 {
+			str += strings.TrimRight(fmt.Sprintf(".%09d", d), "0")
+		}
+		parts = append(parts, str)
+	}
+	return strings.Join(parts, " ")
+}
+
 // NullValue implements non-existing value.
 type NullValue struct {
 }
@@ -444,9 +678,12 @@ func (v NullValue) String() string {
 	return "null"
 }
 
-// Format implements value formatting options.
+// Format implements value formatting options. The Date option is a
+// Go time layout for the datetime values.
 type Format struct {
-	Float string
+	Float     string
+	Date      string
+	Thousands bool
 }
 
 // FormattedValue implements value by wrapping another value type with
@@ -465,6 +702,16 @@ func NewFormattedValue(v Value, f *Format) *FormattedValue {
 	}
 }
 
+// Value returns the wrapped value.
+func (v *FormattedValue) Value() Value {
+	return v.value
+}
+
+// Format returns the formatting options of the value.
+func (v *FormattedValue) Format() *Format {
+	return v.format
+}
+
 // Type implements the Value.Type().
 func (v *FormattedValue) Type() Type {
 	return v.value.Type()
@@ -495,10 +742,59 @@ func (v *FormattedValue) String() string {
 	case FloatValue:
 		format := v.format.Float
 		if len(format) == 0 {
-			format = defaultFloatFormat
+			format = DefaultFloatFormat
 		}
 		return fmt.Sprintf(format, float64(val))
+	case DateValue:
+		if len(v.format.Date) == 0 {
+			return val.String()
+		}
+		return time.Time(val).Format(v.format.Date)
 	default:
 		return v.value.String()
 	}
 }
+
+// Display returns the value in its display format. Unlike String(),
+// the display format groups the integer digits with thousands
+// separators if the Thousands formatting option is set.
+func (v *FormattedValue) Display() string {
+	str := v.String()
+	if !v.format.Thousands {
+		return str
+	}
+	if _, ok := v.value.(IntValue); ok {
+		return groupThousands(str)
+	}
+	return str
+}
+
+// groupThousands groups the integer digits of the numeric string with
+// comma separators. The sign and the fractional part are not
+// grouped.
+func groupThousands(str string) string {
+	var sign string
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		sign = str[:1]
+		str = str[1:]
+	}
+	digits := str
+	var frac string
+	if idx := strings.IndexByte(str, '.'); idx >= 0 {
+		digits = str[:idx]
+		frac = str[idx:]
+	}
+	if len(digits) <= 3 {
+		return sign + str
+	}
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteString(frac)
+	return sb.String()
+}
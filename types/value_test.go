@@ -7,7 +7,9 @@
 package types
 
 import (
+	"math"
 	"testing"
+	"time"
 )
 
 func TestBool(t *testing.T) {
@@ -48,3 +50,155 @@ func TestFloat(t *testing.T) {
 		t.Errorf("Float() failed: %s", err)
 	}
 }
+
+func TestFloatNaNCompare(t *testing.T) {
+	nan := FloatValue(math.NaN())
+	inf := FloatValue(math.Inf(1))
+
+	tests := []struct {
+		v1, v2 Value
+		cmp    int
+	}{
+		{nan, nan, 0},
+		{nan, inf, 1},
+		{inf, nan, -1},
+		{FloatValue(1), nan, -1},
+		{nan, FloatValue(math.Inf(-1)), 1},
+	}
+	for _, test := range tests {
+		cmp, err := Compare(test.v1, test.v2)
+		if err != nil {
+			t.Fatalf("Compare(%v, %v) failed: %s", test.v1, test.v2, err)
+		}
+		if cmp != test.cmp {
+			t.Errorf("Compare(%v, %v) = %d, expected %d",
+				test.v1, test.v2, cmp, test.cmp)
+		}
+	}
+}
+
+func TestFloatFormat(t *testing.T) {
+	val := FloatValue(4.1)
+
+	str := val.String()
+	if str != "4.1" {
+		t.Errorf("Float.String() failed: got %s, expected 4.1", str)
+	}
+	formatted := NewFormattedValue(val, &Format{}).String()
+	if formatted != str {
+		t.Errorf("FormattedValue.String() failed: got %s, expected %s",
+			formatted, str)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	val := DateValue(time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC))
+
+	str := NewFormattedValue(val, &Format{}).String()
+	if str != val.String() {
+		t.Errorf("FormattedValue.String() failed: got %s, expected %s",
+			str, val.String())
+	}
+	str = NewFormattedValue(val, &Format{Date: "02.01.2006"}).String()
+	if str != "01.03.2024" {
+		t.Errorf("FormattedValue.String() failed: got %s, expected 01.03.2024",
+			str)
+	}
+}
+
+func TestThousands(t *testing.T) {
+	tests := []struct {
+		v   Value
+		str string
+	}{
+		{IntValue(1234567), "1,234,567"},
+		{IntValue(-1234567), "-1,234,567"},
+		{IntValue(123456), "123,456"},
+		{IntValue(-123), "-123"},
+		{IntValue(0), "0"},
+		{FloatValue(1234567.5), "1.2345675e+06"},
+	}
+	for _, test := range tests {
+		str := NewFormattedValue(test.v, &Format{
+			Thousands: true,
+		}).Display()
+		if str != test.str {
+			t.Errorf("Display(%v): got %s, expected %s", test.v, str, test.str)
+		}
+	}
+	if str := groupThousands("-12345.6789"); str != "-12,345.6789" {
+		t.Errorf("groupThousands: got %s, expected -12,345.6789", str)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	tests := []struct {
+		count int64
+		unit  string
+		str   string
+	}{
+		{1, "DAY", "1 day"},
+		{14, "month", "1 year 2 months"},
+		{-2, "YEAR", "-2 years"},
+		{90, "MINUTE", "01:30:00"},
+		{0, "SECOND", "00:00:00"},
+	}
+	for _, test := range tests {
+		val, err := NewInterval(test.count, test.unit)
+		if err != nil {
+			t.Fatalf("NewInterval(%d, %s) failed: %s", test.count, test.unit,
+				err)
+		}
+		if val.String() != test.str {
+			t.Errorf("Interval.String() failed: got %s, expected %s",
+				val.String(), test.str)
+		}
+	}
+	_, err := NewInterval(1, "WEEK")
+	if err == nil {
+		t.Errorf("NewInterval accepted invalid unit")
+	}
+
+	t1, err := ParseDate("2020-02-28 12:00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := ParseDate("2020-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := Since(t1, t2)
+	if since.String() != "58 days 12:00:00" {
+		t.Errorf("Since() failed: got %s", since)
+	}
+	if !since.AddTo(t2).Equal(t1) {
+		t.Errorf("AddTo() failed: got %s, expected %s", since.AddTo(t2), t1)
+	}
+}
+
+func TestIntervalAddTo(t *testing.T) {
+	tests := []struct {
+		t        string
+		interval IntervalValue
+		expected string
+	}{
+		{"2020-01-31", IntervalValue{Months: 1}, "2020-02-29"},
+		{"2021-01-31", IntervalValue{Months: 1}, "2021-02-28"},
+		{"2020-03-31", IntervalValue{Months: -1}, "2020-02-29"},
+		{"2020-01-31", IntervalValue{Months: 2}, "2020-03-31"},
+		{"2020-01-31", IntervalValue{Months: 1, Days: 1}, "2020-03-01"},
+		{"2020-02-29", IntervalValue{Months: 12}, "2021-02-28"},
+		{"2020-12-31", IntervalValue{Months: 2}, "2021-02-28"},
+	}
+	for _, test := range tests {
+		start, err := ParseDate(test.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := test.interval.AddTo(start).Format(DateLayout)
+		if got != test.expected {
+			t.Errorf("%s + %s: got %s, expected %s", test.t, test.interval,
+				got, test.expected)
+		}
+	}
+}